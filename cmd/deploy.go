@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/samber/do"
+	"github.com/spf13/cobra"
+	"github.com/yz4230/githost-poc/internal/usecase"
+)
+
+var deployRollbackFlags struct {
+	to string
+}
+
+var deployCmd = &cobra.Command{
+	Use:   "deploy",
+	Short: "Manage deployments",
+}
+
+var deployRollbackCmd = &cobra.Command{
+	Use:   "rollback <owner/repo>",
+	Short: "Relaunch the image of a previous deployment without rebuilding it",
+	Long: `Relaunch the image of a previous successful deployment without rebuilding it.
+Without --to, the latest successful deployment of a commit other than the active one is used.
+The rollback is queued and run by the server.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		injector := newInjector()
+		usecase := do.MustInvoke[usecase.RollbackRepositoryUsecase](injector)
+		dep, err := usecase.Execute(cmd.Context(), args[0], deployRollbackFlags.to)
+		if err != nil {
+			return fmt.Errorf("rollback: %w", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "queued deployment %s of %s (rollback of deployment %s)\n", dep.ID, dep.CommitSHA[:7], dep.RollbackOf)
+		return nil
+	},
+}
+
+func init() {
+	deployRollbackCmd.Flags().StringVar(&deployRollbackFlags.to, "to", "", "Commit to roll back to")
+	deployCmd.AddCommand(deployRollbackCmd)
+}
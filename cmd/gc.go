@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/docker/go-units"
+	"github.com/rs/zerolog/log"
+	"github.com/samber/do"
+	"github.com/spf13/cobra"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/server"
+	"github.com/yz4230/githost-poc/internal/usecase"
+)
+
+var gcImagesFlags struct {
+	keep    int
+	dryRun  bool
+	runtime string
+}
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Free disk space used by deployments",
+}
+
+var gcImagesCmd = &cobra.Command{
+	Use:   "images",
+	Short: "Remove stopped containers and old images",
+	Long: `Remove stopped containers and all but the newest images of every repository.
+Images of active, queued and running deployments and of running containers are always kept.
+The local runtime only knows its processes inside the server, so run this against it with --dry-run
+or while the server is stopped.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := os.MkdirAll(rootPersistentFlags.dataDir, os.ModePerm); err != nil {
+			return err
+		}
+		injector := server.NewInjector(&server.Config{
+			Root:    rootPersistentFlags.dataDir,
+			Logger:  log.Logger,
+			Runtime: gcImagesFlags.runtime,
+		})
+		prune := do.MustInvoke[usecase.PruneImagesUsecase](injector)
+		report, err := prune.Execute(log.Logger.WithContext(cmd.Context()), usecase.PruneImagesOptions{
+			Keep:   gcImagesFlags.keep,
+			DryRun: gcImagesFlags.dryRun,
+		})
+		if err != nil {
+			return fmt.Errorf("prune images: %w", err)
+		}
+
+		verb := "removed"
+		if gcImagesFlags.dryRun {
+			verb = "would remove"
+		}
+		out := cmd.OutOrStdout()
+		for _, c := range report.Containers {
+			fmt.Fprintf(out, "%s container %s\n", verb, c.Name)
+		}
+		for _, image := range report.Images {
+			fmt.Fprintf(out, "%s image %s:%s (%s)\n", verb, image.RepoName, image.CommitSHA, units.HumanSize(float64(image.Size)))
+		}
+		if gcImagesFlags.dryRun {
+			fmt.Fprintf(out, "would free up to %s\n", units.HumanSize(float64(report.FreedBytes)))
+		} else {
+			fmt.Fprintf(out, "freed up to %s\n", units.HumanSize(float64(report.FreedBytes)))
+		}
+		return nil
+	},
+}
+
+func init() {
+	gcImagesCmd.Flags().IntVar(&gcImagesFlags.keep, "keep", 5, "Number of newest images kept per repository")
+	gcImagesCmd.Flags().BoolVar(&gcImagesFlags.dryRun, "dry-run", false, "Only report what would be removed")
+	gcImagesCmd.Flags().StringVar(&gcImagesFlags.runtime, "runtime", deployer.RuntimeDocker, "Runtime the server runs apps with, docker or local")
+	gcCmd.AddCommand(gcImagesCmd)
+}
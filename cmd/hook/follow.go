@@ -0,0 +1,82 @@
+package hook
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/usecase"
+)
+
+const (
+	// pushOptionFollow (git push -o githost.follow) shows the build progress until the deployments
+	// finish instead of returning right after they are queued.
+	pushOptionFollow = "githost.follow"
+	// pushOptionClean (git push -o githost.clean) builds without the build cache.
+	pushOptionClean = "githost.clean"
+
+	// followQueuedTimeout is how long to wait for a queued deployment to start before detaching.
+	followQueuedTimeout = 30 * time.Second
+)
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiFaint = "\x1b[2m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+)
+
+// pushOptions returns the options given with git push -o.
+func pushOptions() []string {
+	n, _ := strconv.Atoi(os.Getenv("GIT_PUSH_OPTION_COUNT"))
+	opts := make([]string, 0, n)
+	for i := range n {
+		opts = append(opts, os.Getenv(fmt.Sprintf("GIT_PUSH_OPTION_%d", i)))
+	}
+	return opts
+}
+
+// followDeployment copies the build log of the deployment to w as it is written, until the
+// deployment finishes. The build itself runs in the server, so it keeps going when the
+// client goes away and this hook is killed.
+func followDeployment(ctx context.Context, injector *do.Injector, id entity.ID, w io.Writer) error {
+	usecase := do.MustInvoke[usecase.FollowDeploymentLogUsecase](injector)
+	dep, err := usecase.Execute(ctx, id, followQueuedTimeout, func(line string) error {
+		_, err := fmt.Fprintln(w, colorize(line))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if dep.Status == entity.DeploymentStatusPending {
+		fmt.Fprintf(w, "githost: deployment %s is still queued and will run in the background\n", id)
+	}
+	return nil
+}
+
+// colorize highlights a line of the build log for the terminal of the pushing user.
+func colorize(line string) string {
+	color := ""
+	switch {
+	case strings.HasPrefix(line, "ERROR"):
+		color = ansiRed
+	case strings.HasPrefix(line, "=> "), strings.HasPrefix(line, "Step "), strings.HasPrefix(line, "Deploying "):
+		color = ansiBold + ansiCyan
+	case strings.HasPrefix(line, "Built image"), strings.HasPrefix(line, "Started container"), strings.HasSuffix(line, "succeeded"):
+		color = ansiGreen
+	case strings.HasPrefix(line, " ---> "):
+		color = ansiFaint
+	}
+	if color == "" {
+		return line
+	}
+	return color + line + ansiReset
+}
@@ -0,0 +1,87 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/usecase"
+)
+
+func TestPushOptions(t *testing.T) {
+	t.Setenv("GIT_PUSH_OPTION_COUNT", "2")
+	t.Setenv("GIT_PUSH_OPTION_0", pushOptionClean)
+	t.Setenv("GIT_PUSH_OPTION_1", pushOptionFollow)
+	if got := pushOptions(); !slices.Equal(got, []string{pushOptionClean, pushOptionFollow}) {
+		t.Fatalf("push options = %q, want clean and follow", got)
+	}
+	// pushes without -o do not follow
+	t.Setenv("GIT_PUSH_OPTION_COUNT", "")
+	if got := pushOptions(); len(got) != 0 {
+		t.Fatalf("push options without -o = %q, want none", got)
+	}
+}
+
+func TestFollowDeployment(t *testing.T) {
+	h := newHookTest(t, 2)
+	ctx := context.Background()
+	runtime := deployer.NewFakeRuntime()
+	do.OverrideValue[deployer.Runtime](h.injector, runtime)
+	run := do.MustInvoke[usecase.RunDeploymentUsecase](h.injector)
+
+	// deploy runs the deployment of a new commit of main and returns what following it shows
+	deploy := func() (*entity.Deployment, string) {
+		t.Helper()
+		main := h.commit("main")
+		queued, out := h.receive(refLine(entity.ZeroSHA, main, "refs/heads/main"))
+		if len(queued) != 1 {
+			t.Fatalf("queued %d deployments, want 1:\n%s", len(queued), out)
+		}
+		dep, _ := run.Execute(ctx, queued[0].ID)
+		var followed strings.Builder
+		if err := followDeployment(ctx, h.injector, dep.ID, &followed); err != nil {
+			t.Fatal(err)
+		}
+		return dep, followed.String()
+	}
+
+	runtime.BuildFunc = func(req *deployer.BuildRequest) error {
+		fmt.Fprintln(req.Output, "=> [1/2] FROM docker.io/library/alpine:3")
+		fmt.Fprintln(req.Output, "fetching packages")
+		return nil
+	}
+	dep, out := deploy()
+	instances, err := runtime.List(ctx, map[string]string{deployer.LabelDeployment: dep.ID.String()})
+	if err != nil || len(instances) != 1 {
+		t.Fatalf("instances of the deployment = %v, %v, want one", instances, err)
+	}
+	for _, want := range []string{
+		ansiBold + ansiCyan + "Deploying " + dep.CommitSHA[:7] + " (main) of alice/app" + ansiReset + "\n",
+		ansiBold + ansiCyan + "=> [1/2] FROM docker.io/library/alpine:3" + ansiReset + "\n",
+		"\nfetching packages\n",
+		ansiGreen + "Started container " + instances[0].Name + ", waiting for it to become healthy" + ansiReset + "\n",
+		ansiGreen + "Deployment " + dep.ID.String() + " succeeded" + ansiReset + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("followed output does not contain %q:\n%s", want, out)
+		}
+	}
+
+	runtime.BuildFunc = func(req *deployer.BuildRequest) error {
+		return errors.New("build failed: exit code 2")
+	}
+	dep, out = deploy()
+	if dep.Status != entity.DeploymentStatusFailed {
+		t.Fatalf("deployment status = %s, want failed", dep.Status)
+	}
+	want := ansiRed + "ERROR: deployment " + dep.ID.String() + " failed: failed to build image: build failed: exit code 2" + ansiReset + "\n"
+	if !strings.HasSuffix(out, want) {
+		t.Fatalf("followed output does not end with %q:\n%s", want, out)
+	}
+}
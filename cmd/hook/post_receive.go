@@ -3,26 +3,26 @@ package hook
 import (
 	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
-	"github.com/docker/docker/api/types/build"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/filters"
-	"github.com/docker/docker/client"
-	"github.com/docker/docker/pkg/jsonmessage"
-	"github.com/moby/go-archive"
 	"github.com/rs/zerolog/log"
+	"github.com/samber/do"
 	"github.com/spf13/cobra"
+	"github.com/yz4230/githost-poc/internal/config"
+	"github.com/yz4230/githost-poc/internal/deployconfig"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/server"
+	"github.com/yz4230/githost-poc/internal/storage"
+	"github.com/yz4230/githost-poc/internal/usecase"
 )
 
-const BRANCH_FOR_DEPLOY = "refs/heads/main"
-
 var postReceiveCmd = &cobra.Command{
 	Use:           "post-receive",
 	Short:         "Handle post-receive git hook. Not intended to be run manually.",
@@ -33,182 +33,162 @@ var postReceiveCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal().Err(err).Msg("getwd")
 		}
-		reponame := strings.TrimSuffix(filepath.Base(gitDir), ".git")
-
-		shouldDeploy := false
-		s := bufio.NewScanner(os.Stdin)
-		var oldsha, newsha, refName string
-		for s.Scan() {
-			line := s.Text()
-			parts := strings.Fields(line)
-			if len(parts) != 3 {
-				log.Error().Str("line", line).Msg("invalid input line")
-				continue
-			}
-			oldsha, newsha, refName = parts[0], parts[1], parts[2]
-
-			if refName == BRANCH_FOR_DEPLOY {
-				shouldDeploy = true
-				break
-			}
-		}
-
-		if err := s.Err(); err != nil {
-			log.Fatal().Err(err).Msg("read stdin")
-		}
-
-		if !shouldDeploy {
-			log.Info().Msg("no deployment needed")
-			return nil
-		}
-
-		log.Info().Str("old_sha", oldsha).Str("new_sha", newsha).Str("ref", refName).Msg("starting deployment...")
-
-		tmpDir, err := os.MkdirTemp("", fmt.Sprintf("deployment-%s-*", newsha))
+		dataDir, reponame := repoOfDir(gitDir, os.Getenv(config.EnvDataDir))
+		// previews are disabled unless the server tells how many a repository may have
+		maxPreviews, _ := strconv.Atoi(os.Getenv(config.EnvMaxPreviews))
+		injector := server.NewInjector(&server.Config{Root: dataDir, Logger: log.Logger, MaxPreviews: maxPreviews})
+		ctx := log.Logger.WithContext(cmd.Context())
+
+		getUsecase := do.MustInvoke[usecase.GetRepositoryByNameUsecase](injector)
+		repo, err := getUsecase.Execute(ctx, reponame)
 		if err != nil {
-			log.Error().Err(err).Msg("create temp dir")
+			log.Error().Err(err).Str("repo", reponame).Msg("failed to find repository")
 			return err
 		}
-		defer os.RemoveAll(tmpDir)
+		repoDir := do.MustInvoke[storage.GitStorage](injector).GetRepoDir(repo.FullName())
+		clean := slices.Contains(pushOptions(), pushOptionClean)
+		out := cmd.OutOrStdout()
 
-		if err := exec.Command("git", "clone", "--depth=1", "--branch=main", gitDir, tmpDir).Run(); err != nil {
-			log.Error().Err(err).Msg("git clone")
+		queued, err := queueRefs(ctx, injector, repo, repoDir, os.Stdin, out, clean)
+		if err != nil {
 			return err
 		}
 
-		if _, err := os.Stat(filepath.Join(tmpDir, "Dockerfile")); os.IsNotExist(err) {
-			log.Warn().Msg("no Dockerfile found, skipping deployment")
+		if len(queued) == 0 {
+			log.Info().Str("deploy_branch", repo.DeployBranch).Msg("no deployment needed")
 			return nil
 		}
-
-		log.Info().Msg("starting deployment with docker")
-
-		if err := deployWithDocker(tmpDir, reponame, newsha); err != nil {
-			log.Error().Err(err).Msg("failed to deploy with docker")
-			return err
+		if !slices.Contains(pushOptions(), pushOptionFollow) {
+			return nil
+		}
+		// the deployments of a repository run one after another, so following them in order waits
+		// no longer than following the last one
+		for _, dep := range queued {
+			if err := followDeployment(ctx, injector, dep.ID, out); err != nil {
+				log.Error().Err(err).Msg("failed to follow deployment")
+				return err
+			}
 		}
 
 		return nil
 	},
 }
 
-func deployWithDocker(repodir, reponame, commitSHA string) error {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		return fmt.Errorf("failed to create docker client: %w", err)
-	}
-	defer cli.Close()
-
-	imageID, err := buildDockerImage(cli, repodir, reponame, commitSHA)
-	if err != nil {
-		return fmt.Errorf("failed to build docker image: %w", err)
-	}
+// queueRefs queues a deployment for every ref line of the post-receive input in, the deploy
+// branch as the app and the other branches as previews. It returns the queued deployments.
+func queueRefs(ctx context.Context, injector *do.Injector, repo *entity.Repository, repoDir string, in io.Reader, out io.Writer, clean bool) ([]*entity.Deployment, error) {
+	maxPreviews := do.MustInvoke[*config.Config](injector).MaxPreviews
+	var queued []*entity.Deployment
+	s := bufio.NewScanner(in)
+	for s.Scan() {
+		line := s.Text()
+		parts := strings.Fields(line)
+		if len(parts) != 3 {
+			log.Error().Str("line", line).Msg("invalid input line")
+			continue
+		}
+		newsha, refName := parts[1], parts[2]
+		branch, ok := strings.CutPrefix(refName, "refs/heads/")
+		if !ok {
+			continue
+		}
 
-	containers, err := cli.ContainerList(context.Background(), container.ListOptions{
-		All: true,
-		Filters: filters.NewArgs(
-			filters.Arg("label", "githost.enabled=true"),
-			filters.Arg("label", fmt.Sprintf("githost.repo=%s", reponame)),
-		),
-	})
-	if err != nil {
-		log.Error().Err(err).Msg("failed to list containers")
-		return err
-	}
-	if len(containers) > 0 {
-		for _, c := range containers {
-			log.Info().Str("container", c.ID).Msg("removing existing container")
-			if err := cli.ContainerStop(context.Background(), c.ID, container.StopOptions{}); err != nil {
-				log.Error().Err(err).Msg("failed to stop existing container")
-				return err
+		var dep *entity.Deployment
+		var err error
+		switch {
+		case refName == repo.DeployRef() && newsha == entity.ZeroSHA:
+			log.Warn().Str("deploy_branch", repo.DeployBranch).Msg("deploy branch deleted, the app keeps running")
+			continue
+		case newsha == entity.ZeroSHA:
+			dep, err = do.MustInvoke[usecase.TeardownPreviewUsecase](injector).Execute(ctx, repo.FullName(), branch)
+			if err == entity.ErrNotFound {
+				continue
 			}
-			if err := cli.ContainerRemove(context.Background(), c.ID, container.RemoveOptions{}); err != nil {
-				log.Error().Err(err).Msg("failed to remove existing container")
-				return err
+			if err != nil {
+				log.Error().Err(err).Str("branch", branch).Msg("failed to queue preview removal")
+				return nil, err
+			}
+			fmt.Fprintf(out, "githost: queued removal %s of preview %s\n", dep.ID, repo.PreviewName(branch))
+		case refName != repo.DeployRef() && repo.PreviewLimit(maxPreviews) == 0:
+			fmt.Fprintf(out, "githost: previews are disabled for %s, %s was not deployed\n", repo.FullName(), branch)
+			continue
+		default:
+			valid, err := checkDeployConfig(ctx, out, repoDir, newsha)
+			if err != nil {
+				return nil, err
+			}
+			if !valid {
+				continue
+			}
+			if refName == repo.DeployRef() {
+				dep, err = do.MustInvoke[usecase.CreateDeploymentUsecase](injector).Execute(ctx, repo.FullName(), branch, newsha, clean)
+			} else {
+				dep, err = do.MustInvoke[usecase.CreatePreviewDeploymentUsecase](injector).Execute(ctx, repo.FullName(), branch, newsha, clean)
+			}
+			if err == entity.ErrDisabled {
+				fmt.Fprintf(out, "githost: previews are disabled for %s, %s was not deployed\n", repo.FullName(), branch)
+				continue
+			}
+			if err == entity.ErrLimitExceeded {
+				fmt.Fprintf(out, "githost: %s already has as many previews as it may have, %s was not deployed\n", repo.FullName(), branch)
+				continue
+			}
+			if err != nil {
+				log.Error().Err(err).Msg("failed to queue deployment")
+				return nil, err
+			}
+			if dep.Preview {
+				fmt.Fprintf(out, "githost: queued deployment %s of %s (%s) as preview %s\n", dep.ID, newsha[:7], branch, repo.PreviewName(branch))
+			} else {
+				fmt.Fprintf(out, "githost: queued deployment %s of %s (%s)\n", dep.ID, newsha[:7], branch)
 			}
-			log.Info().Str("container", c.ID).Msg("removed existing container")
 		}
+		queued = append(queued, dep)
 	}
-
-	log.Info().Str("image", imageID).Msg("starting new container")
-
-	containerName := fmt.Sprintf("%s-%s", reponame, commitSHA[:7])
-	resp, err := cli.ContainerCreate(context.Background(),
-		&container.Config{
-			Image: fmt.Sprintf("%s:%s", reponame, commitSHA),
-			Labels: map[string]string{
-				"githost.enabled": "true",
-				"githost.repo":    reponame,
-				"githost.commit":  commitSHA,
-			},
-		},
-		&container.HostConfig{
-			RestartPolicy: container.RestartPolicy{
-				Name: container.RestartPolicyUnlessStopped,
-			},
-		}, nil, nil, containerName)
-
-	if err != nil {
-		return fmt.Errorf("failed to create container: %w", err)
+	if err := s.Err(); err != nil {
+		return nil, err
 	}
-
-	if err := cli.ContainerStart(context.Background(), resp.ID, container.StartOptions{}); err != nil {
-		return fmt.Errorf("failed to start container: %w", err)
-	}
-
-	log.Info().Str("container", resp.ID).Msg("started new container")
-
-	return nil
+	return queued, nil
 }
 
-func buildDockerImage(cli *client.Client, repodir, reponame, commitSHA string) (string, error) {
-	buildContext, err := archive.TarWithOptions(repodir, &archive.TarOptions{})
-	if err != nil {
-		return "", fmt.Errorf("failed to create tar archive: %w", err)
+// checkDeployConfig reports a broken config file of the commit to the pusher right away instead
+// of failing the build later. It returns false if the commit must not be deployed.
+func checkDeployConfig(ctx context.Context, out io.Writer, repoDir, commitSHA string) (bool, error) {
+	_, err := deployconfig.Load(ctx, repoDir, commitSHA)
+	if err == nil {
+		return true, nil
 	}
-	buildOptions := build.ImageBuildOptions{
-		Tags: []string{fmt.Sprintf("%s:%s", reponame, commitSHA), fmt.Sprintf("%s:latest", reponame)},
-		Labels: map[string]string{
-			"githost.enabled": "true",
-			"githost.repo":    reponame,
-			"githost.commit":  commitSHA,
-		},
-		Dockerfile: "Dockerfile",
-		Remove:     true,
-		NoCache:    true,
+	var verr *deployconfig.ValidationError
+	if !errors.As(err, &verr) {
+		log.Error().Err(err).Msg("failed to read deploy config")
+		return false, err
 	}
-	resp, err := cli.ImageBuild(context.Background(), buildContext, buildOptions)
-	if err != nil {
-		return "", fmt.Errorf("failed to build image: %w", err)
+	fmt.Fprintf(out, "githost: %s is invalid, %s was not deployed:\n", deployconfig.FileName, commitSHA[:7])
+	for _, problem := range verr.Problems {
+		fmt.Fprintln(out, colorize("ERROR: "+problem))
 	}
-	defer resp.Body.Close()
+	return false, nil
+}
 
-	imageID := ""
-	dec := json.NewDecoder(resp.Body)
-	for {
-		var jm jsonmessage.JSONMessage
-		if err := dec.Decode(&jm); err != nil {
-			if err == io.EOF {
-				break
-			}
-			return "", fmt.Errorf("failed to decode json message: %w", err)
-		}
-		if stream := strings.TrimSpace(jm.Stream); stream != "" {
-			log.Info().Msg(stream)
-		}
-		if jm.Aux != nil {
-			var result build.Result
-			if err := json.Unmarshal(*jm.Aux, &result); err != nil {
-				return "", fmt.Errorf("failed to unmarshal json message: %w", err)
-			}
-			imageID = result.ID
+// repoOfDir returns the data directory and the full name of the repository stored at gitDir.
+// Repositories are stored at <data>/repositories/<owner>/<name>.git, or at
+// <data>/repositories/<name>.git without an owner. dataDir is guessed if it is empty.
+func repoOfDir(gitDir, dataDir string) (string, string) {
+	name := strings.TrimSuffix(filepath.Base(gitDir), ".git")
+	parent := filepath.Dir(gitDir)
+	if dataDir == "" {
+		if filepath.Base(parent) == "repositories" {
+			return filepath.Dir(parent), name
 		}
+		return filepath.Dir(filepath.Dir(parent)), entity.JoinFullName(filepath.Base(parent), name)
 	}
-	if imageID == "" {
-		return "", fmt.Errorf("failed to get image ID")
+	// compared as files, the paths may differ by symlinks
+	parentInfo, err := os.Stat(parent)
+	if err != nil {
+		return dataDir, name
 	}
-
-	log.Info().Str("image", imageID).Msg("built image successfully")
-
-	return imageID, nil
+	if rootInfo, err := os.Stat(filepath.Join(dataDir, "repositories")); err == nil && os.SameFile(parentInfo, rootInfo) {
+		return dataDir, name
+	}
+	return dataDir, entity.JoinFullName(filepath.Base(parent), name)
 }
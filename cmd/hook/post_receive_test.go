@@ -0,0 +1,161 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/server"
+	"github.com/yz4230/githost-poc/internal/storage"
+	"github.com/yz4230/githost-poc/internal/usecase"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// hookTest feeds pushes to the repository "alice/app" through the post-receive hook.
+type hookTest struct {
+	t        *testing.T
+	injector *do.Injector
+	repo     *entity.Repository
+	bare     string
+	work     string
+}
+
+func newHookTest(t *testing.T, maxPreviews int) *hookTest {
+	t.Helper()
+	root := t.TempDir()
+	injector := server.NewInjector(&server.Config{Root: root, Logger: zerolog.Nop(), MaxPreviews: maxPreviews})
+	repo, err := do.MustInvoke[repository.RepositoryRepository](injector).Create(context.Background(), &entity.Repository{Owner: "alice", Name: "app", DeployBranch: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a plain bare repository, the hook is run by the test
+	bare := do.MustInvoke[storage.GitStorage](injector).GetRepoDir(repo.FullName())
+	runGit(t, root, "init", "--bare", "--initial-branch=main", bare)
+	work := filepath.Join(root, "work")
+	runGit(t, root, "init", "--initial-branch=main", work)
+	return &hookTest{t: t, injector: injector, repo: repo, bare: bare, work: work}
+}
+
+// commit pushes a new commit to the branch and returns its SHA.
+func (h *hookTest) commit(branch string) string {
+	h.t.Helper()
+	runGit(h.t, h.work, "commit", "--allow-empty", "-m", "change")
+	runGit(h.t, h.work, "push", h.bare, "HEAD:"+branch)
+	return runGit(h.t, h.work, "rev-parse", "HEAD")
+}
+
+// receive runs the hook for the ref lines and returns the queued deployments and the output
+// shown to the pusher.
+func (h *hookTest) receive(lines ...string) ([]*entity.Deployment, string) {
+	h.t.Helper()
+	var out bytes.Buffer
+	in := strings.NewReader(strings.Join(lines, "\n") + "\n")
+	queued, err := queueRefs(context.Background(), h.injector, h.repo, h.bare, in, &out, false)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return queued, out.String()
+}
+
+func refLine(oldsha, newsha, ref string) string {
+	return oldsha + " " + newsha + " " + ref
+}
+
+func TestQueueRefsDeploysTheDeployBranch(t *testing.T) {
+	h := newHookTest(t, 2)
+	ctx := context.Background()
+
+	main := h.commit("main")
+	feature := h.commit("feature")
+	queued, out := h.receive(
+		refLine(entity.ZeroSHA, main, "refs/heads/main"),
+		refLine(entity.ZeroSHA, feature, "refs/heads/feature"),
+		refLine(entity.ZeroSHA, main, "refs/tags/v1"),
+	)
+	if len(queued) != 2 {
+		t.Fatalf("queued %d deployments, want 2:\n%s", len(queued), out)
+	}
+	if dep := queued[0]; dep.Preview || dep.Branch != "main" || dep.CommitSHA != main {
+		t.Fatalf("deployment of main = %+v, want the app", dep)
+	}
+	if dep := queued[1]; !dep.Preview || dep.Branch != "feature" || dep.CommitSHA != feature {
+		t.Fatalf("deployment of feature = %+v, want a preview", dep)
+	}
+
+	// once feature is the deploy branch, it is deployed as the app and main as a preview
+	repo, err := do.MustInvoke[usecase.UpdateDeployBranchUsecase](h.injector).Execute(ctx, h.repo.FullName(), "feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.repo = repo
+	nextFeature := h.commit("feature")
+	nextMain := h.commit("main")
+	queued, out = h.receive(
+		refLine(feature, nextFeature, "refs/heads/feature"),
+		refLine(main, nextMain, "refs/heads/main"),
+	)
+	if len(queued) != 2 {
+		t.Fatalf("queued %d deployments, want 2:\n%s", len(queued), out)
+	}
+	if dep := queued[0]; dep.Preview || dep.Branch != "feature" || dep.CommitSHA != nextFeature {
+		t.Fatalf("deployment of feature = %+v, want the app", dep)
+	}
+	if dep := queued[1]; !dep.Preview || dep.Branch != "main" {
+		t.Fatalf("deployment of main = %+v, want a preview", dep)
+	}
+	stored, err := do.MustInvoke[repository.RepositoryRepository](h.injector).GetByID(ctx, h.repo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.LatestSHA != nextFeature {
+		t.Fatalf("latest SHA = %s, want %s of the deploy branch", stored.LatestSHA, nextFeature)
+	}
+
+	// deleting the deploy branch keeps the app
+	if queued, out := h.receive(refLine(nextFeature, entity.ZeroSHA, "refs/heads/feature")); len(queued) != 0 {
+		t.Fatalf("deleting the deploy branch queued %d deployments:\n%s", len(queued), out)
+	}
+}
+
+func TestQueueRefsWithPreviewsDisabled(t *testing.T) {
+	h := newHookTest(t, 0)
+
+	main := h.commit("main")
+	// the config of a branch that is not deployed is not checked
+	if err := os.WriteFile(filepath.Join(h.work, ".githost.yml"), []byte("run:\n  port: -1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, h.work, "add", "-A")
+	feature := h.commit("feature")
+	queued, out := h.receive(
+		refLine(entity.ZeroSHA, main, "refs/heads/main"),
+		refLine(entity.ZeroSHA, feature, "refs/heads/feature"),
+	)
+	if len(queued) != 1 || queued[0].Preview {
+		t.Fatalf("queued %v, want only the app:\n%s", queued, out)
+	}
+	if !strings.Contains(out, "previews are disabled for alice/app, feature was not deployed") {
+		t.Fatalf("output does not tell that previews are disabled:\n%s", out)
+	}
+	if strings.Contains(out, "as many previews") || strings.Contains(out, "is invalid") {
+		t.Fatalf("output reports a limit or the config of a branch that is not deployed:\n%s", out)
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samber/do"
+	"github.com/spf13/cobra"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/server"
+	"github.com/yz4230/githost-poc/internal/usecase"
+)
+
+var migrateOwnersFlags struct {
+	runtime string
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate data created by earlier versions",
+}
+
+var migrateOwnersCmd = &cobra.Command{
+	Use:   "owners <owner>",
+	Short: "Move repositories without an owner into the namespace of a user or organization",
+	Long: `Move the repositories created before owners existed into the namespace of a user or organization.
+Each repository is renamed from <name> to <owner>/<name>: the bare repository and the images move,
+the running app and previews are relaunched under the new name by the server, and /repos/<name>.git
+keeps working as a deprecated redirect. Apps move to <name>.<owner>.<apps domain> unless a host is set.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := os.MkdirAll(rootPersistentFlags.dataDir, os.ModePerm); err != nil {
+			return err
+		}
+		injector := server.NewInjector(&server.Config{
+			Root:    rootPersistentFlags.dataDir,
+			Logger:  log.Logger,
+			Runtime: migrateOwnersFlags.runtime,
+		})
+		migrate := do.MustInvoke[usecase.MigrateRepositoryOwnersUsecase](injector)
+		repos, err := migrate.Execute(log.Logger.WithContext(cmd.Context()), args[0])
+		for _, repo := range repos {
+			fmt.Fprintf(cmd.OutOrStdout(), "moved %s to %s\n", repo.Name, repo.FullName())
+		}
+		if err != nil {
+			return fmt.Errorf("migrate owners: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	migrateOwnersCmd.Flags().StringVar(&migrateOwnersFlags.runtime, "runtime", deployer.RuntimeDocker, "Runtime the server runs apps with, docker or local")
+	migrateCmd.AddCommand(migrateOwnersCmd)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samber/do"
+	"github.com/spf13/cobra"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/usecase"
+)
+
+var orgAddFlags struct {
+	description string
+	admin       string
+}
+
+var orgCmd = &cobra.Command{
+	Use:   "org",
+	Short: "Manage organizations",
+}
+
+var orgAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Create an organization",
+	Long: `Create an organization. Organizations own repositories like users do and share one namespace
+with them. Server admins and the admins of an organization create repositories in it, grant
+other users access with githost user grant.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		injector := newInjector()
+		usecase := do.MustInvoke[usecase.CreateOrganizationUsecase](injector)
+		org, err := usecase.Execute(cmd.Context(), &entity.Organization{Name: args[0], Description: orgAddFlags.description}, orgAddFlags.admin)
+		if err != nil {
+			return fmt.Errorf("create organization: %w", err)
+		}
+		log.Info().Str("organization", org.Name).Msg("created organization")
+		return nil
+	},
+}
+
+func init() {
+	orgAddCmd.Flags().StringVar(&orgAddFlags.description, "description", "", "Description of the organization")
+	orgAddCmd.Flags().StringVar(&orgAddFlags.admin, "admin", "", "User to make the first admin of the organization")
+	orgCmd.AddCommand(orgAddCmd)
+}
@@ -11,6 +11,7 @@ import (
 
 var rootPersistentFlags struct {
 	verbose bool
+	dataDir string
 }
 
 var rootCmd = &cobra.Command{
@@ -31,6 +32,13 @@ func Execute() {
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVarP(&rootPersistentFlags.dataDir, "data", "d", "./data", "Directory to store server data")
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(userCmd)
+	rootCmd.AddCommand(orgCmd)
+	rootCmd.AddCommand(tokenCmd)
+	rootCmd.AddCommand(deployCmd)
+	rootCmd.AddCommand(gcCmd)
+	rootCmd.AddCommand(migrateCmd)
 	rootCmd.AddCommand(hookcmd.HookCmd)
 }
@@ -3,31 +3,103 @@ package cmd
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/docker/go-units"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/yz4230/githost-poc/internal/deployconfig"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/secret"
 	"github.com/yz4230/githost-poc/internal/server"
 )
 
 var serveFlags struct {
-	port    int
-	dataDir string
+	port            int
+	createOnPush    bool
+	deployWorkers   int
+	proxyPort       int
+	appsDomain      string
+	runtime         string
+	maxPreviews     int
+	restoreWindow   time.Duration
+	keepImages      int
+	pruneInterval   time.Duration
+	masterKeyFile   string
+	memory          string
+	cpus            float64
+	pids            int64
+	readOnlyRootfs  bool
+	capDrop         []string
+	noNewPrivileges bool
+	healthPath      string
+	healthPort      int
+	healthTimeout   time.Duration
 }
 
 var serveCmd = &cobra.Command{
 	Use: "serve",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := os.MkdirAll(serveFlags.dataDir, os.ModePerm); err != nil {
+		if err := os.MkdirAll(rootPersistentFlags.dataDir, os.ModePerm); err != nil {
 			log.Fatal().Err(err).Msg("failed to create data directory")
 			return err
 		}
 
-		config := &server.Config{Root: serveFlags.dataDir, Port: serveFlags.port, Logger: log.Logger}
+		masterKey, err := readMasterKey()
+		if err != nil {
+			return err
+		}
+
+		if serveFlags.runtime != deployer.RuntimeDocker && serveFlags.runtime != deployer.RuntimeLocal {
+			return fmt.Errorf("--runtime must be %s or %s", deployer.RuntimeDocker, deployer.RuntimeLocal)
+		}
+
+		if serveFlags.maxPreviews < 0 {
+			return fmt.Errorf("--max-previews must not be negative")
+		}
+
+		if serveFlags.restoreWindow < 0 {
+			return fmt.Errorf("--restore-window must not be negative")
+		}
+
+		if serveFlags.keepImages < 1 {
+			return fmt.Errorf("--keep-images must be at least 1")
+		}
+
+		containerDefaults, err := containerDefaults()
+		if err != nil {
+			return err
+		}
+
+		config := &server.Config{
+			Root:              rootPersistentFlags.dataDir,
+			Port:              serveFlags.port,
+			Logger:            log.Logger,
+			CreateOnPush:      serveFlags.createOnPush,
+			DeployWorkers:     serveFlags.deployWorkers,
+			ProxyPort:         serveFlags.proxyPort,
+			AppsDomain:        serveFlags.appsDomain,
+			MasterKey:         masterKey,
+			Runtime:           serveFlags.runtime,
+			MaxPreviews:       serveFlags.maxPreviews,
+			RestoreWindow:     serveFlags.restoreWindow,
+			KeepImages:        serveFlags.keepImages,
+			PruneInterval:     serveFlags.pruneInterval,
+			ContainerDefaults: containerDefaults,
+			HealthCheck: server.HealthCheckConfig{
+				Path:    serveFlags.healthPath,
+				Port:    serveFlags.healthPort,
+				Timeout: serveFlags.healthTimeout,
+			},
+		}
 		srv := server.New(config)
 		chSignal := make(chan os.Signal, 1)
 		signal.Notify(chSignal, syscall.SIGINT, syscall.SIGTERM)
@@ -54,5 +126,62 @@ var serveCmd = &cobra.Command{
 
 func init() {
 	serveCmd.Flags().IntVarP(&serveFlags.port, "port", "p", 8080, "Port to listen on")
-	serveCmd.Flags().StringVarP(&serveFlags.dataDir, "data", "d", "./data", "Directory to store server data")
+	serveCmd.Flags().IntVar(&serveFlags.deployWorkers, "deploy-workers", 2, "Number of deployments that may build concurrently")
+	serveCmd.Flags().IntVar(&serveFlags.proxyPort, "proxy-port", 8000, "Port of the reverse proxy in front of deployed apps (0 disables it)")
+	serveCmd.Flags().StringVar(&serveFlags.appsDomain, "apps-domain", "apps.local", "Domain under which apps are served by default, as <repo>.<domain>")
+	serveCmd.Flags().StringVar(&serveFlags.runtime, "runtime", deployer.RuntimeDocker, "Runtime of deployed apps: docker, or local to run the web process of a Procfile on the host")
+	serveCmd.Flags().IntVar(&serveFlags.maxPreviews, "max-previews", 3, "Number of preview environments of branches a repository may have, unless it sets its own (0 disables previews)")
+	serveCmd.Flags().DurationVar(&serveFlags.restoreWindow, "restore-window", 7*24*time.Hour, "How long deleted repositories can be restored before they are purged (0 purges them right away)")
+	serveCmd.Flags().IntVar(&serveFlags.keepImages, "keep-images", 5, "Number of newest images kept per repository when pruning")
+	serveCmd.Flags().DurationVar(&serveFlags.pruneInterval, "prune-interval", time.Hour, "How often stopped containers and old images are pruned (0 disables pruning)")
+	serveCmd.Flags().StringVar(&serveFlags.masterKeyFile, "master-key-file", "", "File with the base64 encoded 32-byte key that encrypts environment variables (default: $"+server.EnvMasterKey+")")
+	serveCmd.Flags().StringVar(&serveFlags.memory, "memory", "", "Default memory limit of containers, e.g. 512m (default: no limit)")
+	serveCmd.Flags().Float64Var(&serveFlags.cpus, "cpus", 0, "Default number of CPUs containers may use (default: no limit)")
+	serveCmd.Flags().Int64Var(&serveFlags.pids, "pids-limit", 0, "Default maximum number of processes in a container (default: no limit)")
+	serveCmd.Flags().BoolVar(&serveFlags.readOnlyRootfs, "read-only-rootfs", false, "Run containers with a read-only root filesystem and a tmpfs at /tmp")
+	serveCmd.Flags().StringSliceVar(&serveFlags.capDrop, "cap-drop", nil, "Capabilities dropped from every container, e.g. NET_RAW or ALL")
+	serveCmd.Flags().BoolVar(&serveFlags.noNewPrivileges, "no-new-privileges", false, "Prevent processes in containers from gaining new privileges")
+	serveCmd.Flags().StringVar(&serveFlags.healthPath, "health-check-path", "", "HTTP path probed on new containers whose image has no HEALTHCHECK")
+	serveCmd.Flags().IntVar(&serveFlags.healthPort, "health-check-port", 0, "Container port probed by the HTTP health check (default: lowest exposed port)")
+	serveCmd.Flags().DurationVar(&serveFlags.healthTimeout, "health-check-timeout", time.Minute, "How long a new container may take to become healthy")
+	serveCmd.Flags().BoolVar(&serveFlags.createOnPush, "create-on-push", false, "Create unknown repositories when an authenticated user pushes to them")
+}
+
+// readMasterKey reads the master key from --master-key-file, falling back to the environment.
+func readMasterKey() ([]byte, error) {
+	encoded := os.Getenv(server.EnvMasterKey)
+	if serveFlags.masterKeyFile != "" {
+		data, err := os.ReadFile(serveFlags.masterKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read master key: %w", err)
+		}
+		encoded = string(data)
+	}
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		log.Warn().Msg("no master key configured, environment variables are disabled")
+		return nil, nil
+	}
+	return secret.ParseKey(encoded)
+}
+
+// containerDefaults builds the server wide container limits from the flags.
+func containerDefaults() (entity.ContainerLimits, error) {
+	limits := entity.ContainerLimits{
+		CPUs:            serveFlags.cpus,
+		PidsLimit:       serveFlags.pids,
+		ReadOnlyRootfs:  serveFlags.readOnlyRootfs,
+		NoNewPrivileges: serveFlags.noNewPrivileges,
+	}
+	if serveFlags.memory != "" {
+		n, err := units.RAMInBytes(serveFlags.memory)
+		if err != nil {
+			return limits, fmt.Errorf("invalid --memory: %w", err)
+		}
+		limits.MemoryBytes = n
+	}
+	for _, capability := range serveFlags.capDrop {
+		limits.CapDrop = append(limits.CapDrop, deployconfig.NormalizeCapability(capability))
+	}
+	return limits, nil
 }
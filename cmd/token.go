@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/samber/do"
+	"github.com/spf13/cobra"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/usecase"
+)
+
+var tokenCreateFlags struct {
+	name      string
+	scopes    []string
+	expiresIn time.Duration
+}
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage personal access tokens",
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create <user>",
+	Short: "Create a personal access token. The token is printed only once.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scopes := make([]entity.TokenScope, len(tokenCreateFlags.scopes))
+		for i, s := range tokenCreateFlags.scopes {
+			scopes[i] = entity.TokenScope(s)
+		}
+
+		injector := newInjector()
+		usecase := do.MustInvoke[usecase.CreateAccessTokenUsecase](injector)
+		token, secret, err := usecase.Execute(cmd.Context(), args[0], tokenCreateFlags.name, scopes, tokenCreateFlags.expiresIn)
+		if err != nil {
+			return fmt.Errorf("create token: %w", err)
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "created token %s (expires %s)\n", token.ID, token.ExpiresAt.Format(time.RFC3339))
+		fmt.Fprintln(cmd.OutOrStdout(), secret)
+		return nil
+	},
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list <user>",
+	Short: "List personal access tokens of a user",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		injector := newInjector()
+		usecase := do.MustInvoke[usecase.ListAccessTokensUsecase](injector)
+		tokens, err := usecase.Execute(cmd.Context(), args[0])
+		if err != nil {
+			return fmt.Errorf("list tokens: %w", err)
+		}
+		for _, t := range tokens {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%v\t%s\n", t.ID, t.Name, t.Scopes, t.ExpiresAt.Format(time.RFC3339))
+		}
+		return nil
+	},
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <user> <token-id>",
+	Short: "Revoke a personal access token",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		injector := newInjector()
+		usecase := do.MustInvoke[usecase.RevokeAccessTokenUsecase](injector)
+		if err := usecase.Execute(cmd.Context(), args[0], entity.NewID(args[1])); err != nil {
+			return fmt.Errorf("revoke token: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	tokenCreateCmd.Flags().StringVar(&tokenCreateFlags.name, "name", "", "Name to identify the token")
+	tokenCreateCmd.Flags().StringSliceVar(&tokenCreateFlags.scopes, "scope", []string{string(entity.TokenScopeRepoRead)}, "Scopes of the token (repo:read, repo:write)")
+	tokenCreateCmd.Flags().DurationVar(&tokenCreateFlags.expiresIn, "expires-in", 90*24*time.Hour, "Lifetime of the token")
+	tokenCmd.AddCommand(tokenCreateCmd)
+	tokenCmd.AddCommand(tokenListCmd)
+	tokenCmd.AddCommand(tokenRevokeCmd)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samber/do"
+	"github.com/spf13/cobra"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/server"
+	"github.com/yz4230/githost-poc/internal/usecase"
+)
+
+var userAddFlags struct {
+	admin         bool
+	password      string
+	passwordStdin bool
+}
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage users and their repository permissions",
+}
+
+var userAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Create a user",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		password := userAddFlags.password
+		if userAddFlags.passwordStdin {
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				return fmt.Errorf("read password: %w", err)
+			}
+			password = strings.TrimRight(line, "\r\n")
+		}
+		if password == "" {
+			return errors.New("password is required")
+		}
+
+		injector := newInjector()
+		usecase := do.MustInvoke[usecase.CreateUserUsecase](injector)
+		user, err := usecase.Execute(cmd.Context(), args[0], password, userAddFlags.admin)
+		if err != nil {
+			return fmt.Errorf("create user: %w", err)
+		}
+		log.Info().Str("user", user.Name).Bool("admin", user.IsAdmin).Msg("created user")
+		return nil
+	},
+}
+
+var userGrantCmd = &cobra.Command{
+	Use:   "grant <user> <owner/repo> <read|write|none>",
+	Short: "Grant a user access to a repository",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		access := entity.Access(args[2])
+		if args[2] == "none" {
+			access = entity.AccessNone
+		}
+
+		injector := newInjector()
+		usecase := do.MustInvoke[usecase.GrantRepositoryPermissionUsecase](injector)
+		if err := usecase.Execute(cmd.Context(), args[1], args[0], access); err != nil {
+			return fmt.Errorf("grant permission: %w", err)
+		}
+		log.Info().Str("user", args[0]).Str("repo", args[1]).Str("access", args[2]).Msg("updated permission")
+		return nil
+	},
+}
+
+func newInjector() *do.Injector {
+	if err := os.MkdirAll(rootPersistentFlags.dataDir, os.ModePerm); err != nil {
+		log.Fatal().Err(err).Msg("failed to create data directory")
+	}
+	return server.NewInjector(&server.Config{Root: rootPersistentFlags.dataDir, Logger: log.Logger})
+}
+
+func init() {
+	userAddCmd.Flags().BoolVar(&userAddFlags.admin, "admin", false, "Grant access to every repository")
+	userAddCmd.Flags().StringVar(&userAddFlags.password, "password", "", "Password of the user")
+	userAddCmd.Flags().BoolVar(&userAddFlags.passwordStdin, "password-stdin", false, "Read the password from stdin")
+	userCmd.AddCommand(userAddCmd)
+	userCmd.AddCommand(userGrantCmd)
+}
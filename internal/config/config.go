@@ -0,0 +1,59 @@
+package config
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yz4230/githost-poc/internal/entity"
+)
+
+// EnvDataDir is set for git processes spawned by the server so that hooks can open the same data directory.
+const EnvDataDir = "GITHOST_DATA_DIR"
+
+// EnvMaxPreviews passes Config.MaxPreviews of the server on to hooks, which queue the previews.
+const EnvMaxPreviews = "GITHOST_MAX_PREVIEWS"
+
+// EnvMasterKey holds the base64 encoded master key that encrypts environment variables at rest.
+const EnvMasterKey = "GITHOST_MASTER_KEY"
+
+type Config struct {
+	Root   string
+	Port   int
+	Logger zerolog.Logger
+
+	// CreateOnPush creates unknown repositories when an authenticated user pushes to them.
+	CreateOnPush bool
+	// DeployWorkers is the number of deployments that may build concurrently.
+	DeployWorkers int
+	// ProxyPort is the port of the reverse proxy in front of deployed apps. Zero disables the proxy.
+	ProxyPort int
+	// AppsDomain is the domain under which apps get their default host, <repo>.<AppsDomain>.
+	AppsDomain string
+	// MasterKey encrypts environment variables at rest. Without it, environment variables cannot be set.
+	MasterKey []byte
+	// Runtime runs the deployed apps, "docker" (the default) or "local" for processes from a Procfile.
+	Runtime string
+	// MaxPreviews is the number of preview environments of branches a repository may have, unless
+	// the repository sets its own limit. Zero disables previews.
+	MaxPreviews int
+	// RestoreWindow is how long deleted repositories can be restored before they are purged.
+	// Zero purges them right away.
+	RestoreWindow time.Duration
+	// KeepImages is the number of newest images kept per repository when images are pruned.
+	KeepImages int
+	// PruneInterval is how often stopped containers and old images are pruned. Zero disables pruning.
+	PruneInterval time.Duration
+	// ContainerDefaults are the limits of every container, repositories override them in .githost.yml.
+	ContainerDefaults entity.ContainerLimits
+	// HealthCheck configures how a new container is checked before it replaces the running one.
+	HealthCheck HealthCheckConfig
+}
+
+type HealthCheckConfig struct {
+	// Path is the HTTP path probed on containers whose image has no HEALTHCHECK. Empty disables the probe.
+	Path string
+	// Port is the probed container port. Zero uses the lowest TCP port exposed by the image.
+	Port int
+	// Timeout is how long a new container may take to become healthy.
+	Timeout time.Duration
+}
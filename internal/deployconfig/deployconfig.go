@@ -0,0 +1,239 @@
+// Package deployconfig reads the .githost.yml file that configures how a repository is built and run.
+package deployconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/git"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the path of the config file in the repository.
+const FileName = ".githost.yml"
+
+var platformPattern = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`)
+
+var capabilityPattern = regexp.MustCompile(`^(?i)(CAP_)?[A-Z_]+$`)
+
+const (
+	RestartNo            = "no"
+	RestartAlways        = "always"
+	RestartOnFailure     = "on-failure"
+	RestartUnlessStopped = "unless-stopped"
+)
+
+// Config is the content of .githost.yml. Fields that are not set keep the defaults.
+type Config struct {
+	Build Build `yaml:"build"`
+	Run   Run   `yaml:"run"`
+}
+
+type Build struct {
+	// Dockerfile is the path of the Dockerfile relative to the repository root.
+	Dockerfile string            `yaml:"dockerfile"`
+	Args       map[string]string `yaml:"args"`
+	// Target is the stage of a multi-stage Dockerfile to build.
+	Target string `yaml:"target"`
+	// Platform is the platform to build for, e.g. linux/arm64. Empty builds for the daemon's platform.
+	Platform string `yaml:"platform"`
+}
+
+type Run struct {
+	// Port is the container port the reverse proxy and the HTTP health check use.
+	// Zero uses the lowest TCP port exposed by the image.
+	Port        int               `yaml:"port"`
+	Env         map[string]string `yaml:"env"`
+	Restart     string            `yaml:"restart"`
+	HealthCheck HealthCheck       `yaml:"healthcheck"`
+	Resources   Resources         `yaml:"resources"`
+	Security    Security          `yaml:"security"`
+}
+
+type HealthCheck struct {
+	// Path enables the HTTP health check, overriding the server default.
+	Path    string        `yaml:"path"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type Resources struct {
+	// Memory is a size such as 512m or 1g.
+	Memory string  `yaml:"memory"`
+	CPUs   float64 `yaml:"cpus"`
+	Pids   int64   `yaml:"pids"`
+}
+
+// Security tightens the security options of the server. Options the server enables stay enabled,
+// unset options keep the server defaults.
+type Security struct {
+	ReadOnlyRootfs  *bool    `yaml:"read_only_rootfs"`
+	CapDrop         []string `yaml:"cap_drop"`
+	NoNewPrivileges *bool    `yaml:"no_new_privileges"`
+}
+
+// ValidationError lists everything wrong with a config file.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return FileName + ": " + strings.Join(e.Problems, "; ")
+}
+
+// Default returns the config used when the repository has no config file.
+func Default() *Config {
+	return &Config{
+		Build: Build{Dockerfile: "Dockerfile"},
+		Run:   Run{Restart: RestartUnlessStopped},
+	}
+}
+
+// Parse decodes and validates a config file. Unknown fields are rejected to catch typos.
+func Parse(data []byte) (*Config, error) {
+	cfg := Default()
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return nil, &ValidationError{Problems: typeErr.Errors}
+		}
+		return nil, &ValidationError{Problems: []string{err.Error()}}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Load reads the config file from the commit, falling back to the defaults if there is none.
+func Load(ctx context.Context, repoPath, commitSHA string) (*Config, error) {
+	data, err := git.ReadFile(ctx, repoPath, commitSHA, FileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return Default(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Validate reports every invalid field as a ValidationError.
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if !isRelativePath(c.Build.Dockerfile) {
+		addf("build.dockerfile: %q must be a path inside the repository", c.Build.Dockerfile)
+	}
+	if c.Build.Platform != "" && !platformPattern.MatchString(c.Build.Platform) {
+		addf("build.platform: %q is not a platform such as linux/amd64", c.Build.Platform)
+	}
+	for name := range c.Build.Args {
+		if name == "" {
+			addf("build.args: names must not be empty")
+		}
+	}
+
+	if c.Run.Port < 0 || c.Run.Port > 65535 {
+		addf("run.port: %d is not a valid port", c.Run.Port)
+	}
+	for name := range c.Run.Env {
+		if !entity.IsValidEnvVarName(name) {
+			addf("run.env: %q is not a valid variable name", name)
+		}
+	}
+	if !slices.Contains([]string{RestartNo, RestartAlways, RestartOnFailure, RestartUnlessStopped}, c.Run.Restart) {
+		addf("run.restart: %q must be one of no, always, on-failure, unless-stopped", c.Run.Restart)
+	}
+	if p := c.Run.HealthCheck.Path; p != "" && !strings.HasPrefix(p, "/") {
+		addf("run.healthcheck.path: %q must start with /", p)
+	}
+	if c.Run.HealthCheck.Timeout < 0 {
+		addf("run.healthcheck.timeout: must not be negative")
+	}
+	if c.Run.Resources.Memory != "" {
+		if _, err := units.RAMInBytes(c.Run.Resources.Memory); err != nil {
+			addf("run.resources.memory: %q is not a size such as 512m", c.Run.Resources.Memory)
+		}
+	}
+	if c.Run.Resources.CPUs < 0 {
+		addf("run.resources.cpus: must not be negative")
+	}
+	if c.Run.Resources.Pids < 0 {
+		addf("run.resources.pids: must not be negative")
+	}
+	for _, capability := range c.Run.Security.CapDrop {
+		if !capabilityPattern.MatchString(capability) {
+			addf("run.security.cap_drop: %q is not a capability such as NET_RAW or ALL", capability)
+		}
+	}
+
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Limits applies the resources and security options of the config over the server defaults.
+func (c *Config) Limits(defaults entity.ContainerLimits) entity.ContainerLimits {
+	limits := defaults
+	limits.CapDrop = slices.Clone(defaults.CapDrop)
+	if n := c.Run.Resources.MemoryBytes(); n > 0 {
+		limits.MemoryBytes = n
+	}
+	if c.Run.Resources.CPUs > 0 {
+		limits.CPUs = c.Run.Resources.CPUs
+	}
+	if c.Run.Resources.Pids > 0 {
+		limits.PidsLimit = c.Run.Resources.Pids
+	}
+	// like capabilities, the security options of the server are a floor a repository can only raise
+	if v := c.Run.Security.ReadOnlyRootfs; v != nil {
+		limits.ReadOnlyRootfs = defaults.ReadOnlyRootfs || *v
+	}
+	if v := c.Run.Security.NoNewPrivileges; v != nil {
+		limits.NoNewPrivileges = defaults.NoNewPrivileges || *v
+	}
+	// capabilities are only ever dropped, a repository cannot add back what the server drops
+	for _, capability := range c.Run.Security.CapDrop {
+		capability = NormalizeCapability(capability)
+		if !slices.Contains(limits.CapDrop, capability) {
+			limits.CapDrop = append(limits.CapDrop, capability)
+		}
+	}
+	return limits
+}
+
+// NormalizeCapability returns the capability name in the form docker reports it, e.g. NET_RAW.
+func NormalizeCapability(capability string) string {
+	return strings.TrimPrefix(strings.ToUpper(capability), "CAP_")
+}
+
+// MemoryBytes returns the memory limit in bytes, zero for no limit.
+func (r *Resources) MemoryBytes() int64 {
+	n, _ := units.RAMInBytes(r.Memory)
+	return n
+}
+
+func isRelativePath(p string) bool {
+	if p == "" || path.IsAbs(p) {
+		return false
+	}
+	clean := path.Clean(p)
+	return clean != ".." && !strings.HasPrefix(clean, "../")
+}
@@ -0,0 +1,152 @@
+package deployconfig
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yz4230/githost-poc/internal/entity"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(`
+build:
+  dockerfile: docker/app.Dockerfile
+  args:
+    VERSION: "1.2"
+  target: release
+run:
+  port: 8080
+  env:
+    LOG_LEVEL: debug
+  restart: on-failure
+  healthcheck:
+    path: /healthz
+    timeout: 30s
+  resources:
+    memory: 256m
+    cpus: 0.5
+    pids: 100
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Config{
+		Build: Build{Dockerfile: "docker/app.Dockerfile", Args: map[string]string{"VERSION": "1.2"}, Target: "release"},
+		Run: Run{
+			Port:        8080,
+			Env:         map[string]string{"LOG_LEVEL": "debug"},
+			Restart:     RestartOnFailure,
+			HealthCheck: HealthCheck{Path: "/healthz", Timeout: 30 * time.Second},
+			Resources:   Resources{Memory: "256m", CPUs: 0.5, Pids: 100},
+		},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("Parse() = %+v; want %+v", cfg, want)
+	}
+	if got := cfg.Run.Resources.MemoryBytes(); got != 256<<20 {
+		t.Fatalf("MemoryBytes() = %d; want %d", got, 256<<20)
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	cfg, err := Parse(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Fatalf("Parse(nil) = %+v; want %+v", cfg, Default())
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		problems int
+	}{
+		{"unknown field", "run:\n  prot: 80\n", 1},
+		{"wrong type", "run:\n  port: http\n", 1},
+		{"dockerfile outside", "build:\n  dockerfile: ../Dockerfile\n", 1},
+		{"platform", "build:\n  platform: amd64\n", 1},
+		{"several", "run:\n  port: 70000\n  restart: sometimes\n  env:\n    1X: y\n  resources:\n    memory: lots\n", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.input))
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Parse() error = %v; want a ValidationError", err)
+			}
+			if len(verr.Problems) != tt.problems {
+				t.Fatalf("Parse() problems = %q; want %d", verr.Problems, tt.problems)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	cfg, err := Parse([]byte(`
+run:
+  resources:
+    memory: 1g
+  security:
+    read_only_rootfs: false
+    cap_drop: [cap_net_raw, MKNOD]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := entity.ContainerLimits{
+		MemoryBytes:     512 << 20,
+		PidsLimit:       100,
+		ReadOnlyRootfs:  true,
+		CapDrop:         []string{"MKNOD"},
+		NoNewPrivileges: true,
+	}
+	want := entity.ContainerLimits{
+		MemoryBytes:     1 << 30,
+		PidsLimit:       100,
+		ReadOnlyRootfs:  true,
+		CapDrop:         []string{"MKNOD", "NET_RAW"},
+		NoNewPrivileges: true,
+	}
+	if got := cfg.Limits(defaults); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Limits() = %+v; want %+v", got, want)
+	}
+	if !reflect.DeepEqual(defaults.CapDrop, []string{"MKNOD"}) {
+		t.Fatalf("Limits() modified the defaults: %+v", defaults)
+	}
+}
+
+func TestLimitsSecurityFloor(t *testing.T) {
+	disable, err := Parse([]byte(`
+run:
+  security:
+    read_only_rootfs: false
+    no_new_privileges: false
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a repository cannot disable what the server enables
+	enabled := entity.ContainerLimits{ReadOnlyRootfs: true, NoNewPrivileges: true}
+	if got := disable.Limits(enabled); !got.ReadOnlyRootfs || !got.NoNewPrivileges {
+		t.Fatalf("Limits() = %+v; want the security options of the server", got)
+	}
+
+	enable, err := Parse([]byte(`
+run:
+  security:
+    read_only_rootfs: true
+    no_new_privileges: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// but it can enable what the server leaves disabled
+	if got := enable.Limits(entity.ContainerLimits{}); !got.ReadOnlyRootfs || !got.NoNewPrivileges {
+		t.Fatalf("Limits() = %+v; want the security options of the repository", got)
+	}
+}
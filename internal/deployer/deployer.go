@@ -0,0 +1,326 @@
+package deployer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/config"
+	"github.com/yz4230/githost-poc/internal/deployconfig"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/git"
+)
+
+var (
+	ErrNoDockerfile      = errors.New("no Dockerfile found")
+	ErrNoProcfile        = errors.New("no Procfile with a web process found")
+	ErrContainerNotFound = errors.New("container not found")
+	ErrImageNotFound     = errors.New("image not found")
+)
+
+type Request struct {
+	DeploymentID entity.ID
+	RepoDir      string
+	// RepoName is the full name of the repository, <owner>/<name>.
+	RepoName string
+	// FormerNames are the full names the repository had before it was renamed. Instances started
+	// under them are replaced too.
+	FormerNames []string
+	Branch      string
+	CommitSHA   string
+	// PreviewName names the preview environment the deployment replaces. Empty for the deployed app.
+	PreviewName string
+	// Config is the .githost.yml of the commit. Nil uses the defaults.
+	Config *deployconfig.Config
+	// Limits are applied to the container.
+	Limits entity.ContainerLimits
+	// Env is passed to the container as KEY=VALUE pairs. It may hold secrets and must never be logged.
+	Env []string
+	// Secrets are passed to the build as build secrets, by name. They must never be logged.
+	Secrets map[string][]byte
+	// Clean builds the image without the build cache.
+	Clean bool
+	// Prebuilt starts the image already tagged for the commit instead of building it.
+	Prebuilt bool
+	// Output receives the human readable build progress shown to the user.
+	Output io.Writer
+	// OnReady is called with the address of the new container once it is healthy, before the
+	// previous container is retired. The address is empty if the app exposes no port.
+	OnReady func(addr string)
+}
+
+type LogsRequest struct {
+	DeploymentID entity.ID
+	// Tail is the number of lines to show from the end of the logs, or "all".
+	Tail string
+	// Since shows logs since a timestamp (RFC3339 or UNIX) or relative duration (e.g. 10m).
+	Since  string
+	Follow bool
+}
+
+type Deployer interface {
+	// Deploy builds the commit of the repository and replaces the running container with it.
+	Deploy(ctx context.Context, req *Request) error
+	// Logs copies the output of the container started by the deployment to stdout and stderr.
+	// With Follow it blocks until the container stops or ctx is cancelled.
+	Logs(ctx context.Context, req *LogsRequest, stdout, stderr io.Writer) error
+	// Endpoint returns the address of the running container started by the deployment.
+	// It returns ErrContainerNotFound or ErrNoEndpoint if there is nothing to route to.
+	Endpoint(ctx context.Context, deploymentID entity.ID) (string, error)
+	// RemovePreview stops and removes the containers of the preview of the branch. repoNames are
+	// the name of the repository followed by its former names.
+	RemovePreview(ctx context.Context, repoNames []string, branch string, out io.Writer) error
+}
+
+type deployerImpl struct {
+	runtime Runtime
+	health  config.HealthCheckConfig
+}
+
+// Deploy implements Deployer.
+func (d *deployerImpl) Deploy(ctx context.Context, req *Request) error {
+	log := zerolog.Ctx(ctx)
+	out := req.Output
+	if out == nil {
+		out = io.Discard
+	}
+	cfg := req.Config
+	if cfg == nil {
+		cfg = deployconfig.Default()
+	}
+
+	if req.Prebuilt {
+		ok, err := d.runtime.HasImage(ctx, req.RepoName, req.CommitSHA)
+		if err != nil {
+			return fmt.Errorf("failed to look up image: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s:%s", ErrImageNotFound, req.RepoName, req.CommitSHA)
+		}
+		fmt.Fprintf(out, "Using existing image %s:%s\n", req.RepoName, req.CommitSHA)
+	} else {
+		tmpDir, err := os.MkdirTemp("", fmt.Sprintf("deployment-%s-*", req.CommitSHA))
+		if err != nil {
+			return fmt.Errorf("create temp dir: %w", err)
+		}
+		defer os.RemoveAll(tmpDir)
+
+		if err := git.ExportCommit(ctx, req.RepoDir, req.CommitSHA, tmpDir); err != nil {
+			return err
+		}
+
+		err = d.runtime.Build(ctx, &BuildRequest{
+			Dir:       tmpDir,
+			RepoName:  req.RepoName,
+			CommitSHA: req.CommitSHA,
+			Options:   &cfg.Build,
+			NoCache:   req.Clean,
+			Secrets:   req.Secrets,
+			Output:    out,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to build image")
+			return fmt.Errorf("failed to build image: %w", err)
+		}
+	}
+
+	return d.replace(ctx, req, cfg, out)
+}
+
+// replace starts an instance of the image next to the running ones of the repository and retires
+// them once it is healthy. If it does not become healthy, the running instances are kept.
+func (d *deployerImpl) replace(ctx context.Context, req *Request, cfg *deployconfig.Config, out io.Writer) error {
+	log := zerolog.Ctx(ctx)
+	previous, err := d.environment(ctx, append([]string{req.RepoName}, req.FormerNames...), req.PreviewName != "", req.Branch)
+	if err != nil {
+		return err
+	}
+
+	labels := map[string]string{
+		LabelEnabled:    "true",
+		LabelRepo:       req.RepoName,
+		LabelBranch:     req.Branch,
+		LabelCommit:     req.CommitSHA,
+		LabelDeployment: req.DeploymentID.String(),
+	}
+	// container names cannot contain the slash of the full name, <owner>.<name> is used instead
+	owner, name := entity.SplitFullName(req.RepoName)
+	if req.PreviewName != "" {
+		labels[LabelPreview] = req.PreviewName
+		name = req.PreviewName
+	}
+	if owner != "" {
+		name = owner + "." + name
+	}
+	if cfg.Run.Port != 0 {
+		// remembered for routing, the image may expose other ports too
+		labels[LabelPort] = strconv.Itoa(cfg.Run.Port)
+	}
+	inst, err := d.runtime.Start(ctx, &InstanceSpec{
+		Name:      fmt.Sprintf("%s-%s-%s", name, req.CommitSHA[:7], req.DeploymentID),
+		RepoName:  req.RepoName,
+		CommitSHA: req.CommitSHA,
+		Labels:    labels,
+		Env:       req.Env,
+		Port:      cfg.Run.Port,
+		Restart:   cfg.Run.Restart,
+		Limits:    req.Limits,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	log.Info().Str("container", inst.ID).Msg("started new container")
+	fmt.Fprintf(out, "Started container %s, waiting for it to become healthy\n", inst.Name)
+
+	if err := waitHealthy(ctx, d.runtime, inst.ID, d.healthCheck(cfg)); err != nil {
+		log.Error().Err(err).Str("container", inst.ID).Msg("new container is not healthy")
+		fmt.Fprintf(out, "Removing unhealthy container %s, the previous container keeps running\n", inst.Name)
+		if err := d.runtime.Stop(context.WithoutCancel(ctx), inst.ID); err != nil {
+			log.Error().Err(err).Str("container", inst.ID).Msg("failed to remove unhealthy container")
+		}
+		return fmt.Errorf("container %s is not healthy: %w", inst.Name, err)
+	}
+	fmt.Fprintf(out, "Container %s is healthy\n", inst.Name)
+
+	if req.OnReady != nil {
+		status, err := d.runtime.Status(ctx, inst.ID, cfg.Run.Port)
+		if err != nil {
+			return fmt.Errorf("failed to inspect container: %w", err)
+		}
+		if status.Addr == "" {
+			log.Warn().Str("container", inst.ID).Msg("container is not routable")
+			fmt.Fprintf(out, "Container %s exposes no port, it is not routed\n", inst.Name)
+		}
+		req.OnReady(status.Addr)
+	}
+
+	for _, p := range previous {
+		log.Info().Str("container", p.ID).Msg("retiring previous container")
+		if err := d.runtime.Stop(ctx, p.ID); err != nil {
+			// the new container already serves the app, a leftover is not worth failing the deployment
+			log.Error().Err(err).Str("container", p.ID).Msg("failed to retire previous container")
+			continue
+		}
+		fmt.Fprintf(out, "Retired container %s\n", p.Name)
+	}
+
+	return nil
+}
+
+// environment returns the instances of the deployed app of the repository, or of the preview of
+// the branch if preview is set. repoNames are the name of the repository followed by its former names.
+func (d *deployerImpl) environment(ctx context.Context, repoNames []string, preview bool, branch string) ([]*Instance, error) {
+	var instances []*Instance
+	for _, name := range repoNames {
+		found, err := d.runtime.List(ctx, map[string]string{
+			LabelEnabled: "true",
+			LabelRepo:    name,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list containers: %w", err)
+		}
+		for _, inst := range found {
+			// containers started before previews existed carry no preview label and belong to the app
+			if (inst.Labels[LabelPreview] != "") != preview {
+				continue
+			}
+			if preview && inst.Labels[LabelBranch] != branch {
+				continue
+			}
+			instances = append(instances, inst)
+		}
+	}
+	return instances, nil
+}
+
+// RemovePreview implements Deployer.
+func (d *deployerImpl) RemovePreview(ctx context.Context, repoNames []string, branch string, out io.Writer) error {
+	instances, err := d.environment(ctx, repoNames, true, branch)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, inst := range instances {
+		if err := d.runtime.Stop(ctx, inst.ID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove container %s: %w", inst.Name, err))
+			continue
+		}
+		fmt.Fprintf(out, "Removed container %s\n", inst.Name)
+	}
+	return errors.Join(errs...)
+}
+
+// healthCheck applies the health check of the repository config over the server defaults.
+func (d *deployerImpl) healthCheck(cfg *deployconfig.Config) config.HealthCheckConfig {
+	health := d.health
+	if cfg.Run.HealthCheck.Path != "" {
+		health.Path = cfg.Run.HealthCheck.Path
+	}
+	if cfg.Run.Port != 0 {
+		health.Port = cfg.Run.Port
+	}
+	if cfg.Run.HealthCheck.Timeout != 0 {
+		health.Timeout = cfg.Run.HealthCheck.Timeout
+	}
+	return health
+}
+
+// findInstance returns the instance started by the deployment.
+func (d *deployerImpl) findInstance(ctx context.Context, deploymentID entity.ID) (*Instance, error) {
+	// deployment IDs are unique across repositories, and an instance keeps the repository name
+	// it was started with when the repository is renamed
+	instances, err := d.runtime.List(ctx, map[string]string{
+		LabelEnabled:    "true",
+		LabelDeployment: deploymentID.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	if len(instances) == 0 {
+		return nil, ErrContainerNotFound
+	}
+	return instances[0], nil
+}
+
+// Logs implements Deployer.
+func (d *deployerImpl) Logs(ctx context.Context, req *LogsRequest, stdout, stderr io.Writer) error {
+	inst, err := d.findInstance(ctx, req.DeploymentID)
+	if err != nil {
+		return err
+	}
+	return d.runtime.Logs(ctx, inst.ID, &LogsOptions{Tail: req.Tail, Since: req.Since, Follow: req.Follow}, stdout, stderr)
+}
+
+// Endpoint implements Deployer.
+func (d *deployerImpl) Endpoint(ctx context.Context, deploymentID entity.ID) (string, error) {
+	inst, err := d.findInstance(ctx, deploymentID)
+	if err != nil {
+		return "", err
+	}
+	port, _ := strconv.Atoi(inst.Labels[LabelPort])
+	status, err := d.runtime.Status(ctx, inst.ID, port)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %w", err)
+	}
+	if !status.Running {
+		return "", ErrContainerNotFound
+	}
+	if status.Addr == "" {
+		return "", ErrNoEndpoint
+	}
+	return status.Addr, nil
+}
+
+func NewDeployer(i *do.Injector) (Deployer, error) {
+	config := do.MustInvoke[*config.Config](i)
+	return &deployerImpl{
+		runtime: do.MustInvoke[Runtime](i),
+		health:  config.HealthCheck,
+	}, nil
+}
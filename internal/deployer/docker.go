@@ -0,0 +1,502 @@
+package deployer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/build"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/pkg/stdcopy"
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/go-archive"
+	"github.com/moby/patternmatcher/ignorefile"
+	"github.com/rs/zerolog"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"google.golang.org/protobuf/proto"
+)
+
+// dockerRuntime runs apps as containers of images built by the docker daemon.
+type dockerRuntime struct {
+	cli *client.Client
+}
+
+// NewDockerRuntime returns the runtime talking to the docker daemon configured by the environment.
+func NewDockerRuntime() (Runtime, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client: %w", err)
+	}
+	return &dockerRuntime{cli: cli}, nil
+}
+
+// Build implements Runtime.
+func (r *dockerRuntime) Build(ctx context.Context, req *BuildRequest) error {
+	if _, err := os.Stat(filepath.Join(req.Dir, filepath.FromSlash(req.Options.Dockerfile))); os.IsNotExist(err) {
+		return fmt.Errorf("%w at %s", ErrNoDockerfile, req.Options.Dockerfile)
+	}
+	return buildDockerImage(ctx, r.cli, req)
+}
+
+// HasImage implements Runtime.
+func (r *dockerRuntime) HasImage(ctx context.Context, repoName, commitSHA string) (bool, error) {
+	if _, err := r.cli.ImageInspect(ctx, imageTag(repoName, commitSHA)); err != nil {
+		if client.IsErrNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to inspect image: %w", err)
+	}
+	return true, nil
+}
+
+// List implements Runtime.
+func (r *dockerRuntime) List(ctx context.Context, labels map[string]string) ([]*Instance, error) {
+	args := filters.NewArgs()
+	for k, v := range labels {
+		args.Add("label", k+"="+v)
+	}
+	containers, err := r.cli.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]*Instance, 0, len(containers))
+	for _, c := range containers {
+		instances = append(instances, &Instance{
+			ID:     c.ID,
+			Name:   strings.TrimPrefix(firstName(c.Names), "/"),
+			Labels: c.Labels,
+		})
+	}
+	return instances, nil
+}
+
+// Start implements Runtime.
+func (r *dockerRuntime) Start(ctx context.Context, spec *InstanceSpec) (*Instance, error) {
+	hostConfig := &container.HostConfig{
+		RestartPolicy: container.RestartPolicy{
+			Name: container.RestartPolicyMode(spec.Restart),
+		},
+	}
+	applyLimits(hostConfig, &spec.Limits)
+	resp, err := r.cli.ContainerCreate(ctx,
+		&container.Config{
+			Image:  imageTag(spec.RepoName, spec.CommitSHA),
+			Env:    spec.Env,
+			Labels: spec.Labels,
+		}, hostConfig, nil, nil, spec.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create container: %w", err)
+	}
+
+	if err := r.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		_ = r.cli.ContainerRemove(context.WithoutCancel(ctx), resp.ID, container.RemoveOptions{Force: true})
+		return nil, err
+	}
+	return &Instance{ID: resp.ID, Name: spec.Name, Labels: spec.Labels}, nil
+}
+
+// Status implements Runtime.
+func (r *dockerRuntime) Status(ctx context.Context, id string, port int) (*InstanceStatus, error) {
+	inspect, err := r.cli.ContainerInspect(ctx, id)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return nil, ErrContainerNotFound
+		}
+		return nil, err
+	}
+	status := &InstanceStatus{Restarted: inspect.RestartCount > 0, Health: HealthNone}
+	if state := inspect.State; state != nil {
+		status.Running = state.Running
+		status.ExitCode = state.ExitCode
+		if state.Health != nil && state.Health.Status != container.NoHealthcheck {
+			status.Health = state.Health.Status
+		}
+	}
+	status.Addr, _ = containerAddr(&inspect, port)
+	return status, nil
+}
+
+// Stop implements Runtime.
+func (r *dockerRuntime) Stop(ctx context.Context, id string) error {
+	if err := r.cli.ContainerStop(ctx, id, container.StopOptions{}); err != nil {
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+	if err := r.cli.ContainerRemove(ctx, id, container.RemoveOptions{}); err != nil {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+	return nil
+}
+
+// Logs implements Runtime.
+func (r *dockerRuntime) Logs(ctx context.Context, id string, opts *LogsOptions, stdout, stderr io.Writer) error {
+	inspect, err := r.cli.ContainerInspect(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	rc, err := r.cli.ContainerLogs(ctx, inspect.ID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Since:      opts.Since,
+		Tail:       opts.Tail,
+		Follow:     opts.Follow,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read container logs: %w", err)
+	}
+	defer rc.Close()
+
+	// without a TTY, docker multiplexes stdout and stderr into one stream of framed chunks
+	if inspect.Config != nil && inspect.Config.Tty {
+		_, err = io.Copy(stdout, rc)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, rc)
+	}
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to copy container logs: %w", err)
+	}
+	return nil
+}
+
+// containerAddr returns the address of the container port on the docker network.
+// A zero port uses the lowest TCP port exposed by the image.
+func containerAddr(inspect *container.InspectResponse, port int) (string, error) {
+	if port == 0 && inspect.Config != nil {
+		var ports []int
+		for p := range inspect.Config.ExposedPorts {
+			if p.Proto() == "tcp" {
+				ports = append(ports, p.Int())
+			}
+		}
+		sort.Ints(ports)
+		if len(ports) > 0 {
+			port = ports[0]
+		}
+	}
+	if port == 0 {
+		return "", fmt.Errorf("%w: the image exposes no TCP port", ErrNoEndpoint)
+	}
+	if inspect.NetworkSettings != nil {
+		for _, network := range inspect.NetworkSettings.Networks {
+			if network != nil && network.IPAddress != "" {
+				return net.JoinHostPort(network.IPAddress, strconv.Itoa(port)), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: the container has no IP address", ErrNoEndpoint)
+}
+
+// Images implements Runtime.
+func (r *dockerRuntime) Images(ctx context.Context) ([]*Image, error) {
+	summaries, err := r.cli.ImageList(ctx, image.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", LabelEnabled+"=true")),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	var images []*Image
+	for _, summary := range summaries {
+		for _, tag := range summary.RepoTags {
+			repoName, commitSHA, ok := parseImageTag(tag)
+			// an image rebuilt for another commit keeps the labels of the first build, trust the tags
+			if !ok || commitSHA != summary.Labels[LabelCommit] {
+				continue
+			}
+			images = append(images, &Image{
+				RepoName:  repoName,
+				CommitSHA: commitSHA,
+				Size:      summary.Size,
+				Created:   time.Unix(summary.Created, 0),
+			})
+		}
+	}
+	slices.SortStableFunc(images, func(a, b *Image) int { return b.Created.Compare(a.Created) })
+	return images, nil
+}
+
+// RemoveImage implements Runtime. Other tags of the image, e.g. <repo>:latest, keep it alive.
+func (r *dockerRuntime) RemoveImage(ctx context.Context, repoName, commitSHA string) error {
+	_, err := r.cli.ImageRemove(ctx, imageTag(repoName, commitSHA), image.RemoveOptions{PruneChildren: true})
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return fmt.Errorf("%w: %s", ErrImageNotFound, imageTag(repoName, commitSHA))
+		}
+		return fmt.Errorf("failed to remove image: %w", err)
+	}
+	return nil
+}
+
+// RenameImages implements Runtime. The images are tagged with the new name and the old tags are
+// removed, the labels of an image keep the name it was built under.
+func (r *dockerRuntime) RenameImages(ctx context.Context, from, to string) error {
+	images, err := r.Images(ctx)
+	if err != nil {
+		return err
+	}
+	for _, img := range images {
+		if img.RepoName != from {
+			continue
+		}
+		if err := r.cli.ImageTag(ctx, imageTag(from, img.CommitSHA), imageTag(to, img.CommitSHA)); err != nil {
+			return fmt.Errorf("failed to tag image: %w", err)
+		}
+		// with the new tag in place this only untags the image
+		if _, err := r.cli.ImageRemove(ctx, imageTag(from, img.CommitSHA), image.RemoveOptions{}); err != nil {
+			return fmt.Errorf("failed to remove old tag: %w", err)
+		}
+	}
+	return nil
+}
+
+func imageTag(repoName, commitSHA string) string {
+	return fmt.Sprintf("%s:%s", repoName, commitSHA)
+}
+
+// parseImageTag splits a tag made by imageTag.
+func parseImageTag(tag string) (repoName, commitSHA string, ok bool) {
+	i := strings.LastIndex(tag, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	return tag[:i], tag[i+1:], true
+}
+
+func applyLimits(hostConfig *container.HostConfig, limits *entity.ContainerLimits) {
+	hostConfig.Memory = limits.MemoryBytes
+	hostConfig.NanoCPUs = int64(limits.CPUs * 1e9)
+	if limits.PidsLimit > 0 {
+		pids := limits.PidsLimit
+		hostConfig.PidsLimit = &pids
+	}
+	if limits.ReadOnlyRootfs {
+		hostConfig.ReadonlyRootfs = true
+		// most apps need somewhere to write temporary files
+		hostConfig.Tmpfs = map[string]string{"/tmp": ""}
+	}
+	hostConfig.CapDrop = limits.CapDrop
+	if limits.NoNewPrivileges {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "no-new-privileges:true")
+	}
+}
+
+func firstName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+func buildDockerImage(ctx context.Context, cli *client.Client, req *BuildRequest) error {
+	log := zerolog.Ctx(ctx)
+	out, opts := req.Output, req.Options
+	excludes, err := readDockerignore(req.Dir, opts.Dockerfile)
+	if err != nil {
+		return err
+	}
+	buildContext, err := archive.TarWithOptions(req.Dir, &archive.TarOptions{ExcludePatterns: excludes})
+	if err != nil {
+		return fmt.Errorf("failed to create tar archive: %w", err)
+	}
+	// BuildKit reads the secrets from a session the client attaches to the daemon
+	sess, err := session.NewSession(ctx, "githost")
+	if err != nil {
+		return fmt.Errorf("failed to create build session: %w", err)
+	}
+	sess.Allow(secretsprovider.FromMap(req.Secrets))
+	go func() {
+		err := sess.Run(ctx, func(ctx context.Context, proto string, meta map[string][]string) (net.Conn, error) {
+			return cli.DialHijack(ctx, "/session", proto, meta)
+		})
+		if err != nil {
+			log.Debug().Err(err).Msg("build session ended")
+		}
+	}()
+	defer sess.Close()
+
+	buildOptions := build.ImageBuildOptions{
+		Version:   build.BuilderBuildKit,
+		SessionID: sess.ID(),
+		Tags:      []string{imageTag(req.RepoName, req.CommitSHA), fmt.Sprintf("%s:latest", req.RepoName)},
+		Labels: map[string]string{
+			LabelEnabled: "true",
+			LabelRepo:    req.RepoName,
+			LabelCommit:  req.CommitSHA,
+		},
+		Dockerfile: opts.Dockerfile,
+		BuildArgs:  buildArgs(opts.Args),
+		Target:     opts.Target,
+		Platform:   opts.Platform,
+		Remove:     true,
+		NoCache:    req.NoCache,
+	}
+	resp, err := cli.ImageBuild(ctx, buildContext, buildOptions)
+	if err != nil {
+		return fmt.Errorf("failed to build image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	imageID, err := readBuildStream(ctx, resp.Body, out)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("image", imageID).Msg("built image successfully")
+	fmt.Fprintf(out, "Built image %s\n", imageID)
+
+	return nil
+}
+
+// readBuildStream renders the messages of a docker build stream to out and returns the ID of the
+// built image.
+func readBuildStream(ctx context.Context, r io.Reader, out io.Writer) (string, error) {
+	log := zerolog.Ctx(ctx)
+	imageID := ""
+	trace := &buildTrace{out: out, started: map[string]bool{}}
+	dec := json.NewDecoder(r)
+	for {
+		var jm jsonmessage.JSONMessage
+		if err := dec.Decode(&jm); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", fmt.Errorf("failed to decode json message: %w", err)
+		}
+		writeBuildMessage(out, &jm)
+		if stream := strings.TrimSpace(jm.Stream); stream != "" {
+			log.Debug().Msg(stream)
+		}
+		if jm.Error != nil {
+			return "", fmt.Errorf("build failed: %s", jm.Error.Message)
+		}
+		switch {
+		case jm.Aux == nil:
+		case jm.ID == buildkitTraceID:
+			if err := trace.write(*jm.Aux); err != nil {
+				return "", err
+			}
+		case jm.ID == buildkitImageID:
+			var result build.Result
+			if err := json.Unmarshal(*jm.Aux, &result); err != nil {
+				return "", fmt.Errorf("failed to unmarshal json message: %w", err)
+			}
+			imageID = result.ID
+		}
+	}
+	if imageID == "" {
+		return "", fmt.Errorf("failed to get image ID")
+	}
+	return imageID, nil
+}
+
+const (
+	// buildkitTraceID marks the messages of a BuildKit build carrying its progress.
+	buildkitTraceID = "moby.buildkit.trace"
+	// buildkitImageID marks the message of a BuildKit build carrying the ID of the built image.
+	buildkitImageID = "moby.image.id"
+)
+
+// buildTrace renders the progress of a BuildKit build as plain text lines: the steps as they
+// start, followed by their output and errors.
+type buildTrace struct {
+	out     io.Writer
+	started map[string]bool
+}
+
+// write renders a progress message, a JSON string of an encoded controlapi.StatusResponse.
+func (t *buildTrace) write(aux json.RawMessage) error {
+	var data []byte
+	if err := json.Unmarshal(aux, &data); err != nil {
+		return fmt.Errorf("failed to unmarshal build progress: %w", err)
+	}
+	var status controlapi.StatusResponse
+	if err := proto.Unmarshal(data, &status); err != nil {
+		return fmt.Errorf("failed to decode build progress: %w", err)
+	}
+	for _, v := range status.Vertexes {
+		if v.Started != nil && !t.started[v.Digest] {
+			t.started[v.Digest] = true
+			if v.Cached {
+				fmt.Fprintf(t.out, "=> CACHED %s\n", v.Name)
+			} else {
+				fmt.Fprintf(t.out, "=> %s\n", v.Name)
+			}
+		}
+		if v.Error != "" {
+			fmt.Fprintf(t.out, "ERROR: %s: %s\n", v.Name, v.Error)
+		}
+	}
+	for _, l := range status.Logs {
+		for line := range strings.Lines(string(l.Msg)) {
+			if line = strings.TrimRight(line, "\r\n"); strings.TrimSpace(line) != "" {
+				fmt.Fprintln(t.out, line)
+			}
+		}
+	}
+	for _, w := range status.Warnings {
+		fmt.Fprintf(t.out, "WARNING: %s\n", w.Short)
+	}
+	return nil
+}
+
+// readDockerignore returns the patterns of the .dockerignore at the root of the build context.
+func readDockerignore(dir, dockerfile string) ([]string, error) {
+	f, err := os.Open(filepath.Join(dir, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	excludes, err := ignorefile.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .dockerignore: %w", err)
+	}
+	// the daemon needs these even if they are ignored, like the docker CLI sends them anyway
+	return append(excludes, "!"+path.Clean(dockerfile), "!.dockerignore"), nil
+}
+
+func buildArgs(args map[string]string) map[string]*string {
+	result := make(map[string]*string, len(args))
+	for k, v := range args {
+		result[k] = &v
+	}
+	return result
+}
+
+// writeBuildMessage renders a message of the docker build stream as plain text lines.
+// Progress bars of layer downloads are skipped to keep the output readable.
+func writeBuildMessage(out io.Writer, jm *jsonmessage.JSONMessage) {
+	switch {
+	case jm.Error != nil:
+		fmt.Fprintf(out, "ERROR: %s\n", jm.Error.Message)
+	case jm.Stream != "":
+		for line := range strings.Lines(jm.Stream) {
+			if line = strings.TrimRight(line, "\r\n"); strings.TrimSpace(line) != "" {
+				fmt.Fprintln(out, line)
+			}
+		}
+	case jm.Status != "" && jm.Progress == nil:
+		if jm.ID != "" {
+			fmt.Fprintf(out, "%s: %s\n", jm.ID, jm.Status)
+		} else {
+			fmt.Fprintln(out, jm.Status)
+		}
+	}
+}
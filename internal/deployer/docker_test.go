@@ -0,0 +1,100 @@
+package deployer
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// traceMessage returns the build stream line carrying the BuildKit progress.
+func traceMessage(t *testing.T, status *controlapi.StatusResponse) string {
+	t.Helper()
+	data, err := proto.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the daemon sends the encoded progress as a JSON string
+	aux, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return `{"id":"moby.buildkit.trace","aux":` + string(aux) + `}`
+}
+
+func TestReadBuildStream(t *testing.T) {
+	started := timestamppb.New(time.Unix(1, 0))
+	from := &controlapi.Vertex{Digest: "sha256:1", Name: "[1/2] FROM docker.io/library/alpine:3", Started: started, Cached: true}
+	run := &controlapi.Vertex{Digest: "sha256:2", Name: "[2/2] RUN make", Started: started}
+	stream := strings.Join([]string{
+		traceMessage(t, &controlapi.StatusResponse{Vertexes: []*controlapi.Vertex{from, run}}),
+		// vertexes are sent again as they progress, their header is written once
+		traceMessage(t, &controlapi.StatusResponse{
+			Vertexes: []*controlapi.Vertex{run},
+			Logs:     []*controlapi.VertexLog{{Vertex: run.Digest, Msg: []byte("building\r\n\ndone\n")}},
+			Warnings: []*controlapi.VertexWarning{{Vertex: run.Digest, Short: []byte("FromAsCasing")}},
+		}),
+		`{"status":"Downloading","progressDetail":{"current":1,"total":2},"progress":"[=>  ]","id":"4abcf2066143"}`,
+		`{"status":"Pull complete","id":"4abcf2066143"}`,
+		`{"stream":"Successfully tagged alice/app:latest\n"}`,
+		`{"id":"moby.image.id","aux":{"ID":"sha256:abc"}}`,
+	}, "\n")
+
+	var out strings.Builder
+	imageID, err := readBuildStream(context.Background(), strings.NewReader(stream), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imageID != "sha256:abc" {
+		t.Fatalf("image ID = %q, want sha256:abc", imageID)
+	}
+	want := strings.Join([]string{
+		"=> CACHED [1/2] FROM docker.io/library/alpine:3",
+		"=> [2/2] RUN make",
+		"building",
+		"done",
+		"WARNING: FromAsCasing",
+		"4abcf2066143: Pull complete",
+		"Successfully tagged alice/app:latest",
+	}, "\n") + "\n"
+	if out.String() != want {
+		t.Fatalf("output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestReadBuildStreamErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, stream, wantErr, wantOut string
+	}{
+		{
+			name: "failed step",
+			stream: traceMessage(t, &controlapi.StatusResponse{Vertexes: []*controlapi.Vertex{{
+				Digest: "sha256:2", Name: "[2/2] RUN make", Started: timestamppb.New(time.Unix(1, 0)), Error: "exit code: 2",
+			}}}) + "\n" + `{"errorDetail":{"message":"process \"make\" did not complete successfully"},"error":"process \"make\" did not complete successfully"}`,
+			wantErr: `build failed: process "make" did not complete successfully`,
+			wantOut: "=> [2/2] RUN make\nERROR: [2/2] RUN make: exit code: 2\nERROR: process \"make\" did not complete successfully\n",
+		},
+		{
+			name:    "no image",
+			stream:  `{"stream":"Step 1/1 : FROM scratch\n"}`,
+			wantErr: "failed to get image ID",
+			wantOut: "Step 1/1 : FROM scratch\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out strings.Builder
+			_, err := readBuildStream(context.Background(), strings.NewReader(tc.stream), &out)
+			if err == nil || err.Error() != tc.wantErr {
+				t.Fatalf("error = %v, want %s", err, tc.wantErr)
+			}
+			if out.String() != tc.wantOut {
+				t.Fatalf("output:\n%s\nwant:\n%s", out.String(), tc.wantOut)
+			}
+		})
+	}
+}
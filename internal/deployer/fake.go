@@ -0,0 +1,246 @@
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// FakeRuntime is an in-memory Runtime for tests. Builds always succeed and instances start and
+// become healthy right away unless BuildFunc, StartFunc or StatusFunc say otherwise.
+type FakeRuntime struct {
+	// BuildFunc, if set, is called instead of building and may fail the build.
+	BuildFunc func(req *BuildRequest) error
+	// StartFunc, if set, is called before an instance is started and may fail the start.
+	StartFunc func(spec *InstanceSpec) error
+	// StatusFunc, if set, decides the status of a new instance instead of a healthy one.
+	StatusFunc func(spec *InstanceSpec) InstanceStatus
+	// LogsFunc, if set, writes the output of an instance, which has none otherwise.
+	LogsFunc func(inst *Instance, opts *LogsOptions, stdout, stderr io.Writer) error
+
+	mu        sync.Mutex
+	nextID    int
+	builds    int
+	images    map[string]*Image
+	instances map[string]*fakeInstance
+}
+
+type fakeInstance struct {
+	instance Instance
+	spec     InstanceSpec
+	status   InstanceStatus
+}
+
+var _ Runtime = (*FakeRuntime)(nil)
+
+func NewFakeRuntime() *FakeRuntime {
+	return &FakeRuntime{
+		images:    map[string]*Image{},
+		instances: map[string]*fakeInstance{},
+	}
+}
+
+// Build implements Runtime.
+func (r *FakeRuntime) Build(ctx context.Context, req *BuildRequest) error {
+	if r.BuildFunc != nil {
+		if err := r.BuildFunc(req); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// builds are a second apart so that the order of images is deterministic
+	r.builds++
+	r.images[imageTag(req.RepoName, req.CommitSHA)] = &Image{
+		RepoName:  req.RepoName,
+		CommitSHA: req.CommitSHA,
+		Size:      1 << 20,
+		Created:   time.Unix(int64(r.builds), 0),
+	}
+	if req.Output != nil {
+		fmt.Fprintf(req.Output, "Built image %s\n", imageTag(req.RepoName, req.CommitSHA))
+	}
+	return nil
+}
+
+// HasImage implements Runtime.
+func (r *FakeRuntime) HasImage(ctx context.Context, repoName, commitSHA string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.images[imageTag(repoName, commitSHA)] != nil, nil
+}
+
+// List implements Runtime.
+func (r *FakeRuntime) List(ctx context.Context, labels map[string]string) ([]*Instance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var instances []*Instance
+	for _, inst := range r.instances {
+		if hasLabels(inst.instance.Labels, labels) {
+			instances = append(instances, inst.copy())
+		}
+	}
+	slices.SortFunc(instances, func(a, b *Instance) int { return compareIDs(a.ID, b.ID) })
+	return instances, nil
+}
+
+// Start implements Runtime.
+func (r *FakeRuntime) Start(ctx context.Context, spec *InstanceSpec) (*Instance, error) {
+	if r.StartFunc != nil {
+		if err := r.StartFunc(spec); err != nil {
+			return nil, err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.images[imageTag(spec.RepoName, spec.CommitSHA)] == nil {
+		return nil, fmt.Errorf("%w: %s", ErrImageNotFound, imageTag(spec.RepoName, spec.CommitSHA))
+	}
+	for _, inst := range r.instances {
+		if inst.instance.Name == spec.Name {
+			return nil, fmt.Errorf("instance %s already exists", spec.Name)
+		}
+	}
+
+	r.nextID++
+	status := InstanceStatus{Running: true, Health: HealthHealthy, Addr: fmt.Sprintf("fake-%d:%d", r.nextID, spec.Port)}
+	if r.StatusFunc != nil {
+		status = r.StatusFunc(spec)
+	}
+	inst := &fakeInstance{
+		instance: Instance{ID: fmt.Sprintf("fake-%d", r.nextID), Name: spec.Name, Labels: maps.Clone(spec.Labels)},
+		spec:     *spec,
+		status:   status,
+	}
+	r.instances[inst.instance.ID] = inst
+	return inst.copy(), nil
+}
+
+// Status implements Runtime.
+func (r *FakeRuntime) Status(ctx context.Context, id string, port int) (*InstanceStatus, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	inst, ok := r.instances[id]
+	if !ok {
+		return nil, ErrContainerNotFound
+	}
+	status := inst.status
+	return &status, nil
+}
+
+// Stop implements Runtime.
+func (r *FakeRuntime) Stop(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.instances[id]; !ok {
+		return ErrContainerNotFound
+	}
+	delete(r.instances, id)
+	return nil
+}
+
+// Logs implements Runtime. Instances of the fake runtime have no output unless LogsFunc is set.
+func (r *FakeRuntime) Logs(ctx context.Context, id string, opts *LogsOptions, stdout, stderr io.Writer) error {
+	r.mu.Lock()
+	inst, ok := r.instances[id]
+	if !ok {
+		r.mu.Unlock()
+		return ErrContainerNotFound
+	}
+	copied := inst.copy()
+	r.mu.Unlock()
+	if r.LogsFunc == nil {
+		return nil
+	}
+	return r.LogsFunc(copied, opts, stdout, stderr)
+}
+
+// Images implements Runtime.
+func (r *FakeRuntime) Images(ctx context.Context) ([]*Image, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	images := make([]*Image, 0, len(r.images))
+	for _, image := range r.images {
+		copied := *image
+		images = append(images, &copied)
+	}
+	slices.SortFunc(images, func(a, b *Image) int { return b.Created.Compare(a.Created) })
+	return images, nil
+}
+
+// RemoveImage implements Runtime.
+func (r *FakeRuntime) RemoveImage(ctx context.Context, repoName, commitSHA string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tag := imageTag(repoName, commitSHA)
+	if r.images[tag] == nil {
+		return fmt.Errorf("%w: %s", ErrImageNotFound, tag)
+	}
+	for _, inst := range r.instances {
+		if inst.spec.RepoName == repoName && inst.spec.CommitSHA == commitSHA {
+			return fmt.Errorf("image %s is used by instance %s", tag, inst.instance.Name)
+		}
+	}
+	delete(r.images, tag)
+	return nil
+}
+
+// RenameImages implements Runtime.
+func (r *FakeRuntime) RenameImages(ctx context.Context, from, to string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for tag, image := range r.images {
+		if image.RepoName != from {
+			continue
+		}
+		delete(r.images, tag)
+		image.RepoName = to
+		r.images[imageTag(to, image.CommitSHA)] = image
+	}
+	return nil
+}
+
+// Exit marks the instance as exited, like an app that crashed.
+func (r *FakeRuntime) Exit(id string, exitCode int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if inst, ok := r.instances[id]; ok {
+		inst.status = InstanceStatus{ExitCode: exitCode}
+	}
+}
+
+// Spec returns the spec the instance was started with.
+func (r *FakeRuntime) Spec(id string) (InstanceSpec, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	inst, ok := r.instances[id]
+	if !ok {
+		return InstanceSpec{}, false
+	}
+	return inst.spec, true
+}
+
+func (i *fakeInstance) copy() *Instance {
+	return &Instance{ID: i.instance.ID, Name: i.instance.Name, Labels: maps.Clone(i.instance.Labels)}
+}
+
+// compareIDs orders the IDs of the fake runtime by the order the instances were started.
+func compareIDs(a, b string) int {
+	var na, nb int
+	fmt.Sscanf(a, "fake-%d", &na)
+	fmt.Sscanf(b, "fake-%d", &nb)
+	return na - nb
+}
+
+func hasLabels(have, want map[string]string) bool {
+	for k, v := range want {
+		if have[k] != v {
+			return false
+		}
+	}
+	return true
+}
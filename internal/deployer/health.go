@@ -0,0 +1,93 @@
+package deployer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/yz4230/githost-poc/internal/config"
+)
+
+var ErrNoEndpoint = errors.New("container has no reachable port")
+
+const (
+	healthCheckInterval = time.Second
+	// healthCheckGrace is how long a container without any health check has to keep running.
+	healthCheckGrace          = 5 * time.Second
+	defaultHealthCheckTimeout = time.Minute
+)
+
+var ErrUnhealthy = errors.New("container is unhealthy")
+
+// waitHealthy waits until the instance passes its health check. The health check of the image
+// takes precedence over the HTTP probe; without either, the instance only has to keep running for
+// a short grace period. An instance that exits or restarts fails immediately.
+func waitHealthy(ctx context.Context, runtime Runtime, id string, health config.HealthCheckConfig) error {
+	timeout := health.Timeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	httpClient := &http.Client{Timeout: healthCheckInterval}
+	var lastErr error
+	for {
+		status, err := runtime.Status(ctx, id, health.Port)
+		if err != nil {
+			return fmt.Errorf("failed to inspect container: %w", err)
+		}
+		if !status.Running || status.Restarted {
+			return fmt.Errorf("%w: exited with code %d", ErrUnhealthy, status.ExitCode)
+		}
+
+		switch {
+		case status.Health != HealthNone:
+			switch status.Health {
+			case HealthHealthy:
+				return nil
+			case HealthUnhealthy:
+				return fmt.Errorf("%w: HEALTHCHECK failed", ErrUnhealthy)
+			}
+		case health.Path != "":
+			if status.Addr == "" {
+				return fmt.Errorf("%w: %w", ErrUnhealthy, ErrNoEndpoint)
+			}
+			if lastErr = probeHTTP(ctx, httpClient, "http://"+status.Addr+health.Path); lastErr == nil {
+				return nil
+			}
+		default:
+			if time.Since(started) >= healthCheckGrace {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("%w: %w", ErrUnhealthy, lastErr)
+			}
+			return fmt.Errorf("%w: %w", ErrUnhealthy, ctx.Err())
+		case <-time.After(healthCheckInterval):
+		}
+	}
+}
+
+func probeHTTP(ctx context.Context, httpClient *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return nil
+}
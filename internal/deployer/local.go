@@ -0,0 +1,481 @@
+package deployer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// localStopTimeout is how long a process has to exit after SIGTERM before it is killed.
+	localStopTimeout = 10 * time.Second
+	localLogInterval = 500 * time.Millisecond
+)
+
+// localRuntime runs the web process of the Procfile of a commit directly on the host, for machines
+// without docker. A build is a copy of the commit tree. Resource limits, security options and
+// restart policies are not applied, logs cannot be filtered by time, and the processes are
+// stopped with the server: instances do not survive a restart.
+type localRuntime struct {
+	dir string
+
+	mu        sync.Mutex
+	nextID    int
+	instances map[string]*localProcess
+}
+
+type localProcess struct {
+	instance Instance
+	port     int
+	logDir   string
+	cmd      *exec.Cmd
+	// done is closed once the process exited and exitCode is set.
+	done     chan struct{}
+	exitCode int
+}
+
+// localEnvAllowlist lists the variables of the server that apps inherit. Everything else stays
+// with the server, in particular the master key and the other GITHOST_ variables.
+var localEnvAllowlist = []string{"PATH", "HOME", "LANG", "TMPDIR"}
+
+// localEnv returns the variables of the server that apps inherit, see localEnvAllowlist.
+func localEnv() []string {
+	var env []string
+	for _, name := range localEnvAllowlist {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// NewLocalRuntime returns the runtime keeping builds and logs of processes under dir.
+func NewLocalRuntime(dir string) Runtime {
+	return &localRuntime{dir: dir, instances: map[string]*localProcess{}}
+}
+
+// Build implements Runtime.
+func (r *localRuntime) Build(ctx context.Context, req *BuildRequest) error {
+	command, err := readProcfile(req.Dir)
+	if err != nil {
+		return err
+	}
+	dst := r.buildDir(req.RepoName, req.CommitSHA)
+	if err := os.RemoveAll(dst); err != nil {
+		return fmt.Errorf("failed to remove previous build: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create build directory: %w", err)
+	}
+	if err := os.CopyFS(dst, os.DirFS(req.Dir)); err != nil {
+		return fmt.Errorf("failed to copy commit tree: %w", err)
+	}
+	if req.Output != nil {
+		fmt.Fprintf(req.Output, "Built %s:%s, web process: %s\n", req.RepoName, req.CommitSHA, command)
+	}
+	return nil
+}
+
+// HasImage implements Runtime.
+func (r *localRuntime) HasImage(ctx context.Context, repoName, commitSHA string) (bool, error) {
+	_, err := os.Stat(filepath.Join(r.buildDir(repoName, commitSHA), "Procfile"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// List implements Runtime.
+func (r *localRuntime) List(ctx context.Context, labels map[string]string) ([]*Instance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var instances []*Instance
+	for _, p := range r.instances {
+		if hasLabels(p.instance.Labels, labels) {
+			instances = append(instances, &Instance{ID: p.instance.ID, Name: p.instance.Name, Labels: maps.Clone(p.instance.Labels)})
+		}
+	}
+	return instances, nil
+}
+
+// Start implements Runtime.
+func (r *localRuntime) Start(ctx context.Context, spec *InstanceSpec) (*Instance, error) {
+	dir := r.buildDir(spec.RepoName, spec.CommitSHA)
+	command, err := readProcfile(dir)
+	if errors.Is(err, ErrNoProcfile) {
+		return nil, fmt.Errorf("%w: %s", ErrImageNotFound, imageTag(spec.RepoName, spec.CommitSHA))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	port := spec.Port
+	if port == 0 {
+		if port, err = freePort(); err != nil {
+			return nil, err
+		}
+	}
+	logDir := filepath.Join(r.dir, "instances", spec.Name)
+	if err := os.MkdirAll(logDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	stdout, err := os.Create(filepath.Join(logDir, "stdout.log"))
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := os.Create(filepath.Join(logDir, "stderr.log"))
+	if err != nil {
+		stdout.Close()
+		return nil, err
+	}
+
+	// exec replaces the shell so that signals reach the app itself
+	cmd := exec.Command("sh", "-c", "exec "+command)
+	cmd.Dir = dir
+	cmd.Env = append(append(localEnv(), spec.Env...), "PORT="+strconv.Itoa(port))
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		stdout.Close()
+		stderr.Close()
+		os.RemoveAll(logDir)
+		return nil, fmt.Errorf("failed to start %q: %w", command, err)
+	}
+
+	r.mu.Lock()
+	r.nextID++
+	p := &localProcess{
+		instance: Instance{ID: strconv.Itoa(r.nextID), Name: spec.Name, Labels: maps.Clone(spec.Labels)},
+		port:     port,
+		logDir:   logDir,
+		cmd:      cmd,
+		done:     make(chan struct{}),
+	}
+	r.instances[p.instance.ID] = p
+	r.mu.Unlock()
+
+	go func() {
+		err := cmd.Wait()
+		stdout.Close()
+		stderr.Close()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			p.exitCode = exitErr.ExitCode()
+		}
+		close(p.done)
+	}()
+
+	return &Instance{ID: p.instance.ID, Name: p.instance.Name, Labels: maps.Clone(p.instance.Labels)}, nil
+}
+
+// Status implements Runtime. The port is ignored, the app listens on the port passed in $PORT.
+func (r *localRuntime) Status(ctx context.Context, id string, port int) (*InstanceStatus, error) {
+	p, err := r.get(id)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-p.done:
+		return &InstanceStatus{ExitCode: p.exitCode, Health: HealthNone}, nil
+	default:
+		return &InstanceStatus{
+			Running: true,
+			Health:  HealthNone,
+			Addr:    net.JoinHostPort("127.0.0.1", strconv.Itoa(p.port)),
+		}, nil
+	}
+}
+
+// Stop implements Runtime.
+func (r *localRuntime) Stop(ctx context.Context, id string) error {
+	p, err := r.get(id)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-p.done:
+	default:
+		_ = p.cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-p.done:
+		case <-time.After(localStopTimeout):
+			_ = p.cmd.Process.Kill()
+			<-p.done
+		}
+	}
+
+	r.mu.Lock()
+	delete(r.instances, id)
+	r.mu.Unlock()
+	return os.RemoveAll(p.logDir)
+}
+
+// Logs implements Runtime. With Follow it returns once the process exited and its output is copied.
+func (r *localRuntime) Logs(ctx context.Context, id string, opts *LogsOptions, stdout, stderr io.Writer) error {
+	p, err := r.get(id)
+	if err != nil {
+		return err
+	}
+	tail := -1
+	if opts.Tail != "" && opts.Tail != "all" {
+		if tail, err = strconv.Atoi(opts.Tail); err != nil || tail < 0 {
+			return fmt.Errorf("invalid tail %q", opts.Tail)
+		}
+	}
+
+	// both streams share the writers of the caller, which need not be safe for concurrent use
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, name := range []string{"stdout.log", "stderr.log"} {
+		w := &lockedWriter{mu: &mu, w: []io.Writer{stdout, stderr}[i]}
+		wg.Go(func() {
+			errs[i] = copyLogFile(ctx, filepath.Join(p.logDir, name), tail, opts.Follow, p.done, w)
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Images implements Runtime.
+func (r *localRuntime) Images(ctx context.Context) ([]*Image, error) {
+	repos, err := os.ReadDir(filepath.Join(r.dir, "builds"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var images []*Image
+	for _, repo := range repos {
+		repoName, err := url.PathUnescape(repo.Name())
+		if err != nil {
+			continue
+		}
+		builds, err := os.ReadDir(r.repoDir(repoName))
+		if err != nil {
+			return nil, err
+		}
+		for _, build := range builds {
+			info, err := build.Info()
+			if err != nil {
+				return nil, err
+			}
+			size, err := dirSize(r.buildDir(repoName, build.Name()))
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, &Image{RepoName: repoName, CommitSHA: build.Name(), Size: size, Created: info.ModTime()})
+		}
+	}
+	slices.SortStableFunc(images, func(a, b *Image) int { return b.Created.Compare(a.Created) })
+	return images, nil
+}
+
+// RemoveImage implements Runtime.
+func (r *localRuntime) RemoveImage(ctx context.Context, repoName, commitSHA string) error {
+	if ok, err := r.HasImage(ctx, repoName, commitSHA); err != nil || !ok {
+		return fmt.Errorf("%w: %s", ErrImageNotFound, imageTag(repoName, commitSHA))
+	}
+	r.mu.Lock()
+	for _, p := range r.instances {
+		if p.instance.Labels[LabelRepo] == repoName && p.instance.Labels[LabelCommit] == commitSHA {
+			r.mu.Unlock()
+			return fmt.Errorf("build %s is used by instance %s", imageTag(repoName, commitSHA), p.instance.Name)
+		}
+	}
+	r.mu.Unlock()
+	return os.RemoveAll(r.buildDir(repoName, commitSHA))
+}
+
+// RenameImages implements Runtime. Running processes keep their working directory when it moves.
+func (r *localRuntime) RenameImages(ctx context.Context, from, to string) error {
+	builds, err := os.ReadDir(r.repoDir(from))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.repoDir(to), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create build directory: %w", err)
+	}
+	for _, build := range builds {
+		src, dst := r.buildDir(from, build.Name()), r.buildDir(to, build.Name())
+		if ok, err := r.HasImage(ctx, to, build.Name()); err != nil || ok {
+			// the commit was built under the new name before, that build wins
+			continue
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return fmt.Errorf("failed to move build: %w", err)
+		}
+	}
+	return os.RemoveAll(r.repoDir(from))
+}
+
+// Shutdown stops every process, they would be orphaned once the server exits.
+func (r *localRuntime) Shutdown() error {
+	r.mu.Lock()
+	ids := make([]string, 0, len(r.instances))
+	for id := range r.instances {
+		ids = append(ids, id)
+	}
+	r.mu.Unlock()
+
+	var errs []error
+	for _, id := range ids {
+		errs = append(errs, r.Stop(context.Background(), id))
+	}
+	return errors.Join(errs...)
+}
+
+func (r *localRuntime) get(id string) (*localProcess, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.instances[id]
+	if !ok {
+		return nil, ErrContainerNotFound
+	}
+	return p, nil
+}
+
+// repoDir returns the directory of the builds of the repository. The slash of the full name is
+// escaped to keep one directory per repository.
+func (r *localRuntime) repoDir(repoName string) string {
+	return filepath.Join(r.dir, "builds", url.PathEscape(repoName))
+}
+
+func (r *localRuntime) buildDir(repoName, commitSHA string) string {
+	return filepath.Join(r.repoDir(repoName), commitSHA)
+}
+
+// readProcfile returns the command of the web process in the Procfile of dir.
+func readProcfile(dir string) (string, error) {
+	f, err := os.Open(filepath.Join(dir, "Procfile"))
+	if os.IsNotExist(err) {
+		return "", ErrNoProcfile
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		name, command, ok := strings.Cut(s.Text(), ":")
+		if ok && strings.TrimSpace(name) == "web" && strings.TrimSpace(command) != "" {
+			return strings.TrimSpace(command), nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", ErrNoProcfile
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// copyLogFile writes the last tail lines of the file (all of it if tail is negative) to w. With
+// follow it keeps copying what is appended until done is closed or ctx is cancelled.
+func copyLogFile(ctx context.Context, path string, tail int, follow bool, done <-chan struct{}, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(lastLines(data, tail)); err != nil || !follow {
+		return err
+	}
+
+	for {
+		exited := false
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-done:
+			exited = true
+		case <-time.After(localLogInterval):
+		}
+		if _, err := io.Copy(w, f); err != nil {
+			return err
+		}
+		if exited {
+			return nil
+		}
+	}
+}
+
+func lastLines(data []byte, n int) []byte {
+	if n < 0 {
+		return data
+	}
+	if n == 0 {
+		return nil
+	}
+	end := len(data)
+	if end > 0 && data[end-1] == '\n' {
+		end--
+	}
+	for i := end - 1; i >= 0; i-- {
+		if data[i] == '\n' {
+			if n--; n == 0 {
+				return data[i+1:]
+			}
+		}
+	}
+	return data
+}
+
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
@@ -0,0 +1,137 @@
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/config"
+	"github.com/yz4230/githost-poc/internal/deployconfig"
+	"github.com/yz4230/githost-poc/internal/entity"
+)
+
+// Labels identifying the instances of deployed apps. Every runtime stores them with its instances.
+const (
+	LabelEnabled    = "githost.enabled"
+	LabelRepo       = "githost.repo"
+	LabelCommit     = "githost.commit"
+	LabelDeployment = "githost.deployment"
+	LabelPort       = "githost.port"
+	LabelBranch     = "githost.branch"
+	// LabelPreview holds the name of the preview environment, it is not set on the deployed app.
+	LabelPreview = "githost.preview"
+)
+
+// Health states reported by a runtime for an instance with its own health check.
+const (
+	HealthNone      = ""
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// Runtime builds images of commits and runs them as instances. The deployer uses it to
+// replace the instances of a repository without knowing how they are run.
+type Runtime interface {
+	// Build builds the image of the exported commit in req.Dir.
+	Build(ctx context.Context, req *BuildRequest) error
+	// HasImage reports whether the image of the commit was built before.
+	HasImage(ctx context.Context, repoName, commitSHA string) (bool, error)
+	// List returns the instances, running or not, that carry all of the labels.
+	List(ctx context.Context, labels map[string]string) ([]*Instance, error)
+	// Start creates and starts an instance. A failed start leaves no instance behind.
+	Start(ctx context.Context, spec *InstanceSpec) (*Instance, error)
+	// Status reports the state of the instance, including its address on port (zero for the default port).
+	Status(ctx context.Context, id string, port int) (*InstanceStatus, error)
+	// Stop stops and removes the instance.
+	Stop(ctx context.Context, id string) error
+	// Logs copies the output of the instance to stdout and stderr.
+	Logs(ctx context.Context, id string, opts *LogsOptions, stdout, stderr io.Writer) error
+	// Images returns the images built for the repositories, newest first.
+	Images(ctx context.Context) ([]*Image, error)
+	// RemoveImage removes the image of the commit. It fails if an instance still uses it.
+	RemoveImage(ctx context.Context, repoName, commitSHA string) error
+	// RenameImages moves the images of a repository to its new name. Instances keep running
+	// from the images they were started with.
+	RenameImages(ctx context.Context, from, to string) error
+}
+
+type BuildRequest struct {
+	// Dir contains the tree of the commit.
+	Dir       string
+	RepoName  string
+	CommitSHA string
+	Options   *deployconfig.Build
+	// NoCache rebuilds every step instead of reusing the cache of earlier builds.
+	NoCache bool
+	// Secrets are mounted by RUN --mount=type=secret,id=<name> of the Dockerfile. They must never
+	// be logged.
+	Secrets map[string][]byte
+	// Output receives the human readable build progress.
+	Output io.Writer
+}
+
+type InstanceSpec struct {
+	Name      string
+	RepoName  string
+	CommitSHA string
+	Labels    map[string]string
+	Env       []string
+	// Port is the port the app listens on, zero to use the default of the image.
+	Port    int
+	Restart string
+	Limits  entity.ContainerLimits
+}
+
+type Instance struct {
+	ID     string
+	Name   string
+	Labels map[string]string
+}
+
+type InstanceStatus struct {
+	Running bool
+	// Restarted is set if the instance crashed and was restarted since it was started.
+	Restarted bool
+	ExitCode  int
+	// Health is the state of the health check of the image, HealthNone if it has none.
+	Health string
+	// Addr is the host:port the server reaches the app at, empty if it is not reachable.
+	Addr string
+}
+
+type Image struct {
+	RepoName  string
+	CommitSHA string
+	// Size is the disk space of the image, including layers it may share with other images.
+	Size    int64
+	Created time.Time
+}
+
+type LogsOptions struct {
+	Tail   string
+	Since  string
+	Follow bool
+}
+
+// Names of the runtimes selectable in the server config.
+const (
+	RuntimeDocker = "docker"
+	RuntimeLocal  = "local"
+)
+
+// NewRuntime returns the runtime selected by the server config.
+func NewRuntime(i *do.Injector) (Runtime, error) {
+	config := do.MustInvoke[*config.Config](i)
+	switch config.Runtime {
+	case "", RuntimeDocker:
+		return NewDockerRuntime()
+	case RuntimeLocal:
+		return NewLocalRuntime(filepath.Join(config.Root, "runtime")), nil
+	default:
+		return nil, fmt.Errorf("unknown runtime %q", config.Runtime)
+	}
+}
@@ -0,0 +1,31 @@
+package entity
+
+import "time"
+
+type Commit struct {
+	SHA       string    `json:"sha"`
+	Parents   []string  `json:"parents"`
+	Author    Signature `json:"author"`
+	Committer Signature `json:"committer"`
+	Message   string    `json:"message"`
+	// Stats are only read when requested. Merge commits are compared to their first parent.
+	Stats *CommitStats `json:"stats,omitempty"`
+	// Diff is the patch of the commit, only read for a single commit.
+	Diff string `json:"diff,omitempty"`
+	// DiffTruncated is set if the patch is too large to be returned in full.
+	DiffTruncated bool `json:"diff_truncated,omitempty"`
+}
+
+// CommitStats counts the changed lines and files of a commit. Lines of binary files are not counted.
+type CommitStats struct {
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+	Files     int `json:"files"`
+}
+
+// Signature is the author or committer of a commit.
+type Signature struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
@@ -14,9 +14,25 @@ const (
 type Deployment struct {
 	ID        ID               `json:"id"`
 	RepoID    ID               `json:"repo_id"`
+	Branch    string           `json:"branch"`
 	CommitSHA string           `json:"commit_sha"`
 	Status    DeploymentStatus `json:"status"`
 	IsActive  bool             `json:"is_active"`
+	// RollbackOf is the deployment whose image is relaunched, empty for deployments that build.
+	RollbackOf ID `json:"rollback_of,omitempty"`
+	// Clean builds the image without the build cache.
+	Clean bool `json:"clean,omitempty"`
+	// Preview is set for deployments of branches other than the deploy branch. They run next to
+	// the deployed app, as the preview environment of their branch.
+	Preview bool `json:"preview,omitempty"`
+	// Limits are resolved when the deployment runs, nil while it is queued.
+	Limits    *ContainerLimits `json:"limits,omitempty"`
 	CreatedAt time.Time        `json:"created_at"`
 	UpdatedAt time.Time        `json:"updated_at"`
 }
+
+// IsTeardown reports whether the deployment removes the preview environment of a deleted branch
+// instead of deploying a commit.
+func (d *Deployment) IsTeardown() bool {
+	return d.CommitSHA == ZeroSHA
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"regexp"
+	"time"
+)
+
+var envVarNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// EnvVar is an environment variable passed to the containers of a repository.
+// Values are encrypted at rest, and the values of secrets are never returned by the API.
+type EnvVar struct {
+	ID        ID        `json:"id"`
+	RepoID    ID        `json:"repo_id"`
+	Name      string    `json:"name"`
+	Value     string    `json:"value,omitempty"`
+	Secret    bool      `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsValidEnvVarName reports whether the name can be used as an environment variable.
+func IsValidEnvVarName(name string) bool {
+	return envVarNamePattern.MatchString(name)
+}
+
+// Redacted returns a copy that is safe to show, without the value if it is a secret.
+func (e *EnvVar) Redacted() *EnvVar {
+	redacted := *e
+	if redacted.Secret {
+		redacted.Value = ""
+	}
+	return &redacted
+}
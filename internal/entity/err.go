@@ -3,9 +3,13 @@ package entity
 import "errors"
 
 var (
-	ErrNotFound  = errors.New("not found")
-	ErrInvalid   = errors.New("invalid entity")
-	ErrConflict  = errors.New("conflict")
-	ErrForbidden = errors.New("forbidden")
-	ErrInternal  = errors.New("internal error")
+	ErrNotFound      = errors.New("not found")
+	ErrInvalid       = errors.New("invalid entity")
+	ErrConflict      = errors.New("conflict")
+	ErrUnauthorized  = errors.New("unauthorized")
+	ErrForbidden     = errors.New("forbidden")
+	ErrUnavailable   = errors.New("unavailable")
+	ErrLimitExceeded = errors.New("limit exceeded")
+	ErrDisabled      = errors.New("disabled")
+	ErrInternal      = errors.New("internal error")
 )
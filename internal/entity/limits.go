@@ -0,0 +1,12 @@
+package entity
+
+// ContainerLimits are the resource limits and security options a deployment's container runs with.
+// Zero values mean no limit.
+type ContainerLimits struct {
+	MemoryBytes     int64    `json:"memory_bytes"`
+	CPUs            float64  `json:"cpus"`
+	PidsLimit       int64    `json:"pids_limit"`
+	ReadOnlyRootfs  bool     `json:"read_only_rootfs"`
+	CapDrop         []string `json:"cap_drop"`
+	NoNewPrivileges bool     `json:"no_new_privileges"`
+}
@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+// Organization owns repositories like a user does, users and organizations share one namespace.
+type Organization struct {
+	ID          ID        `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type OrganizationRole string
+
+const (
+	// OrganizationRoleAdmin manages the organization and has full access to its repositories.
+	OrganizationRoleAdmin OrganizationRole = "admin"
+)
+
+// OrganizationMember is the role of a user in an organization.
+type OrganizationMember struct {
+	OrgID  ID               `json:"org_id"`
+	UserID ID               `json:"user_id"`
+	Role   OrganizationRole `json:"role"`
+}
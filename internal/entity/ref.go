@@ -0,0 +1,24 @@
+package entity
+
+type RefType string
+
+const (
+	RefTypeBranch RefType = "branch"
+	RefTypeTag    RefType = "tag"
+)
+
+// Prefix returns the namespace of the refs of the type, e.g. "refs/heads/".
+func (t RefType) Prefix() string {
+	if t == RefTypeTag {
+		return "refs/tags/"
+	}
+	return "refs/heads/"
+}
+
+// Ref is a branch or tag of a repository.
+type Ref struct {
+	Name string `json:"name"`
+	// SHA is the commit the ref points to, annotated tags are peeled to their commit.
+	SHA    string    `json:"sha"`
+	Author Signature `json:"author"`
+}
@@ -1,17 +1,60 @@
 package entity
 
 import (
+	"strings"
 	"time"
 )
 
+// ZeroSHA is the object name git uses for a ref that does not exist, e.g. a deleted branch.
+const ZeroSHA = "0000000000000000000000000000000000000000"
+
 type Repository struct {
-	ID           ID        `json:"id"`
-	Name         string    `json:"name"`
-	Description  string    `json:"description"`
-	DeployBranch string    `json:"deploy_branch"`
-	LatestSHA    string    `json:"latest_sha"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID ID `json:"id"`
+	// Owner is the name of the user or organization the repository belongs to. It is empty for
+	// repositories created before owners existed, until they are migrated to one.
+	Owner        string `json:"owner"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	DeployBranch string `json:"deploy_branch"`
+	LatestSHA    string `json:"latest_sha"`
+	// Host is the host name the reverse proxy routes to the app. Empty uses <name>.<owner>.<apps domain>.
+	Host string `json:"host"`
+	// MaxPreviews caps the number of preview environments of branches. Nil uses the server default.
+	MaxPreviews *int      `json:"max_previews"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// DeletedAt is set once the repository is deleted, until it is restored or purged.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// RepositoryRedirect keeps a former full name of a renamed repository working.
+type RepositoryRedirect struct {
+	Name      string    `json:"name"`
+	RepoID    ID        `json:"repo_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FullName returns <owner>/<name>, the name the repository is looked up, stored and deployed by.
+// It is just the name for repositories without an owner.
+func (r *Repository) FullName() string {
+	return JoinFullName(r.Owner, r.Name)
+}
+
+// JoinFullName returns the full name of the repository of the owner.
+func JoinFullName(owner, name string) string {
+	if owner == "" {
+		return name
+	}
+	return owner + "/" + name
+}
+
+// SplitFullName splits a full name into the owner and the name of the repository. The owner is
+// empty if the full name has none.
+func SplitFullName(fullName string) (owner, name string) {
+	if i := strings.Index(fullName, "/"); i >= 0 {
+		return fullName[:i], fullName[i+1:]
+	}
+	return "", fullName
 }
 
 func (r *Repository) FillDefaults() {
@@ -19,6 +62,53 @@ func (r *Repository) FillDefaults() {
 		r.DeployBranch = "main"
 	}
 	if r.LatestSHA == "" {
-		r.LatestSHA = "0000000000000000000000000000000000000000" // 40 zeros
+		r.LatestSHA = ZeroSHA
+	}
+}
+
+// DeployRef returns the full ref name of the branch that gets deployed.
+func (r *Repository) DeployRef() string {
+	return "refs/heads/" + r.DeployBranch
+}
+
+// PreviewLimit returns the number of previews the repository may have, given the server default.
+// Zero means previews are disabled.
+func (r *Repository) PreviewLimit(serverDefault int) int {
+	if r.MaxPreviews != nil {
+		return *r.MaxPreviews
+	}
+	return serverDefault
+}
+
+// AppHost returns the host name the reverse proxy routes to the deployed app.
+func (r *Repository) AppHost(domain string) string {
+	if r.Host != "" {
+		return r.Host
+	}
+	return r.Name + "." + r.domain(domain)
+}
+
+// PreviewName returns the name of the preview environment of the branch, <repo>-<branch> with
+// everything but letters, digits and dashes replaced by dashes, e.g. app-feature-login.
+func (r *Repository) PreviewName(branch string) string {
+	slug := strings.Map(func(r rune) rune {
+		if ('a' <= r && r <= 'z') || ('0' <= r && r <= '9') || r == '-' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(branch))
+	return r.Name + "-" + strings.Trim(slug, "-")
+}
+
+// PreviewHost returns the host name the reverse proxy routes to the preview of the branch.
+func (r *Repository) PreviewHost(branch, domain string) string {
+	return r.PreviewName(branch) + "." + r.domain(domain)
+}
+
+// domain returns the domain the hosts of the repository are under, <owner>.<apps domain>.
+func (r *Repository) domain(domain string) string {
+	if r.Owner == "" {
+		return domain
 	}
+	return r.Owner + "." + domain
 }
@@ -0,0 +1,84 @@
+package entity
+
+import (
+	"slices"
+	"time"
+)
+
+type User struct {
+	ID           ID        `json:"id"`
+	Name         string    `json:"name"`
+	PasswordHash string    `json:"-"`
+	IsAdmin      bool      `json:"is_admin"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type Access string
+
+const (
+	AccessNone  Access = ""
+	AccessRead  Access = "read"
+	AccessWrite Access = "write"
+)
+
+// Allows reports whether a grant of a covers the required access.
+func (a Access) Allows(required Access) bool {
+	switch required {
+	case AccessNone:
+		return true
+	case AccessRead:
+		return a == AccessRead || a == AccessWrite
+	case AccessWrite:
+		return a == AccessWrite
+	}
+	return false
+}
+
+func (a Access) IsValid() bool {
+	return a == AccessRead || a == AccessWrite
+}
+
+type RepositoryPermission struct {
+	RepoID ID     `json:"repo_id"`
+	UserID ID     `json:"user_id"`
+	Access Access `json:"access"`
+}
+
+type TokenScope string
+
+const (
+	TokenScopeRepoRead  TokenScope = "repo:read"
+	TokenScopeRepoWrite TokenScope = "repo:write"
+)
+
+func (s TokenScope) IsValid() bool {
+	return s == TokenScopeRepoRead || s == TokenScopeRepoWrite
+}
+
+type AccessToken struct {
+	ID        ID           `json:"id"`
+	UserID    ID           `json:"user_id"`
+	Name      string       `json:"name"`
+	TokenHash string       `json:"-"`
+	Scopes    []TokenScope `json:"scopes"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+func (t *AccessToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// Allows reports whether the token scopes permit the required repository access.
+func (t *AccessToken) Allows(required Access) bool {
+	switch required {
+	case AccessNone:
+		return true
+	case AccessRead:
+		return slices.Contains(t.Scopes, TokenScopeRepoRead) || slices.Contains(t.Scopes, TokenScopeRepoWrite)
+	case AccessWrite:
+		return slices.Contains(t.Scopes, TokenScopeRepoWrite)
+	}
+	return false
+}
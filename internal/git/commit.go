@@ -0,0 +1,71 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/moby/go-archive"
+)
+
+// ResolveCommit resolves rev (a SHA, branch or tag) to the full SHA of a commit in the repository.
+func ResolveCommit(ctx context.Context, repoPath, rev string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "--git-dir", repoPath, "rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("resolve %s: %w", rev, err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// IsValidBranchName reports whether name can be used as a branch name.
+func IsValidBranchName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "-") {
+		return false
+	}
+	return exec.Command("git", "check-ref-format", "refs/heads/"+name).Run() == nil
+}
+
+// ExportCommit extracts the tree of the commit into dir, without any git metadata.
+func ExportCommit(ctx context.Context, repoPath, commitSHA, dir string) error {
+	cmd := exec.CommandContext(ctx, "git", "--git-dir", repoPath, "archive", "--format=tar", commitSHA)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start git archive: %w", err)
+	}
+	if err := archive.Untar(stdout, dir, &archive.TarOptions{NoLchown: true}); err != nil {
+		_ = cmd.Wait()
+		return fmt.Errorf("extract archive: %w", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("git archive: %w: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// ReadFile returns the content of the file at path in the tree of the commit.
+// It returns os.ErrNotExist if the commit has no such file.
+func ReadFile(ctx context.Context, repoPath, commitSHA, path string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "--git-dir", repoPath, "cat-file", "blob", commitSHA+":"+path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// tell a missing file apart from other failures, e.g. an unknown commit
+		if exec.CommandContext(ctx, "git", "--git-dir", repoPath, "cat-file", "-e", commitSHA+"^{commit}").Run() == nil {
+			return nil, fmt.Errorf("read %s at %s: %w", path, commitSHA, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("read %s at %s: %w: %s", path, commitSHA, err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,168 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yz4230/githost-poc/internal/entity"
+)
+
+// LogOptions selects the commits Reader.Log returns.
+type LogOptions struct {
+	// From is the commit whose history is read.
+	From string
+	// Skip leaves out the first commits, Max limits the number of commits if it is not zero.
+	Skip int
+	Max  int
+	// Path limits the history to the commits that change the file or directory.
+	Path string
+	// Since and Until limit the commit dates, zero means no limit.
+	Since time.Time
+	Until time.Time
+	// Author limits the history to the commits whose author name or email contains it, ignoring case.
+	Author string
+	// Stats reads the stats of the commits.
+	Stats bool
+}
+
+// commitFormat starts every commit with a record separator and prints the fields parseCommit
+// reads separated by NUL. With --numstat, the stats follow the last NUL.
+const commitFormat = "%x1e%H%x00%P%x00%an%x00%ae%x00%at%x00%cn%x00%ce%x00%ct%x00%B%x00"
+
+// Log implements Reader.
+func (r *readerImpl) Log(ctx context.Context, opts LogOptions) ([]*entity.Commit, error) {
+	// pathspec magic such as :(exclude) is not wanted from the API
+	args := []string{"--git-dir", r.repoPath, "--literal-pathspecs", "log", "--format=" + commitFormat}
+	if opts.Stats {
+		// merges show no changes unless they are compared to a parent
+		args = append(args, "--numstat", "--diff-merges=first-parent")
+	}
+	if opts.Skip > 0 {
+		args = append(args, "--skip="+strconv.Itoa(opts.Skip))
+	}
+	if opts.Max > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(opts.Max))
+	}
+	if !opts.Since.IsZero() {
+		args = append(args, "--since="+opts.Since.Format(time.RFC3339))
+	}
+	if !opts.Until.IsZero() {
+		args = append(args, "--until="+opts.Until.Format(time.RFC3339))
+	}
+	if opts.Author != "" {
+		args = append(args, "--fixed-strings", "--regexp-ignore-case", "--author="+opts.Author)
+	}
+	args = append(args, "--end-of-options", opts.From, "--")
+	if opts.Path != "" {
+		args = append(args, opts.Path)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("log %s: %w: %s", opts.From, err, stderr.String())
+	}
+
+	commits := []*entity.Commit{}
+	for record := range strings.SplitSeq(stdout.String(), "\x1e") {
+		if record == "" {
+			continue
+		}
+		commit, err := parseCommit(record, opts.Stats)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// Commit implements Reader.
+func (r *readerImpl) Commit(ctx context.Context, rev string) (*entity.Commit, error) {
+	sha, err := ResolveCommit(ctx, r.repoPath, rev)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", os.ErrNotExist, err)
+	}
+	commits, err := r.Log(ctx, LogOptions{From: sha, Max: 1, Stats: true})
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("commit %s: %w", sha, os.ErrNotExist)
+	}
+	return commits[0], nil
+}
+
+// Diff implements Reader.
+func (r *readerImpl) Diff(ctx context.Context, commitSHA string, maxBytes int) (string, bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "--git-dir", r.repoPath, "show", "--format=", "--patch",
+		"--diff-merges=first-parent", "--no-color", "--no-ext-diff", "--end-of-options", commitSHA)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", false, err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", false, fmt.Errorf("start git show: %w", err)
+	}
+	patch, err := io.ReadAll(io.LimitReader(stdout, int64(maxBytes)+1))
+	if err != nil {
+		_ = cmd.Wait()
+		return "", false, fmt.Errorf("read diff: %w", err)
+	}
+	if len(patch) > maxBytes {
+		// the rest of the patch is not needed, git is killed
+		cancel()
+		_ = cmd.Wait()
+		return string(patch[:maxBytes]), true, nil
+	}
+	if err := cmd.Wait(); err != nil {
+		return "", false, fmt.Errorf("git show: %w: %s", err, stderr.String())
+	}
+	return string(patch), false, nil
+}
+
+// parseCommit parses a commit printed with commitFormat, without the leading record separator.
+func parseCommit(record string, stats bool) (*entity.Commit, error) {
+	fields := strings.SplitN(record, "\x00", 10)
+	if len(fields) != 10 {
+		return nil, fmt.Errorf("unexpected git log output: %q", record)
+	}
+	commit := &entity.Commit{
+		SHA:       fields[0],
+		Parents:   strings.Fields(fields[1]),
+		Author:    entity.Signature{Name: fields[2], Email: fields[3], Date: parseUnixTime(fields[4])},
+		Committer: entity.Signature{Name: fields[5], Email: fields[6], Date: parseUnixTime(fields[7])},
+		Message:   strings.TrimRight(fields[8], "\n"),
+	}
+	if !stats {
+		return commit, nil
+	}
+	// one "<added>\t<deleted>\t<path>" line per file, with dashes for binary files
+	commit.Stats = &entity.CommitStats{}
+	for line := range strings.SplitSeq(fields[9], "\n") {
+		added, rest, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		deleted, _, _ := strings.Cut(rest, "\t")
+		a, _ := strconv.Atoi(added)
+		d, _ := strconv.Atoi(deleted)
+		commit.Stats.Additions += a
+		commit.Stats.Deletions += d
+		commit.Stats.Files++
+	}
+	return commit, nil
+}
@@ -0,0 +1,34 @@
+package git
+
+import (
+	"context"
+
+	"github.com/yz4230/githost-poc/internal/entity"
+)
+
+// Reader reads the refs and history of a bare repository without changing it.
+type Reader interface {
+	// Branches returns the branches sorted by name.
+	Branches(ctx context.Context) ([]*entity.Ref, error)
+	// Tags returns the tags that point to commits, newest first.
+	Tags(ctx context.Context) ([]*entity.Ref, error)
+	// Ref returns the branch or tag of the name. It returns os.ErrNotExist if there is no such ref.
+	Ref(ctx context.Context, refType entity.RefType, name string) (*entity.Ref, error)
+	// Log returns the commits selected by opts, newest first.
+	Log(ctx context.Context, opts LogOptions) ([]*entity.Commit, error)
+	// Commit returns the commit of rev, a SHA, branch or tag, with its stats.
+	// It returns os.ErrNotExist if there is no such commit.
+	Commit(ctx context.Context, rev string) (*entity.Commit, error)
+	// Diff returns the patch of the commit against its first parent, cut after maxBytes, and
+	// whether it was cut.
+	Diff(ctx context.Context, commitSHA string, maxBytes int) (string, bool, error)
+}
+
+type readerImpl struct {
+	repoPath string
+}
+
+// NewReader returns a Reader of the bare repository at repoPath, see storage.GitStorage.GetRepoDir.
+func NewReader(repoPath string) Reader {
+	return &readerImpl{repoPath: repoPath}
+}
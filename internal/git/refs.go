@@ -0,0 +1,139 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yz4230/githost-poc/internal/entity"
+)
+
+// ErrRefConflict is returned when a ref cannot be created or deleted because it exists, conflicts
+// with another ref or is being updated.
+var ErrRefConflict = errors.New("ref conflict")
+
+// refFormat prints the fields parseRef reads, separated by NUL. The author fields are empty for
+// annotated tags, whose commit is read from the fields of the peeled object instead.
+const refFormat = "%(refname)%00%(objecttype)%00%(objectname)%00%(authorname)%00%(authoremail)%00%(authordate:unix)" +
+	"%00%(*objecttype)%00%(*objectname)%00%(*authorname)%00%(*authoremail)%00%(*authordate:unix)"
+
+// Branches implements Reader.
+func (r *readerImpl) Branches(ctx context.Context) ([]*entity.Ref, error) {
+	return r.forEachRef(ctx, "--sort=refname", entity.RefTypeBranch.Prefix())
+}
+
+// Tags implements Reader.
+func (r *readerImpl) Tags(ctx context.Context) ([]*entity.Ref, error) {
+	return r.forEachRef(ctx, "--sort=-creatordate", entity.RefTypeTag.Prefix())
+}
+
+// Ref implements Reader.
+func (r *readerImpl) Ref(ctx context.Context, refType entity.RefType, name string) (*entity.Ref, error) {
+	refs, err := r.forEachRef(ctx, "--sort=refname", refType.Prefix()+name)
+	if err != nil {
+		return nil, err
+	}
+	// the pattern also matches the refs below name, e.g. refs/heads/name/child
+	for _, ref := range refs {
+		if ref.Name == name {
+			return ref, nil
+		}
+	}
+	return nil, fmt.Errorf("ref %s%s: %w", refType.Prefix(), name, os.ErrNotExist)
+}
+
+func (r *readerImpl) forEachRef(ctx context.Context, sort, pattern string) ([]*entity.Ref, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "--git-dir", r.repoPath, "for-each-ref", sort, "--format="+refFormat, pattern)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("list refs: %w: %s", err, stderr.String())
+	}
+
+	var refs []*entity.Ref
+	for line := range strings.SplitSeq(strings.TrimSuffix(stdout.String(), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		ref, ok := parseRef(line)
+		if !ok {
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// parseRef parses a line printed with refFormat. It returns false for refs that do not point to
+// a commit, e.g. tags of trees.
+func parseRef(line string) (*entity.Ref, bool) {
+	fields := strings.Split(line, "\x00")
+	if len(fields) != 11 {
+		return nil, false
+	}
+	commit := fields[1:6]
+	if commit[0] != "commit" {
+		commit = fields[6:11]
+	}
+	if commit[0] != "commit" {
+		return nil, false
+	}
+	_, name, _ := strings.Cut(strings.TrimPrefix(fields[0], "refs/"), "/")
+	return &entity.Ref{
+		Name: name,
+		SHA:  commit[1],
+		Author: entity.Signature{
+			Name:  commit[2],
+			Email: strings.Trim(commit[3], "<>"),
+			Date:  parseUnixTime(commit[4]),
+		},
+	}, true
+}
+
+func parseUnixTime(s string) time.Time {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0).UTC()
+}
+
+// IsValidTagName reports whether name can be used as a tag name.
+func IsValidTagName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "-") {
+		return false
+	}
+	return exec.Command("git", "check-ref-format", "refs/tags/"+name).Run() == nil
+}
+
+// CreateRef points the new ref, e.g. refs/heads/main, to the commit.
+// It returns ErrRefConflict if the ref exists or conflicts with another ref.
+func CreateRef(ctx context.Context, repoPath, ref, commitSHA string) error {
+	// an empty old value makes git refuse to overwrite an existing ref
+	return updateRef(ctx, repoPath, ref, commitSHA, "")
+}
+
+// DeleteRef deletes the ref. It returns ErrRefConflict if the ref is locked, e.g. by a push.
+func DeleteRef(ctx context.Context, repoPath, ref string) error {
+	return updateRef(ctx, repoPath, "-d", ref)
+}
+
+func updateRef(ctx context.Context, repoPath string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--git-dir", repoPath, "update-ref", "--no-deref"}, args...)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stderr.String(), "cannot lock ref") {
+			return fmt.Errorf("update ref: %w: %s", ErrRefConflict, strings.TrimSpace(stderr.String()))
+		}
+		return fmt.Errorf("update ref: %w: %s", err, stderr.String())
+	}
+	return nil
+}
@@ -5,7 +5,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -18,6 +20,30 @@ const (
 	Repositories       = "repositories"
 )
 
+// reGitProtocol matches the values git clients send in the Git-Protocol header,
+// e.g. "version=2" or "version=2:object-format=sha1".
+var reGitProtocol = regexp.MustCompile(`^[a-zA-Z0-9=:._-]*$`)
+
+// SanitizeGitProtocol returns the Git-Protocol header value if it is safe to pass
+// through as GIT_PROTOCOL, or an empty string otherwise.
+func SanitizeGitProtocol(header string) string {
+	header = strings.TrimSpace(header)
+	if !reGitProtocol.MatchString(header) {
+		return ""
+	}
+	return header
+}
+
+// IsProtocolV2 reports whether the GIT_PROTOCOL value requests protocol version 2.
+func IsProtocolV2(gitProtocol string) bool {
+	for kv := range strings.SplitSeq(gitProtocol, ":") {
+		if kv == "version=2" {
+			return true
+		}
+	}
+	return false
+}
+
 // buildServiceAnnouncement builds the pkt-lines that announce the service per git smart protocol.
 // Returned bytes include the length-prefixed header line and the terminating flush (0000).
 func buildServiceAnnouncement(service string) ([]byte, error) {
@@ -38,21 +64,41 @@ func buildServiceAnnouncement(service string) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// serviceCommand builds the git command for the service, passing gitProtocol through as GIT_PROTOCOL.
+// Push options (git push -o) are enabled for receive-pack so that hooks can read them.
+func serviceCommand(service, gitProtocol string, env []string, args ...string) *exec.Cmd {
+	var gitArgs []string
+	if service == ServiceReceivePack {
+		gitArgs = append(gitArgs, "-c", "receive.advertisePushOptions=true")
+	}
+	gitArgs = append(gitArgs, strings.TrimPrefix(service, "git-"))
+	cmd := exec.Command("git", append(gitArgs, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	if gitProtocol != "" {
+		cmd.Env = append(cmd.Env, "GIT_PROTOCOL="+gitProtocol)
+	}
+	return cmd
+}
+
 // AdvertiseRefs writes the advertisement header and runs the git service in advertise-refs mode.
-func AdvertiseRefs(ctx context.Context, service string, repoPath string, w io.Writer) error {
+// When protocol v2 is requested for upload-pack, git writes the capability advertisement itself
+// and the "# service=" preamble is omitted.
+func AdvertiseRefs(ctx context.Context, service string, repoPath string, gitProtocol string, w io.Writer) error {
 	log := zerolog.Ctx(ctx)
-	ann, err := buildServiceAnnouncement(service)
-	if err != nil {
-		return err
-	}
-	if _, err := w.Write(ann); err != nil {
-		return fmt.Errorf("write announcement: %w", err)
+	if service != ServiceUploadPack || !IsProtocolV2(gitProtocol) {
+		ann, err := buildServiceAnnouncement(service)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(ann); err != nil {
+			return fmt.Errorf("write announcement: %w", err)
+		}
 	}
-	cmd := exec.Command("git", strings.TrimPrefix(service, "git-"), "--stateless-rpc", "--advertise-refs", repoPath)
+	cmd := serviceCommand(service, gitProtocol, nil, "--stateless-rpc", "--advertise-refs", repoPath)
 	var stderr bytes.Buffer
 	cmd.Stdout = w
 	cmd.Stderr = &stderr
-	log.Debug().Strs("command", cmd.Args).Msg("executing git command")
+	log.Debug().Strs("command", cmd.Args).Str("git_protocol", gitProtocol).Msg("executing git command")
 	if err := cmd.Run(); err != nil {
 		log.Error().Err(err).Str("stderr", stderr.String()).Msg("git advertise command failed")
 		return err
@@ -61,17 +107,18 @@ func AdvertiseRefs(ctx context.Context, service string, repoPath string, w io.Wr
 }
 
 // ExecStatelessRPC executes the stateless-rpc for the given service.
-func ExecStatelessRPC(ctx context.Context, service string, repoPath string, in io.Reader, w io.Writer) error {
+// env is added to the environment of the service and the hooks it runs.
+func ExecStatelessRPC(ctx context.Context, service string, repoPath string, gitProtocol string, env []string, in io.Reader, w io.Writer) error {
 	log := zerolog.Ctx(ctx)
 	if service != ServiceUploadPack && service != ServiceReceivePack {
 		return fmt.Errorf("unsupported service: %s", service)
 	}
-	cmd := exec.Command("git", strings.TrimPrefix(service, "git-"), "--stateless-rpc", repoPath)
+	cmd := serviceCommand(service, gitProtocol, env, "--stateless-rpc", repoPath)
 	var stderr bytes.Buffer
 	cmd.Stdin = in
 	cmd.Stdout = w
 	cmd.Stderr = &stderr
-	log.Debug().Strs("command", cmd.Args).Msg("executing git command")
+	log.Debug().Strs("command", cmd.Args).Str("git_protocol", gitProtocol).Msg("executing git command")
 	if err := cmd.Run(); err != nil {
 		log.Error().Err(err).Str("stderr", stderr.String()).Msg("git rpc command failed")
 		return err
@@ -0,0 +1,274 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/config"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+// refreshInterval is how often the routes are rebuilt, e.g. to follow containers restarted with a new address.
+const refreshInterval = 30 * time.Second
+
+// Router is a host based reverse proxy in front of the containers of the active deployments.
+type Router interface {
+	http.Handler
+	// SetHost routes requests for the host of the repository to its app, e.g. after the host changed.
+	SetHost(repo *entity.Repository)
+	// SetBackend switches the traffic of the repository to the address.
+	SetBackend(repo *entity.Repository, addr string)
+	// SetPreviewBackend switches the traffic of the preview of the branch to the address.
+	SetPreviewBackend(repo *entity.Repository, branch, addr string)
+	// RemovePreview stops routing to the preview of the branch.
+	RemovePreview(repo *entity.Repository, branch string)
+	// RemoveRepository stops routing to the app and the previews of the repository.
+	RemoveRepository(repo *entity.Repository)
+	// Refresh rebuilds all routes from the repositories and their active deployments.
+	Refresh(ctx context.Context) error
+	// Run refreshes the routes periodically until ctx is cancelled.
+	Run(ctx context.Context)
+}
+
+type route struct {
+	host  string
+	addr  string
+	proxy *httputil.ReverseProxy
+	// preview is set for the routes of previews, the hosts of repositories take precedence over them.
+	preview bool
+	// gen is the value of the generation counter when the route was last set explicitly.
+	gen uint64
+}
+
+// table is never modified once published, changes build a new table and swap it in.
+type table struct {
+	routes map[string]*route // by repository full name, and by preview key for previews
+	hosts  map[string]*route
+}
+
+type routerImpl struct {
+	domain               string
+	repositoryRepository repository.RepositoryRepository
+	deploymentRepository repository.DeploymentRepository
+	deployer             deployer.Deployer
+
+	mu    sync.Mutex // serializes writers
+	gen   uint64
+	table atomic.Pointer[table]
+}
+
+// ServeHTTP implements Router.
+func (r *routerImpl) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	rt, ok := r.table.Load().hosts[strings.ToLower(host)]
+	if !ok {
+		http.Error(w, "no app is served at this host", http.StatusNotFound)
+		return
+	}
+	if rt.proxy == nil {
+		http.Error(w, "the app is not running", http.StatusBadGateway)
+		return
+	}
+	rt.proxy.ServeHTTP(w, req)
+}
+
+// SetHost implements Router.
+func (r *routerImpl) SetHost(repo *entity.Repository) {
+	r.update(func(routes map[string]*route) {
+		rt := routes[repo.FullName()]
+		if rt == nil {
+			rt = &route{}
+		}
+		routes[repo.FullName()] = &route{host: r.host(repo), addr: rt.addr, proxy: rt.proxy, gen: r.gen}
+	})
+}
+
+// SetBackend implements Router.
+func (r *routerImpl) SetBackend(repo *entity.Repository, addr string) {
+	r.update(func(routes map[string]*route) {
+		routes[repo.FullName()] = &route{host: r.host(repo), addr: addr, proxy: newReverseProxy(addr), gen: r.gen}
+	})
+}
+
+// SetPreviewBackend implements Router.
+func (r *routerImpl) SetPreviewBackend(repo *entity.Repository, branch, addr string) {
+	r.update(func(routes map[string]*route) {
+		routes[previewKey(repo, branch)] = &route{host: r.previewHost(repo, branch), addr: addr, proxy: newReverseProxy(addr), preview: true, gen: r.gen}
+	})
+}
+
+// RemovePreview implements Router.
+func (r *routerImpl) RemovePreview(repo *entity.Repository, branch string) {
+	r.update(func(routes map[string]*route) {
+		delete(routes, previewKey(repo, branch))
+	})
+}
+
+// RemoveRepository implements Router.
+func (r *routerImpl) RemoveRepository(repo *entity.Repository) {
+	r.update(func(routes map[string]*route) {
+		for name := range routes {
+			if name == repo.FullName() || strings.HasPrefix(name, repo.FullName()+"@") {
+				delete(routes, name)
+			}
+		}
+	})
+}
+
+// Refresh implements Router.
+func (r *routerImpl) Refresh(ctx context.Context) error {
+	log := zerolog.Ctx(ctx)
+	r.mu.Lock()
+	startGen := r.gen
+	r.mu.Unlock()
+
+	repos, err := r.repositoryRepository.List(ctx)
+	if err != nil {
+		return err
+	}
+	routes := make(map[string]*route, len(repos))
+	for _, repo := range repos {
+		rt := &route{host: r.host(repo)}
+		routes[repo.FullName()] = rt
+		active, err := r.deploymentRepository.GetActiveByRepo(ctx, repo.ID)
+		if err != nil {
+			if err != entity.ErrNotFound {
+				return err
+			}
+			continue
+		}
+		addr, err := r.deployer.Endpoint(ctx, active.ID)
+		if err != nil {
+			if !errors.Is(err, deployer.ErrContainerNotFound) && !errors.Is(err, deployer.ErrNoEndpoint) {
+				return err
+			}
+			log.Debug().Err(err).Str("repo", repo.FullName()).Msg("app is not routable")
+			continue
+		}
+		rt.addr = addr
+		rt.proxy = newReverseProxy(addr)
+	}
+	for _, repo := range repos {
+		previews, err := r.deploymentRepository.ListActivePreviews(ctx, repo.ID)
+		if err != nil {
+			return err
+		}
+		for _, preview := range previews {
+			addr, err := r.deployer.Endpoint(ctx, preview.ID)
+			if err != nil {
+				if !errors.Is(err, deployer.ErrContainerNotFound) && !errors.Is(err, deployer.ErrNoEndpoint) {
+					return err
+				}
+				log.Debug().Err(err).Str("repo", repo.FullName()).Str("branch", preview.Branch).Msg("preview is not routable")
+				continue
+			}
+			routes[previewKey(repo, preview.Branch)] = &route{host: r.previewHost(repo, preview.Branch), addr: addr, proxy: newReverseProxy(addr), preview: true}
+		}
+	}
+	r.update(func(current map[string]*route) {
+		for name, old := range current {
+			// routes set while the database was read are newer than what was read
+			if old.gen > startGen {
+				routes[name] = old
+				continue
+			}
+			// keep the reverse proxy, and with it its idle connections, if the address did not change
+			if rt, ok := routes[name]; ok && rt.addr == old.addr {
+				rt.proxy = old.proxy
+			}
+			delete(current, name)
+		}
+		for name, rt := range routes {
+			current[name] = rt
+		}
+	})
+	return nil
+}
+
+// Run implements Router.
+func (r *routerImpl) Run(ctx context.Context) {
+	log := zerolog.Ctx(ctx)
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		if err := r.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("failed to refresh proxy routes")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *routerImpl) host(repo *entity.Repository) string {
+	return strings.ToLower(repo.AppHost(r.domain))
+}
+
+func (r *routerImpl) previewHost(repo *entity.Repository, branch string) string {
+	return strings.ToLower(repo.PreviewHost(branch, r.domain))
+}
+
+// previewKey is the key of the route of a preview, it cannot clash with repository names.
+func previewKey(repo *entity.Repository, branch string) string {
+	return repo.FullName() + "@" + branch
+}
+
+// update applies fn to a copy of the routes and publishes the result.
+func (r *routerImpl) update(fn func(routes map[string]*route)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gen++
+	current := r.table.Load()
+	routes := make(map[string]*route, len(current.routes))
+	for name, rt := range current.routes {
+		routes[name] = rt
+	}
+	fn(routes)
+	hosts := make(map[string]*route, len(routes))
+	for _, rt := range routes {
+		if rt.host == "" {
+			continue
+		}
+		if other, ok := hosts[rt.host]; ok && !other.preview {
+			continue
+		}
+		hosts[rt.host] = rt
+	}
+	r.table.Store(&table{routes: routes, hosts: hosts})
+}
+
+func newReverseProxy(addr string) *httputil.ReverseProxy {
+	if addr == "" {
+		return nil
+	}
+	return httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: addr})
+}
+
+func NewRouter(i *do.Injector) (Router, error) {
+	config := do.MustInvoke[*config.Config](i)
+	r := &routerImpl{
+		domain:               config.AppsDomain,
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](i),
+		deploymentRepository: do.MustInvoke[repository.DeploymentRepository](i),
+		deployer:             do.MustInvoke[deployer.Deployer](i),
+	}
+	r.table.Store(&table{routes: map[string]*route{}, hosts: map[string]*route{}})
+	return r, nil
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yz4230/githost-poc/internal/entity"
+)
+
+func newTestRouter() *routerImpl {
+	r := &routerImpl{domain: "apps.local"}
+	r.table.Store(&table{routes: map[string]*route{}, hosts: map[string]*route{}})
+	return r
+}
+
+func serve(t *testing.T, r *routerImpl, host string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = host
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	body, _ := io.ReadAll(rec.Body)
+	return rec.Code, string(body)
+}
+
+func TestRouterRoutesByHost(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello from "+r.Host)
+	}))
+	defer backend.Close()
+	addr := strings.TrimPrefix(backend.URL, "http://")
+
+	r := newTestRouter()
+	repo := &entity.Repository{ID: "1", Name: "app"}
+
+	if code, _ := serve(t, r, "app.apps.local"); code != http.StatusNotFound {
+		t.Fatalf("unknown host: status = %d, want %d", code, http.StatusNotFound)
+	}
+
+	r.SetHost(repo)
+	if code, _ := serve(t, r, "app.apps.local"); code != http.StatusBadGateway {
+		t.Fatalf("no backend: status = %d, want %d", code, http.StatusBadGateway)
+	}
+
+	r.SetBackend(repo, addr)
+	code, body := serve(t, r, "App.apps.local:8000")
+	if code != http.StatusOK || body != "hello from App.apps.local:8000" {
+		t.Fatalf("routed: status = %d, body = %q", code, body)
+	}
+
+	repo.Host = "app.example.com"
+	r.SetHost(repo)
+	if code, _ := serve(t, r, "app.apps.local"); code != http.StatusNotFound {
+		t.Fatalf("old host: status = %d, want %d", code, http.StatusNotFound)
+	}
+	if code, _ := serve(t, r, "app.example.com"); code != http.StatusOK {
+		t.Fatalf("new host: status = %d, want %d", code, http.StatusOK)
+	}
+}
@@ -0,0 +1,155 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+// pollInterval is how often the database is checked for deployments queued by other processes, e.g. the git hook.
+const pollInterval = 2 * time.Second
+
+// Runner runs a single deployment to completion.
+type Runner interface {
+	Execute(ctx context.Context, id entity.ID) (*entity.Deployment, error)
+}
+
+// DeploymentQueue runs pending deployments on a pool of workers. The queue itself is
+// the set of pending Deployment rows, so queued jobs survive restarts.
+type DeploymentQueue interface {
+	// Notify wakes the dispatcher to look for pending deployments.
+	Notify()
+	// Run dispatches pending deployments until ctx is cancelled and waits for the workers to stop.
+	Run(ctx context.Context)
+}
+
+type deploymentQueueImpl struct {
+	workers              int
+	deploymentRepository repository.DeploymentRepository
+	runner               Runner
+	wake                 chan struct{}
+}
+
+// Notify implements DeploymentQueue.
+func (q *deploymentQueueImpl) Notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run implements DeploymentQueue.
+func (q *deploymentQueueImpl) Run(ctx context.Context) {
+	log := zerolog.Ctx(ctx)
+	q.requeueInterrupted(ctx)
+
+	jobs := make(chan *entity.Deployment)
+	done := make(chan entity.ID)
+	wg := &sync.WaitGroup{}
+	for range q.workers {
+		wg.Go(func() {
+			for dep := range jobs {
+				if _, err := q.runner.Execute(ctx, dep.ID); err != nil {
+					log.Error().Err(err).Str("deployment", dep.ID.String()).Msg("deployment failed")
+					q.failUnfinished(ctx, dep.ID)
+				}
+				done <- dep.RepoID
+			}
+		})
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	// deployments of the same repository run one at a time
+	busy := map[entity.ID]bool{}
+	idle := q.workers
+	for {
+		if idle > 0 {
+			pending, err := q.deploymentRepository.ListByStatus(ctx, entity.DeploymentStatusPending)
+			if err != nil && ctx.Err() == nil {
+				log.Error().Err(err).Msg("failed to list pending deployments")
+			}
+			for _, dep := range pending {
+				if idle == 0 {
+					break
+				}
+				if busy[dep.RepoID] {
+					continue
+				}
+				busy[dep.RepoID] = true
+				idle--
+				jobs <- dep
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			close(jobs)
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			for range done {
+			}
+			return
+		case repoID := <-done:
+			delete(busy, repoID)
+			idle++
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// failUnfinished marks a deployment failed whose runner gave up before it finished, so that it
+// is not picked up again and again. Deployments interrupted by shutdown stay in the queue.
+func (q *deploymentQueueImpl) failUnfinished(ctx context.Context, id entity.ID) {
+	if ctx.Err() != nil {
+		return
+	}
+	log := zerolog.Ctx(ctx)
+	dep, err := q.deploymentRepository.GetByID(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("deployment", id.String()).Msg("failed to look up failed deployment")
+		return
+	}
+	if dep.Status != entity.DeploymentStatusPending && dep.Status != entity.DeploymentStatusRunning {
+		return
+	}
+	dep.Status = entity.DeploymentStatusFailed
+	if _, err := q.deploymentRepository.Update(ctx, dep); err != nil {
+		log.Error().Err(err).Str("deployment", id.String()).Msg("failed to mark deployment failed")
+	}
+}
+
+// requeueInterrupted puts deployments that were running when the server stopped back in the queue.
+func (q *deploymentQueueImpl) requeueInterrupted(ctx context.Context) {
+	log := zerolog.Ctx(ctx)
+	running, err := q.deploymentRepository.ListByStatus(ctx, entity.DeploymentStatusRunning)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list interrupted deployments")
+		return
+	}
+	for _, dep := range running {
+		dep.Status = entity.DeploymentStatusPending
+		if _, err := q.deploymentRepository.Update(ctx, dep); err != nil {
+			log.Error().Err(err).Str("deployment", dep.ID.String()).Msg("failed to requeue deployment")
+			continue
+		}
+		log.Info().Str("deployment", dep.ID.String()).Msg("requeued interrupted deployment")
+	}
+}
+
+func NewDeploymentQueue(workers int, deploymentRepository repository.DeploymentRepository, runner Runner) DeploymentQueue {
+	return &deploymentQueueImpl{
+		workers:              max(workers, 1),
+		deploymentRepository: deploymentRepository,
+		runner:               runner,
+		wake:                 make(chan struct{}, 1),
+	}
+}
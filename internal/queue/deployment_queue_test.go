@@ -0,0 +1,294 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+// fakeRunner records the deployments it runs. Each run waits for a release unless the runner
+// is created without one.
+type fakeRunner struct {
+	deploymentRepository repository.DeploymentRepository
+	release              chan struct{}
+	started              chan entity.ID
+	// err, if set, is returned before the status of the deployment is changed.
+	err error
+
+	mu         sync.Mutex
+	running    map[entity.ID]int
+	concurrent int
+	maxRunning int
+	overlapped bool
+}
+
+func (r *fakeRunner) Execute(ctx context.Context, id entity.ID) (*entity.Deployment, error) {
+	dep, err := r.deploymentRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.running[dep.RepoID]++
+	if r.running[dep.RepoID] > 1 {
+		r.overlapped = true
+	}
+	r.concurrent++
+	r.maxRunning = max(r.maxRunning, r.concurrent)
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.running[dep.RepoID]--
+		r.concurrent--
+		r.mu.Unlock()
+	}()
+	r.started <- id
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	dep.Status = entity.DeploymentStatusRunning
+	if dep, err = r.deploymentRepository.Update(ctx, dep); err != nil {
+		return nil, err
+	}
+	if r.release != nil {
+		select {
+		case <-r.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	dep.Status = entity.DeploymentStatusSuccess
+	return r.deploymentRepository.Update(ctx, dep)
+}
+
+type queueTest struct {
+	t            *testing.T
+	repositories repository.RepositoryRepository
+	deployments  repository.DeploymentRepository
+	runner       *fakeRunner
+}
+
+func newQueueTest(t *testing.T) *queueTest {
+	t.Helper()
+	db, err := repository.NewSQLiteDB(filepath.Join(t.TempDir(), "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	injector := do.New()
+	do.ProvideValue(injector, db)
+	do.Provide(injector, repository.NewRepositoryRepository)
+	do.Provide(injector, repository.NewDeploymentRepository)
+	deployments := do.MustInvoke[repository.DeploymentRepository](injector)
+	return &queueTest{
+		t:            t,
+		repositories: do.MustInvoke[repository.RepositoryRepository](injector),
+		deployments:  deployments,
+		runner: &fakeRunner{
+			deploymentRepository: deployments,
+			started:              make(chan entity.ID, 100),
+			running:              map[entity.ID]int{},
+		},
+	}
+}
+
+// queue creates a deployment of a new commit of the repository with the status.
+func (q *queueTest) queue(repo *entity.Repository, status entity.DeploymentStatus) *entity.Deployment {
+	q.t.Helper()
+	dep, err := q.deployments.Create(context.Background(), &entity.Deployment{RepoID: repo.ID, Branch: "main", CommitSHA: entity.ZeroSHA, Status: status})
+	if err != nil {
+		q.t.Fatal(err)
+	}
+	return dep
+}
+
+func (q *queueTest) repo(name string) *entity.Repository {
+	q.t.Helper()
+	repo, err := q.repositories.Create(context.Background(), &entity.Repository{Owner: "alice", Name: name, DeployBranch: "main"})
+	if err != nil {
+		q.t.Fatal(err)
+	}
+	return repo
+}
+
+// run runs a queue of the workers until the test ends.
+func (q *queueTest) run(workers int) DeploymentQueue {
+	q.t.Helper()
+	queue := NewDeploymentQueue(workers, q.deployments, q.runner)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		queue.Run(ctx)
+		close(stopped)
+	}()
+	q.t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+	return queue
+}
+
+// waitStarted waits for n deployments to start and returns them in order.
+func (q *queueTest) waitStarted(n int) []entity.ID {
+	q.t.Helper()
+	var ids []entity.ID
+	for range n {
+		select {
+		case id := <-q.runner.started:
+			ids = append(ids, id)
+		case <-time.After(5 * time.Second):
+			q.t.Fatalf("%d of %d deployments started", len(ids), n)
+		}
+	}
+	return ids
+}
+
+// assertIdle checks that no further deployment starts for a while.
+func (q *queueTest) assertIdle() {
+	q.t.Helper()
+	select {
+	case id := <-q.runner.started:
+		q.t.Fatalf("deployment %s started, want none", id)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func (q *queueTest) status(id entity.ID) entity.DeploymentStatus {
+	q.t.Helper()
+	dep, err := q.deployments.GetByID(context.Background(), id)
+	if err != nil {
+		q.t.Fatal(err)
+	}
+	return dep.Status
+}
+
+func TestQueueRunsDeploymentsOfARepositoryOneAtATime(t *testing.T) {
+	q := newQueueTest(t)
+	q.runner.release = make(chan struct{})
+	app, api := q.repo("app"), q.repo("api")
+	first := q.queue(app, entity.DeploymentStatusPending)
+	second := q.queue(app, entity.DeploymentStatusPending)
+	other := q.queue(api, entity.DeploymentStatusPending)
+	q.run(4)
+
+	// the other repository does not wait for the first one, but the second deployment does
+	started := q.waitStarted(2)
+	if !slices.Contains(started, first.ID) || !slices.Contains(started, other.ID) {
+		t.Fatalf("started %v, want %s and %s", started, first.ID, other.ID)
+	}
+	q.assertIdle()
+
+	q.runner.release <- struct{}{}
+	q.runner.release <- struct{}{}
+	if got := q.waitStarted(1); got[0] != second.ID {
+		t.Fatalf("started %v after the first deployments, want %s", got, second.ID)
+	}
+	q.runner.release <- struct{}{}
+
+	q.runner.mu.Lock()
+	defer q.runner.mu.Unlock()
+	if q.runner.overlapped {
+		t.Fatal("deployments of the same repository ran at the same time")
+	}
+}
+
+func TestQueueLimitsWorkers(t *testing.T) {
+	for _, tc := range []struct {
+		workers, want int
+	}{
+		{2, 2},
+		// at least one worker runs
+		{0, 1},
+	} {
+		q := newQueueTest(t)
+		q.runner.release = make(chan struct{})
+		var ids []entity.ID
+		for _, name := range []string{"a", "b", "c", "d"} {
+			ids = append(ids, q.queue(q.repo(name), entity.DeploymentStatusPending).ID)
+		}
+		q.run(tc.workers)
+
+		q.waitStarted(tc.want)
+		q.assertIdle()
+		for range ids {
+			q.runner.release <- struct{}{}
+		}
+		for _, id := range ids {
+			deadline := time.Now().Add(5 * time.Second)
+			for q.status(id) != entity.DeploymentStatusSuccess {
+				if time.Now().After(deadline) {
+					t.Fatalf("deployment %s status = %s, want success", id, q.status(id))
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+		q.runner.mu.Lock()
+		if q.runner.maxRunning != tc.want {
+			t.Errorf("%d workers ran %d deployments at once, want %d", tc.workers, q.runner.maxRunning, tc.want)
+		}
+		q.runner.mu.Unlock()
+	}
+}
+
+func TestQueueRequeuesInterruptedDeployments(t *testing.T) {
+	q := newQueueTest(t)
+	// the server stopped while the deployment was running
+	interrupted := q.queue(q.repo("app"), entity.DeploymentStatusRunning)
+	done := q.queue(q.repo("api"), entity.DeploymentStatusSuccess)
+	q.run(1)
+
+	if got := q.waitStarted(1); got[0] != interrupted.ID {
+		t.Fatalf("started %v, want the interrupted deployment %s", got, interrupted.ID)
+	}
+	q.assertIdle()
+	if got := q.status(done.ID); got != entity.DeploymentStatusSuccess {
+		t.Fatalf("finished deployment status = %s, want success", got)
+	}
+}
+
+func TestQueueFailsDeploymentsWhoseRunnerFails(t *testing.T) {
+	q := newQueueTest(t)
+	q.runner.err = errors.New("log storage unavailable")
+	dep := q.queue(q.repo("app"), entity.DeploymentStatusPending)
+	queue := q.run(1)
+
+	q.waitStarted(1)
+	deadline := time.Now().Add(5 * time.Second)
+	for q.status(dep.ID) != entity.DeploymentStatusFailed {
+		if time.Now().After(deadline) {
+			t.Fatalf("deployment status = %s, want failed", q.status(dep.ID))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// it is not retried
+	queue.Notify()
+	q.assertIdle()
+}
+
+func TestQueueKeepsDeploymentsInterruptedByShutdown(t *testing.T) {
+	q := newQueueTest(t)
+	q.runner.release = make(chan struct{})
+	dep := q.queue(q.repo("app"), entity.DeploymentStatusPending)
+	queue := NewDeploymentQueue(1, q.deployments, q.runner)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		queue.Run(ctx)
+		close(stopped)
+	}()
+
+	q.waitStarted(1)
+	cancel()
+	<-stopped
+	// the runner leaves it running, the next start of the server requeues it
+	if got := q.status(dep.ID); got != entity.DeploymentStatusRunning {
+		t.Fatalf("interrupted deployment status = %s, want running", got)
+	}
+}
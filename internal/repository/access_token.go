@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"gorm.io/gorm"
+)
+
+type AccessTokenRepository interface {
+	Create(ctx context.Context, token *entity.AccessToken) (*entity.AccessToken, error)
+	GetByHash(ctx context.Context, hash string) (*entity.AccessToken, error)
+	ListByUser(ctx context.Context, userID entity.ID) ([]*entity.AccessToken, error)
+	Delete(ctx context.Context, id entity.ID) error
+}
+
+type accessTokenRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// Create implements AccessTokenRepository.
+func (r *accessTokenRepositoryImpl) Create(ctx context.Context, token *entity.AccessToken) (*entity.AccessToken, error) {
+	var model AccessToken
+	model.FromEntity(token)
+	if err := gorm.G[AccessToken](r.db).Create(ctx, &model); err != nil {
+		return nil, err
+	}
+	return model.ToEntity(), nil
+}
+
+// GetByHash implements AccessTokenRepository.
+func (r *accessTokenRepositoryImpl) GetByHash(ctx context.Context, hash string) (*entity.AccessToken, error) {
+	found, err := gorm.G[AccessToken](r.db).Where("token_hash = ?", hash).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.ErrNotFound
+		}
+		return nil, err
+	}
+	return found.ToEntity(), nil
+}
+
+// ListByUser implements AccessTokenRepository.
+func (r *accessTokenRepositoryImpl) ListByUser(ctx context.Context, userID entity.ID) ([]*entity.AccessToken, error) {
+	founds, err := gorm.G[AccessToken](r.db).Where("user_id = ?", userID.Uint()).Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*entity.AccessToken, len(founds))
+	for i, f := range founds {
+		result[i] = f.ToEntity()
+	}
+	return result, nil
+}
+
+// Delete implements AccessTokenRepository.
+func (r *accessTokenRepositoryImpl) Delete(ctx context.Context, id entity.ID) error {
+	_, err := gorm.G[AccessToken](r.db).Where("id = ?", id.Uint()).Delete(ctx)
+	return err
+}
+
+func NewAccessTokenRepository(i *do.Injector) (AccessTokenRepository, error) {
+	return &accessTokenRepositoryImpl{db: do.MustInvoke[*gorm.DB](i)}, nil
+}
@@ -6,11 +6,13 @@ import (
 )
 
 func NewSQLiteDB(filename string) (*gorm.DB, error) {
-	db, err := gorm.Open(sqlite.Open(filename), &gorm.Config{})
+	// the database is shared with the git hook processes, so wait for locks instead of failing
+	dsn := filename + "?_busy_timeout=5000&_journal_mode=WAL"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
-	if err := db.AutoMigrate(&Repository{}, &Deployment{}); err != nil {
+	if err := db.AutoMigrate(&Repository{}, &Deployment{}, &User{}, &AccessToken{}, &RepositoryPermission{}, &EnvVar{}, &RepositoryRedirect{}, &Organization{}, &OrganizationMember{}); err != nil {
 		return nil, err
 	}
 	return db, nil
@@ -2,7 +2,9 @@ package repository
 
 import (
 	"context"
+	"errors"
 
+	"github.com/samber/do"
 	"github.com/yz4230/githost-poc/internal/entity"
 	"gorm.io/gorm"
 )
@@ -12,16 +14,24 @@ type DeploymentRepository interface {
 	GetByID(ctx context.Context, id entity.ID) (*entity.Deployment, error)
 	List(ctx context.Context) ([]*entity.Deployment, error)
 	ListByRepo(ctx context.Context, repoID entity.ID) ([]*entity.Deployment, error)
+	GetActiveByRepo(ctx context.Context, repoID entity.ID) (*entity.Deployment, error)
+	GetActivePreview(ctx context.Context, repoID entity.ID, branch string) (*entity.Deployment, error)
+	ListActivePreviews(ctx context.Context, repoID entity.ID) ([]*entity.Deployment, error)
+	DeactivatePreview(ctx context.Context, repoID entity.ID, branch string) error
+	SetLimits(ctx context.Context, id entity.ID, limits *entity.ContainerLimits) error
+	ListByStatus(ctx context.Context, status entity.DeploymentStatus) ([]*entity.Deployment, error)
 	Update(ctx context.Context, dep *entity.Deployment) (*entity.Deployment, error)
+	SetActive(ctx context.Context, dep *entity.Deployment) error
 	Delete(ctx context.Context, id entity.ID) error
+	DeleteByRepo(ctx context.Context, repoID entity.ID) error
 }
 
 type deploymentRepositoryImpl struct {
 	db *gorm.DB
 }
 
-func NewDeploymentRepository(db *gorm.DB) DeploymentRepository {
-	return &deploymentRepositoryImpl{db: db}
+func NewDeploymentRepository(i *do.Injector) (DeploymentRepository, error) {
+	return &deploymentRepositoryImpl{db: do.MustInvoke[*gorm.DB](i)}, nil
 }
 
 // Create a new deployment record.
@@ -38,6 +48,9 @@ func (r *deploymentRepositoryImpl) Create(ctx context.Context, dep *entity.Deplo
 func (r *deploymentRepositoryImpl) GetByID(ctx context.Context, id entity.ID) (*entity.Deployment, error) {
 	found, err := gorm.G[Deployment](r.db).Where("id = ?", id.Uint()).First(ctx)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.ErrNotFound
+		}
 		return nil, err
 	}
 	return found.ToEntity(), nil
@@ -56,9 +69,80 @@ func (r *deploymentRepositoryImpl) List(ctx context.Context) ([]*entity.Deployme
 	return res, nil
 }
 
-// ListByRepo lists deployments belonging to a repository.
+// ListByRepo lists deployments belonging to a repository, newest first.
 func (r *deploymentRepositoryImpl) ListByRepo(ctx context.Context, repoID entity.ID) ([]*entity.Deployment, error) {
-	founds, err := gorm.G[Deployment](r.db).Where("repo_id = ?", repoID.Uint()).Find(ctx)
+	founds, err := gorm.G[Deployment](r.db).Where("repo_id = ?", repoID.Uint()).Order("id DESC").Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*entity.Deployment, len(founds))
+	for i, f := range founds {
+		res[i] = f.ToEntity()
+	}
+	return res, nil
+}
+
+// GetActiveByRepo finds the deployment currently running for a repository, not counting previews.
+func (r *deploymentRepositoryImpl) GetActiveByRepo(ctx context.Context, repoID entity.ID) (*entity.Deployment, error) {
+	found, err := gorm.G[Deployment](r.db).Where("repo_id = ? AND is_active = ? AND preview = ?", repoID.Uint(), true, false).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.ErrNotFound
+		}
+		return nil, err
+	}
+	return found.ToEntity(), nil
+}
+
+// GetActivePreview finds the deployment currently running as the preview of a branch.
+func (r *deploymentRepositoryImpl) GetActivePreview(ctx context.Context, repoID entity.ID, branch string) (*entity.Deployment, error) {
+	found, err := gorm.G[Deployment](r.db).
+		Where("repo_id = ? AND is_active = ? AND preview = ? AND branch = ?", repoID.Uint(), true, true, branch).
+		First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.ErrNotFound
+		}
+		return nil, err
+	}
+	return found.ToEntity(), nil
+}
+
+// ListActivePreviews lists the deployments running as previews of a repository, one per branch.
+func (r *deploymentRepositoryImpl) ListActivePreviews(ctx context.Context, repoID entity.ID) ([]*entity.Deployment, error) {
+	founds, err := gorm.G[Deployment](r.db).
+		Where("repo_id = ? AND is_active = ? AND preview = ?", repoID.Uint(), true, true).
+		Order("branch ASC").
+		Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*entity.Deployment, len(founds))
+	for i, f := range founds {
+		res[i] = f.ToEntity()
+	}
+	return res, nil
+}
+
+// DeactivatePreview marks the preview of the branch as no longer running.
+func (r *deploymentRepositoryImpl) DeactivatePreview(ctx context.Context, repoID entity.ID, branch string) error {
+	return r.db.WithContext(ctx).Model(&Deployment{}).
+		Where("repo_id = ? AND preview = ? AND branch = ?", repoID.Uint(), true, branch).
+		Update("is_active", false).Error
+}
+
+// SetLimits records the limits the container of the deployment runs with.
+func (r *deploymentRepositoryImpl) SetLimits(ctx context.Context, id entity.ID, limits *entity.ContainerLimits) error {
+	// update through the model, the json serializer of the column is not applied to plain values
+	return r.db.WithContext(ctx).Model(&Deployment{}).
+		Where("id = ?", id.Uint()).
+		Select("limits").
+		Updates(&Deployment{Limits: limits}).Error
+}
+
+// ListByStatus lists deployments in the status, oldest first.
+func (r *deploymentRepositoryImpl) ListByStatus(ctx context.Context, status entity.DeploymentStatus) ([]*entity.Deployment, error) {
+	founds, err := gorm.G[Deployment](r.db).Where("status = ?", string(status)).Order("id ASC").Find(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -80,8 +164,29 @@ func (r *deploymentRepositoryImpl) Update(ctx context.Context, dep *entity.Deplo
 	return r.GetByID(ctx, dep.ID)
 }
 
+// SetActive marks the deployment as the active one of its repository and deactivates the others.
+// A preview only replaces the preview of its branch.
+func (r *deploymentRepositoryImpl) SetActive(ctx context.Context, dep *entity.Deployment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		others := tx.Model(&Deployment{}).
+			Where("repo_id = ? AND id <> ? AND preview = ?", dep.RepoID.Uint(), dep.ID.Uint(), dep.Preview)
+		if dep.Preview {
+			others = others.Where("branch = ?", dep.Branch)
+		}
+		if err := others.Update("is_active", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Deployment{}).Where("id = ?", dep.ID.Uint()).Update("is_active", true).Error
+	})
+}
+
 // Delete deployment by id.
 func (r *deploymentRepositoryImpl) Delete(ctx context.Context, id entity.ID) error {
 	_, err := gorm.G[Deployment](r.db).Where("id = ?", id.Uint()).Delete(ctx)
 	return err
 }
+
+// DeleteByRepo permanently removes the deployments of the repository.
+func (r *deploymentRepositoryImpl) DeleteByRepo(ctx context.Context, repoID entity.ID) error {
+	return r.db.WithContext(ctx).Unscoped().Where("repo_id = ?", repoID.Uint()).Delete(&Deployment{}).Error
+}
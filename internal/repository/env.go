@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/secret"
+	"gorm.io/gorm"
+)
+
+// EnvVarRepository stores environment variables encrypted with the master key.
+// Entities carry the plaintext value; it never reaches the database.
+type EnvVarRepository interface {
+	ListByRepo(ctx context.Context, repoID entity.ID) ([]*entity.EnvVar, error)
+	Set(ctx context.Context, env *entity.EnvVar) (*entity.EnvVar, error)
+	Delete(ctx context.Context, repoID entity.ID, name string) error
+	DeleteByRepo(ctx context.Context, repoID entity.ID) error
+}
+
+type envVarRepositoryImpl struct {
+	db     *gorm.DB
+	cipher secret.Cipher
+}
+
+// associatedData ties a ciphertext to its repository and name.
+func associatedData(repoID uint, name string) []byte {
+	return fmt.Appendf(nil, "%d/%s", repoID, name)
+}
+
+func (r *envVarRepositoryImpl) toEntity(m *EnvVar) (*entity.EnvVar, error) {
+	value, err := r.cipher.Decrypt(m.EncryptedValue, associatedData(m.RepoID, m.Name))
+	if err != nil {
+		return nil, fmt.Errorf("env var %s: %w", m.Name, err)
+	}
+	return &entity.EnvVar{
+		ID:        entity.NewID(m.ID),
+		RepoID:    entity.NewID(m.RepoID),
+		Name:      m.Name,
+		Value:     string(value),
+		Secret:    m.Secret,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}, nil
+}
+
+// ListByRepo implements EnvVarRepository.
+func (r *envVarRepositoryImpl) ListByRepo(ctx context.Context, repoID entity.ID) ([]*entity.EnvVar, error) {
+	founds, err := gorm.G[EnvVar](r.db).Where("repo_id = ?", repoID.Uint()).Order("name ASC").Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*entity.EnvVar, len(founds))
+	for i := range founds {
+		if result[i], err = r.toEntity(&founds[i]); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Set creates or replaces the variable of a repository.
+func (r *envVarRepositoryImpl) Set(ctx context.Context, env *entity.EnvVar) (*entity.EnvVar, error) {
+	encrypted, err := r.cipher.Encrypt([]byte(env.Value), associatedData(env.RepoID.Uint(), env.Name))
+	if err != nil {
+		return nil, err
+	}
+	var model EnvVar
+	err = r.db.WithContext(ctx).
+		Where(EnvVar{RepoID: env.RepoID.Uint(), Name: env.Name}).
+		Assign(map[string]any{"encrypted_value": encrypted, "secret": env.Secret}).
+		FirstOrCreate(&model).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.toEntity(&model)
+}
+
+// Delete permanently removes the variable so it can be set again later.
+func (r *envVarRepositoryImpl) Delete(ctx context.Context, repoID entity.ID, name string) error {
+	result := r.db.WithContext(ctx).Unscoped().
+		Where("repo_id = ? AND name = ?", repoID.Uint(), name).
+		Delete(&EnvVar{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteByRepo permanently removes the variables of the repository.
+func (r *envVarRepositoryImpl) DeleteByRepo(ctx context.Context, repoID entity.ID) error {
+	return r.db.WithContext(ctx).Unscoped().Where("repo_id = ?", repoID.Uint()).Delete(&EnvVar{}).Error
+}
+
+func NewEnvVarRepository(i *do.Injector) (EnvVarRepository, error) {
+	return &envVarRepositoryImpl{
+		db:     do.MustInvoke[*gorm.DB](i),
+		cipher: do.MustInvoke[secret.Cipher](i),
+	}, nil
+}
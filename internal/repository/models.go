@@ -1,36 +1,108 @@
 package repository
 
 import (
+	"strings"
+	"time"
+
 	"github.com/yz4230/githost-poc/internal/entity"
 	"gorm.io/gorm"
 )
 
 type Repository struct {
 	gorm.Model
+	// Owner is empty for repositories created before owners existed.
+	Owner        string `gorm:"not null;default:'';index"`
 	Name         string
 	Description  string
 	DeployBranch string
 	LatestSHA    string
+	Host         string
+	MaxPreviews  *int
 }
 
 func (r *Repository) ToEntity() *entity.Repository {
-	return &entity.Repository{
+	e := &entity.Repository{
 		ID:           entity.NewID(r.ID),
+		Owner:        r.Owner,
 		Name:         r.Name,
 		Description:  r.Description,
 		DeployBranch: r.DeployBranch,
 		LatestSHA:    r.LatestSHA,
+		Host:         r.Host,
+		MaxPreviews:  r.MaxPreviews,
 		CreatedAt:    r.CreatedAt,
 		UpdatedAt:    r.UpdatedAt,
 	}
+	if r.DeletedAt.Valid {
+		e.DeletedAt = &r.DeletedAt.Time
+	}
+	return e
 }
 
 func (r *Repository) FromEntity(e *entity.Repository) {
 	r.ID = e.ID.Uint()
+	r.Owner = e.Owner
 	r.Name = e.Name
 	r.Description = e.Description
 	r.DeployBranch = e.DeployBranch
 	r.LatestSHA = e.LatestSHA
+	r.Host = e.Host
+	r.MaxPreviews = e.MaxPreviews
+}
+
+type Organization struct {
+	gorm.Model
+	Name        string `gorm:"uniqueIndex"`
+	Description string
+}
+
+func (o *Organization) ToEntity() *entity.Organization {
+	return &entity.Organization{
+		ID:          entity.NewID(o.ID),
+		Name:        o.Name,
+		Description: o.Description,
+		CreatedAt:   o.CreatedAt,
+		UpdatedAt:   o.UpdatedAt,
+	}
+}
+
+func (o *Organization) FromEntity(e *entity.Organization) {
+	o.ID = e.ID.Uint()
+	o.Name = e.Name
+	o.Description = e.Description
+}
+
+type OrganizationMember struct {
+	gorm.Model
+	OrgID  uint `gorm:"uniqueIndex:idx_org_user"`
+	Org    Organization
+	UserID uint `gorm:"uniqueIndex:idx_org_user"`
+	User   User
+	Role   string
+}
+
+func (m *OrganizationMember) ToEntity() *entity.OrganizationMember {
+	return &entity.OrganizationMember{
+		OrgID:  entity.NewID(m.OrgID),
+		UserID: entity.NewID(m.UserID),
+		Role:   entity.OrganizationRole(m.Role),
+	}
+}
+
+type RepositoryRedirect struct {
+	gorm.Model
+	// Name is the former full name of the repository.
+	Name   string `gorm:"uniqueIndex"`
+	RepoID uint
+	Repo   Repository
+}
+
+func (r *RepositoryRedirect) ToEntity() *entity.RepositoryRedirect {
+	return &entity.RepositoryRedirect{
+		Name:      r.Name,
+		RepoID:    entity.NewID(r.RepoID),
+		CreatedAt: r.CreatedAt,
+	}
 }
 
 type Deployment struct {
@@ -41,24 +113,143 @@ type Deployment struct {
 	CommitSHA string
 	Status    string
 	IsActive  bool
+	// RollbackOfID is zero for deployments that build their image.
+	RollbackOfID uint
+	Clean        bool
+	Preview      bool                    `gorm:"not null;default:false"`
+	Limits       *entity.ContainerLimits `gorm:"serializer:json"`
 }
 
 func (d *Deployment) ToEntity() *entity.Deployment {
-	return &entity.Deployment{
+	e := &entity.Deployment{
 		ID:        entity.NewID(d.ID),
 		RepoID:    entity.NewID(d.RepoID),
+		Branch:    d.Branch,
 		CommitSHA: d.CommitSHA,
 		Status:    entity.DeploymentStatus(d.Status),
 		IsActive:  d.IsActive,
 		CreatedAt: d.CreatedAt,
 		UpdatedAt: d.UpdatedAt,
+		Limits:    d.Limits,
+		Clean:     d.Clean,
+		Preview:   d.Preview,
+	}
+	if d.RollbackOfID != 0 {
+		e.RollbackOf = entity.NewID(d.RollbackOfID)
 	}
+	return e
 }
 
 func (d *Deployment) FromEntity(e *entity.Deployment) {
 	d.ID = e.ID.Uint()
 	d.RepoID = e.RepoID.Uint()
+	d.Branch = e.Branch
 	d.CommitSHA = e.CommitSHA
 	d.Status = string(e.Status)
 	d.IsActive = e.IsActive
+	d.Limits = e.Limits
+	d.Clean = e.Clean
+	d.Preview = e.Preview
+	if e.RollbackOf != "" {
+		d.RollbackOfID = e.RollbackOf.Uint()
+	}
+}
+
+type User struct {
+	gorm.Model
+	Name         string `gorm:"uniqueIndex"`
+	PasswordHash string
+	IsAdmin      bool
+}
+
+func (u *User) ToEntity() *entity.User {
+	return &entity.User{
+		ID:           entity.NewID(u.ID),
+		Name:         u.Name,
+		PasswordHash: u.PasswordHash,
+		IsAdmin:      u.IsAdmin,
+		CreatedAt:    u.CreatedAt,
+		UpdatedAt:    u.UpdatedAt,
+	}
+}
+
+func (u *User) FromEntity(e *entity.User) {
+	u.ID = e.ID.Uint()
+	u.Name = e.Name
+	u.PasswordHash = e.PasswordHash
+	u.IsAdmin = e.IsAdmin
+}
+
+type AccessToken struct {
+	gorm.Model
+	UserID    uint
+	User      User
+	Name      string
+	TokenHash string `gorm:"uniqueIndex"`
+	Scopes    string
+	ExpiresAt time.Time
+}
+
+func (t *AccessToken) ToEntity() *entity.AccessToken {
+	var scopes []entity.TokenScope
+	for s := range strings.SplitSeq(t.Scopes, ",") {
+		if s != "" {
+			scopes = append(scopes, entity.TokenScope(s))
+		}
+	}
+	return &entity.AccessToken{
+		ID:        entity.NewID(t.ID),
+		UserID:    entity.NewID(t.UserID),
+		Name:      t.Name,
+		TokenHash: t.TokenHash,
+		Scopes:    scopes,
+		ExpiresAt: t.ExpiresAt,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+func (t *AccessToken) FromEntity(e *entity.AccessToken) {
+	scopes := make([]string, len(e.Scopes))
+	for i, s := range e.Scopes {
+		scopes[i] = string(s)
+	}
+	t.ID = e.ID.Uint()
+	t.UserID = e.UserID.Uint()
+	t.Name = e.Name
+	t.TokenHash = e.TokenHash
+	t.Scopes = strings.Join(scopes, ",")
+	t.ExpiresAt = e.ExpiresAt
+}
+
+type RepositoryPermission struct {
+	gorm.Model
+	RepoID uint `gorm:"uniqueIndex:idx_repo_user"`
+	Repo   Repository
+	UserID uint `gorm:"uniqueIndex:idx_repo_user"`
+	User   User
+	Access string
+}
+
+func (p *RepositoryPermission) ToEntity() *entity.RepositoryPermission {
+	return &entity.RepositoryPermission{
+		RepoID: entity.NewID(p.RepoID),
+		UserID: entity.NewID(p.UserID),
+		Access: entity.Access(p.Access),
+	}
+}
+
+func (p *RepositoryPermission) FromEntity(e *entity.RepositoryPermission) {
+	p.RepoID = e.RepoID.Uint()
+	p.UserID = e.UserID.Uint()
+	p.Access = string(e.Access)
+}
+
+type EnvVar struct {
+	gorm.Model
+	RepoID uint `gorm:"uniqueIndex:idx_repo_env"`
+	Repo   Repository
+	Name   string `gorm:"uniqueIndex:idx_repo_env"`
+	// EncryptedValue is the value sealed with the master key, see secret.Cipher.
+	EncryptedValue string
+	Secret         bool
 }
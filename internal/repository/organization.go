@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"gorm.io/gorm"
+)
+
+type OrganizationRepository interface {
+	Create(ctx context.Context, org *entity.Organization) (*entity.Organization, error)
+	GetByName(ctx context.Context, name string) (*entity.Organization, error)
+	List(ctx context.Context) ([]*entity.Organization, error)
+}
+
+type organizationRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// Create implements OrganizationRepository.
+func (r *organizationRepositoryImpl) Create(ctx context.Context, org *entity.Organization) (*entity.Organization, error) {
+	var model Organization
+	model.FromEntity(org)
+	if err := gorm.G[Organization](r.db).Create(ctx, &model); err != nil {
+		return nil, err
+	}
+	return model.ToEntity(), nil
+}
+
+// GetByName implements OrganizationRepository.
+func (r *organizationRepositoryImpl) GetByName(ctx context.Context, name string) (*entity.Organization, error) {
+	found, err := gorm.G[Organization](r.db).Where("name = ?", name).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.ErrNotFound
+		}
+		return nil, err
+	}
+	return found.ToEntity(), nil
+}
+
+// List implements OrganizationRepository.
+func (r *organizationRepositoryImpl) List(ctx context.Context) ([]*entity.Organization, error) {
+	founds, err := gorm.G[Organization](r.db).Order("name ASC").Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*entity.Organization, len(founds))
+	for i, f := range founds {
+		result[i] = f.ToEntity()
+	}
+	return result, nil
+}
+
+func NewOrganizationRepository(i *do.Injector) (OrganizationRepository, error) {
+	return &organizationRepositoryImpl{db: do.MustInvoke[*gorm.DB](i)}, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"gorm.io/gorm"
+)
+
+type OrganizationMemberRepository interface {
+	Get(ctx context.Context, orgID, userID entity.ID) (*entity.OrganizationMember, error)
+	Set(ctx context.Context, member *entity.OrganizationMember) (*entity.OrganizationMember, error)
+}
+
+type organizationMemberRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// Get implements OrganizationMemberRepository.
+func (r *organizationMemberRepositoryImpl) Get(ctx context.Context, orgID, userID entity.ID) (*entity.OrganizationMember, error) {
+	found, err := gorm.G[OrganizationMember](r.db).
+		Where("org_id = ? AND user_id = ?", orgID.Uint(), userID.Uint()).
+		First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.ErrNotFound
+		}
+		return nil, err
+	}
+	return found.ToEntity(), nil
+}
+
+// Set creates or replaces the role of a user in an organization.
+func (r *organizationMemberRepositoryImpl) Set(ctx context.Context, member *entity.OrganizationMember) (*entity.OrganizationMember, error) {
+	var model OrganizationMember
+	err := r.db.WithContext(ctx).
+		Where(OrganizationMember{OrgID: member.OrgID.Uint(), UserID: member.UserID.Uint()}).
+		Assign(OrganizationMember{Role: string(member.Role)}).
+		FirstOrCreate(&model).Error
+	if err != nil {
+		return nil, err
+	}
+	return model.ToEntity(), nil
+}
+
+func NewOrganizationMemberRepository(i *do.Injector) (OrganizationMemberRepository, error) {
+	return &organizationMemberRepositoryImpl{db: do.MustInvoke[*gorm.DB](i)}, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"gorm.io/gorm"
+)
+
+type RepositoryPermissionRepository interface {
+	Get(ctx context.Context, repoID, userID entity.ID) (*entity.RepositoryPermission, error)
+	ListByRepo(ctx context.Context, repoID entity.ID) ([]*entity.RepositoryPermission, error)
+	Set(ctx context.Context, perm *entity.RepositoryPermission) (*entity.RepositoryPermission, error)
+	Delete(ctx context.Context, repoID, userID entity.ID) error
+	DeleteByRepo(ctx context.Context, repoID entity.ID) error
+}
+
+type repositoryPermissionRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// Get implements RepositoryPermissionRepository.
+func (r *repositoryPermissionRepositoryImpl) Get(ctx context.Context, repoID, userID entity.ID) (*entity.RepositoryPermission, error) {
+	found, err := gorm.G[RepositoryPermission](r.db).
+		Where("repo_id = ? AND user_id = ?", repoID.Uint(), userID.Uint()).
+		First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.ErrNotFound
+		}
+		return nil, err
+	}
+	return found.ToEntity(), nil
+}
+
+// ListByRepo implements RepositoryPermissionRepository.
+func (r *repositoryPermissionRepositoryImpl) ListByRepo(ctx context.Context, repoID entity.ID) ([]*entity.RepositoryPermission, error) {
+	founds, err := gorm.G[RepositoryPermission](r.db).Where("repo_id = ?", repoID.Uint()).Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*entity.RepositoryPermission, len(founds))
+	for i, f := range founds {
+		result[i] = f.ToEntity()
+	}
+	return result, nil
+}
+
+// Set creates or replaces the permission of a user on a repository.
+func (r *repositoryPermissionRepositoryImpl) Set(ctx context.Context, perm *entity.RepositoryPermission) (*entity.RepositoryPermission, error) {
+	var model RepositoryPermission
+	err := r.db.WithContext(ctx).
+		Where(RepositoryPermission{RepoID: perm.RepoID.Uint(), UserID: perm.UserID.Uint()}).
+		Assign(RepositoryPermission{Access: string(perm.Access)}).
+		FirstOrCreate(&model).Error
+	if err != nil {
+		return nil, err
+	}
+	return model.ToEntity(), nil
+}
+
+// Delete permanently removes the permission so it can be granted again later.
+func (r *repositoryPermissionRepositoryImpl) Delete(ctx context.Context, repoID, userID entity.ID) error {
+	return r.db.WithContext(ctx).Unscoped().
+		Where("repo_id = ? AND user_id = ?", repoID.Uint(), userID.Uint()).
+		Delete(&RepositoryPermission{}).Error
+}
+
+// DeleteByRepo permanently removes the permissions of the repository.
+func (r *repositoryPermissionRepositoryImpl) DeleteByRepo(ctx context.Context, repoID entity.ID) error {
+	return r.db.WithContext(ctx).Unscoped().Where("repo_id = ?", repoID.Uint()).Delete(&RepositoryPermission{}).Error
+}
+
+func NewRepositoryPermissionRepository(i *do.Injector) (RepositoryPermissionRepository, error) {
+	return &repositoryPermissionRepositoryImpl{db: do.MustInvoke[*gorm.DB](i)}, nil
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"gorm.io/gorm"
+)
+
+type RepositoryRedirectRepository interface {
+	GetByName(ctx context.Context, name string) (*entity.RepositoryRedirect, error)
+	ListByRepo(ctx context.Context, repoID entity.ID) ([]*entity.RepositoryRedirect, error)
+	Set(ctx context.Context, name string, repoID entity.ID) error
+	Delete(ctx context.Context, name string) error
+	DeleteByRepo(ctx context.Context, repoID entity.ID) error
+}
+
+type repositoryRedirectRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// GetByName implements RepositoryRedirectRepository.
+func (r *repositoryRedirectRepositoryImpl) GetByName(ctx context.Context, name string) (*entity.RepositoryRedirect, error) {
+	found, err := gorm.G[RepositoryRedirect](r.db).Where("name = ?", name).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.ErrNotFound
+		}
+		return nil, err
+	}
+	return found.ToEntity(), nil
+}
+
+// ListByRepo lists the former names of the repository, oldest first.
+func (r *repositoryRedirectRepositoryImpl) ListByRepo(ctx context.Context, repoID entity.ID) ([]*entity.RepositoryRedirect, error) {
+	founds, err := gorm.G[RepositoryRedirect](r.db).Where("repo_id = ?", repoID.Uint()).Order("id ASC").Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*entity.RepositoryRedirect, len(founds))
+	for i, f := range founds {
+		result[i] = f.ToEntity()
+	}
+	return result, nil
+}
+
+// Set points the name at the repository, replacing where it pointed before.
+func (r *repositoryRedirectRepositoryImpl) Set(ctx context.Context, name string, repoID entity.ID) error {
+	var model RepositoryRedirect
+	return r.db.WithContext(ctx).
+		Where(RepositoryRedirect{Name: name}).
+		Assign(RepositoryRedirect{RepoID: repoID.Uint()}).
+		FirstOrCreate(&model).Error
+}
+
+// Delete permanently removes the redirect of the name, if there is one.
+func (r *repositoryRedirectRepositoryImpl) Delete(ctx context.Context, name string) error {
+	return r.db.WithContext(ctx).Unscoped().Where("name = ?", name).Delete(&RepositoryRedirect{}).Error
+}
+
+// DeleteByRepo permanently removes the redirects to the repository.
+func (r *repositoryRedirectRepositoryImpl) DeleteByRepo(ctx context.Context, repoID entity.ID) error {
+	return r.db.WithContext(ctx).Unscoped().Where("repo_id = ?", repoID.Uint()).Delete(&RepositoryRedirect{}).Error
+}
+
+func NewRepositoryRedirectRepository(i *do.Injector) (RepositoryRedirectRepository, error) {
+	return &repositoryRedirectRepositoryImpl{db: do.MustInvoke[*gorm.DB](i)}, nil
+}
@@ -12,10 +12,23 @@ import (
 type RepositoryRepository interface {
 	Create(ctx context.Context, repo *entity.Repository) (*entity.Repository, error)
 	GetByID(ctx context.Context, id entity.ID) (*entity.Repository, error)
-	GetByName(ctx context.Context, name string) (*entity.Repository, error)
+	// GetByName returns the repository of the full name, <owner>/<name>.
+	GetByName(ctx context.Context, fullName string) (*entity.Repository, error)
 	List(ctx context.Context) ([]*entity.Repository, error)
+	// ListByOwner lists the repositories of the user or organization, an empty owner lists the
+	// repositories that are not migrated to an owner yet.
+	ListByOwner(ctx context.Context, owner string) ([]*entity.Repository, error)
 	Update(ctx context.Context, repo *entity.Repository) (*entity.Repository, error)
+	// Delete soft-deletes the repository, it is hidden from the other methods until it is restored.
 	Delete(ctx context.Context, id entity.ID) error
+	// GetDeletedByName returns the most recently deleted repository of the full name.
+	GetDeletedByName(ctx context.Context, fullName string) (*entity.Repository, error)
+	// ListDeleted lists the deleted repositories, oldest deletion first.
+	ListDeleted(ctx context.Context) ([]*entity.Repository, error)
+	// Restore undoes Delete.
+	Restore(ctx context.Context, id entity.ID) error
+	// Purge permanently removes the row of the repository, deleted or not.
+	Purge(ctx context.Context, id entity.ID) error
 }
 
 type repositoryRepositoryImpl struct {
@@ -37,14 +50,18 @@ func (r *repositoryRepositoryImpl) Create(ctx context.Context, repo *entity.Repo
 func (r *repositoryRepositoryImpl) GetByID(ctx context.Context, id entity.ID) (*entity.Repository, error) {
 	found, err := gorm.G[Repository](r.db).Where("id = ?", id.Uint()).First(ctx)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.ErrNotFound
+		}
 		return nil, err
 	}
 	return found.ToEntity(), nil
 }
 
 // GetByName implements RepoRepository.
-func (r *repositoryRepositoryImpl) GetByName(ctx context.Context, name string) (*entity.Repository, error) {
-	found, err := gorm.G[Repository](r.db).Where("name = ?", name).First(ctx)
+func (r *repositoryRepositoryImpl) GetByName(ctx context.Context, fullName string) (*entity.Repository, error) {
+	owner, name := entity.SplitFullName(fullName)
+	found, err := gorm.G[Repository](r.db).Where("owner = ? AND name = ?", owner, name).First(ctx)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, entity.ErrNotFound
@@ -68,11 +85,29 @@ func (r *repositoryRepositoryImpl) List(ctx context.Context) ([]*entity.Reposito
 	return result, nil
 }
 
+// ListByOwner implements RepoRepository.
+func (r *repositoryRepositoryImpl) ListByOwner(ctx context.Context, owner string) ([]*entity.Repository, error) {
+	founds, err := gorm.G[Repository](r.db).Where("owner = ?", owner).Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*entity.Repository, len(founds))
+	for i, f := range founds {
+		result[i] = f.ToEntity()
+	}
+	return result, nil
+}
+
 // Update implements RepoRepository.
 func (r *repositoryRepositoryImpl) Update(ctx context.Context, repo *entity.Repository) (*entity.Repository, error) {
 	var model Repository
 	model.FromEntity(repo)
-	_, err := gorm.G[Repository](r.db).Where("id = ?", repo.ID.Uint()).Updates(ctx, model)
+	// select the columns explicitly so that fields can be cleared, Updates skips zero values otherwise
+	_, err := gorm.G[Repository](r.db).
+		Where("id = ?", repo.ID.Uint()).
+		Select("owner", "name", "description", "deploy_branch", "latest_sha", "host", "max_previews").
+		Updates(ctx, model)
 	if err != nil {
 		return nil, err
 	}
@@ -86,6 +121,52 @@ func (r *repositoryRepositoryImpl) Delete(ctx context.Context, id entity.ID) err
 	return err
 }
 
+// GetDeletedByName implements RepoRepository.
+func (r *repositoryRepositoryImpl) GetDeletedByName(ctx context.Context, fullName string) (*entity.Repository, error) {
+	owner, name := entity.SplitFullName(fullName)
+	var model Repository
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("owner = ? AND name = ? AND deleted_at IS NOT NULL", owner, name).
+		Order("deleted_at DESC").
+		First(&model).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.ErrNotFound
+		}
+		return nil, err
+	}
+	return model.ToEntity(), nil
+}
+
+// ListDeleted implements RepoRepository.
+func (r *repositoryRepositoryImpl) ListDeleted(ctx context.Context) ([]*entity.Repository, error) {
+	var founds []Repository
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL").
+		Order("deleted_at ASC").
+		Find(&founds).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*entity.Repository, len(founds))
+	for i, f := range founds {
+		result[i] = f.ToEntity()
+	}
+	return result, nil
+}
+
+// Restore implements RepoRepository.
+func (r *repositoryRepositoryImpl) Restore(ctx context.Context, id entity.ID) error {
+	return r.db.WithContext(ctx).Unscoped().Model(&Repository{}).
+		Where("id = ?", id.Uint()).
+		Update("deleted_at", nil).Error
+}
+
+// Purge implements RepoRepository.
+func (r *repositoryRepositoryImpl) Purge(ctx context.Context, id entity.ID) error {
+	return r.db.WithContext(ctx).Unscoped().Where("id = ?", id.Uint()).Delete(&Repository{}).Error
+}
+
 func NewRepositoryRepository(i *do.Injector) (RepositoryRepository, error) {
 	return &repositoryRepositoryImpl{db: do.MustInvoke[*gorm.DB](i)}, nil
 }
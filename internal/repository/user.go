@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"gorm.io/gorm"
+)
+
+type UserRepository interface {
+	Create(ctx context.Context, user *entity.User) (*entity.User, error)
+	GetByID(ctx context.Context, id entity.ID) (*entity.User, error)
+	GetByName(ctx context.Context, name string) (*entity.User, error)
+	List(ctx context.Context) ([]*entity.User, error)
+	Delete(ctx context.Context, id entity.ID) error
+}
+
+type userRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// Create implements UserRepository.
+func (r *userRepositoryImpl) Create(ctx context.Context, user *entity.User) (*entity.User, error) {
+	var model User
+	model.FromEntity(user)
+	if err := gorm.G[User](r.db).Create(ctx, &model); err != nil {
+		return nil, err
+	}
+	return model.ToEntity(), nil
+}
+
+// GetByID implements UserRepository.
+func (r *userRepositoryImpl) GetByID(ctx context.Context, id entity.ID) (*entity.User, error) {
+	found, err := gorm.G[User](r.db).Where("id = ?", id.Uint()).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.ErrNotFound
+		}
+		return nil, err
+	}
+	return found.ToEntity(), nil
+}
+
+// GetByName implements UserRepository.
+func (r *userRepositoryImpl) GetByName(ctx context.Context, name string) (*entity.User, error) {
+	found, err := gorm.G[User](r.db).Where("name = ?", name).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.ErrNotFound
+		}
+		return nil, err
+	}
+	return found.ToEntity(), nil
+}
+
+// List implements UserRepository.
+func (r *userRepositoryImpl) List(ctx context.Context) ([]*entity.User, error) {
+	founds, err := gorm.G[User](r.db).Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*entity.User, len(founds))
+	for i, f := range founds {
+		result[i] = f.ToEntity()
+	}
+	return result, nil
+}
+
+// Delete implements UserRepository.
+func (r *userRepositoryImpl) Delete(ctx context.Context, id entity.ID) error {
+	_, err := gorm.G[User](r.db).Where("id = ?", id.Uint()).Delete(ctx)
+	return err
+}
+
+func NewUserRepository(i *do.Injector) (UserRepository, error) {
+	return &userRepositoryImpl{db: do.MustInvoke[*gorm.DB](i)}, nil
+}
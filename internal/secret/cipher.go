@@ -0,0 +1,91 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the size of the master key, which selects AES-256.
+const KeySize = 32
+
+var (
+	ErrNoMasterKey = errors.New("no master key configured")
+	ErrDecrypt     = errors.New("failed to decrypt value")
+)
+
+// Cipher encrypts values stored at rest with the master key of the server.
+// The associated data binds a ciphertext to where it is stored, so it cannot be moved elsewhere.
+type Cipher interface {
+	Encrypt(plaintext, associatedData []byte) (string, error)
+	Decrypt(ciphertext string, associatedData []byte) ([]byte, error)
+}
+
+type aesGCMCipher struct {
+	aead cipher.AEAD
+}
+
+// Encrypt implements Cipher.
+func (c *aesGCMCipher) Encrypt(plaintext, associatedData []byte) (string, error) {
+	if c.aead == nil {
+		return "", ErrNoMasterKey
+	}
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, plaintext, associatedData)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt implements Cipher.
+func (c *aesGCMCipher) Decrypt(ciphertext string, associatedData []byte) ([]byte, error) {
+	if c.aead == nil {
+		return nil, ErrNoMasterKey
+	}
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, associatedData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// NewCipher returns an AES-GCM cipher using the master key.
+// Without a key, the cipher fails every operation with ErrNoMasterKey.
+func NewCipher(key []byte) (Cipher, error) {
+	if len(key) == 0 {
+		return &aesGCMCipher{}, nil
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aesGCMCipher{aead: aead}, nil
+}
+
+// ParseKey decodes a base64 encoded master key.
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("master key is not valid base64: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
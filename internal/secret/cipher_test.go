@@ -0,0 +1,39 @@
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCipher(t *testing.T) {
+	c, err := NewCipher(bytes.Repeat([]byte{7}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, err := c.Encrypt([]byte("s3cret"), []byte("1/DATABASE_URL"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains([]byte(ciphertext), []byte("s3cret")) {
+		t.Fatalf("ciphertext %q contains the plaintext", ciphertext)
+	}
+
+	plaintext, err := c.Decrypt(ciphertext, []byte("1/DATABASE_URL"))
+	if err != nil || string(plaintext) != "s3cret" {
+		t.Fatalf("Decrypt() = %q, %v; want %q", plaintext, err, "s3cret")
+	}
+
+	if _, err := c.Decrypt(ciphertext, []byte("2/DATABASE_URL")); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("Decrypt() with other associated data: err = %v; want %v", err, ErrDecrypt)
+	}
+
+	noKey, err := NewCipher(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := noKey.Encrypt([]byte("s3cret"), nil); !errors.Is(err, ErrNoMasterKey) {
+		t.Fatalf("Encrypt() without key: err = %v; want %v", err, ErrNoMasterKey)
+	}
+}
@@ -1,7 +1,14 @@
 package routes
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/samber/do"
@@ -11,11 +18,13 @@ import (
 )
 
 func RegisterAPI(injector *do.Injector, e *echo.Echo) {
+	e.Pre(rewriteLegacyRepositoryPaths(injector))
 	api := e.Group("/api")
 
 	api.POST("/check-name", func(c echo.Context) error {
 		type request struct {
-			Name string `json:"name"`
+			Owner string `json:"owner"`
+			Name  string `json:"name"`
 		}
 		var req request
 		if err := c.Bind(&req); err != nil {
@@ -23,16 +32,18 @@ func RegisterAPI(injector *do.Injector, e *echo.Echo) {
 		}
 		name := utils.SanitizeName(req.Name)
 		usecase := do.MustInvoke[usecase.CheckRepositoryNameUsecase](injector)
-		available, err := usecase.Execute(c.Request().Context(), name)
+		available, err := usecase.Execute(c.Request().Context(), entity.JoinFullName(req.Owner, name))
 		if err != nil {
 			return c.NoContent(http.StatusInternalServerError)
 		}
 
 		type response struct {
+			Owner     string `json:"owner"`
 			Name      string `json:"name"`
 			Available bool   `json:"available"`
 		}
 		return c.JSON(http.StatusOK, &response{
+			Owner:     req.Owner,
 			Name:      name,
 			Available: available,
 		})
@@ -40,6 +51,7 @@ func RegisterAPI(injector *do.Injector, e *echo.Echo) {
 	})
 	api.POST("/repositories", func(c echo.Context) error {
 		type request struct {
+			Owner       string `json:"owner"`
 			Name        string `json:"name"`
 			Description string `json:"description"`
 		}
@@ -50,6 +62,7 @@ func RegisterAPI(injector *do.Injector, e *echo.Echo) {
 
 		usecase := do.MustInvoke[usecase.CreateRepositoryUsecase](injector)
 		repo, err := usecase.Execute(c.Request().Context(), &entity.Repository{
+			Owner:       req.Owner,
 			Name:        req.Name,
 			Description: req.Description,
 		})
@@ -67,8 +80,11 @@ func RegisterAPI(injector *do.Injector, e *echo.Echo) {
 	})
 	api.GET("/repositories", func(c echo.Context) error {
 		usecase := do.MustInvoke[usecase.ListRepositoryUsecase](injector)
-		repos, err := usecase.Execute(c.Request().Context())
+		repos, err := usecase.Execute(c.Request().Context(), c.QueryParam("owner"))
 		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
 			return c.NoContent(http.StatusInternalServerError)
 		}
 
@@ -81,8 +97,8 @@ func RegisterAPI(injector *do.Injector, e *echo.Echo) {
 
 		return c.JSON(http.StatusOK, result)
 	})
-	api.GET("/repositories/:name", func(c echo.Context) error {
-		name := c.Param("name")
+	api.GET("/repositories/:owner/:name", func(c echo.Context) error {
+		name := repoFullName(c)
 		usecase := do.MustInvoke[usecase.GetRepositoryByNameUsecase](injector)
 		repo, err := usecase.Execute(c.Request().Context(), name)
 		if err != nil {
@@ -93,4 +109,704 @@ func RegisterAPI(injector *do.Injector, e *echo.Echo) {
 		}
 		return c.JSON(http.StatusOK, repo)
 	})
+	api.PATCH("/repositories/:owner/:name", func(c echo.Context) error {
+		type request struct {
+			Description  *string `json:"description"`
+			DeployBranch *string `json:"deploy_branch"`
+		}
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+
+		name := repoFullName(c)
+		update := do.MustInvoke[usecase.UpdateRepositoryUsecase](injector)
+		repo, err := update.Execute(c.Request().Context(), name, usecase.UpdateRepositoryInput{
+			Description:  req.Description,
+			DeployBranch: req.DeployBranch,
+		})
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			if err == entity.ErrInvalid {
+				return c.NoContent(http.StatusBadRequest)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusOK, repo)
+	}, requireRepoAccess(injector, entity.AccessWrite))
+	api.DELETE("/repositories/:owner/:name", func(c echo.Context) error {
+		purge := false
+		if v := c.QueryParam("purge"); v != "" {
+			var err error
+			if purge, err = strconv.ParseBool(v); err != nil {
+				return c.NoContent(http.StatusBadRequest)
+			}
+		}
+
+		name := repoFullName(c)
+		usecase := do.MustInvoke[usecase.DeleteRepositoryUsecase](injector)
+		if err := usecase.Execute(c.Request().Context(), name, purge); err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			if err == entity.ErrConflict {
+				return c.NoContent(http.StatusConflict)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.NoContent(http.StatusNoContent)
+	}, requireRepoAccess(injector, entity.AccessWrite))
+	api.POST("/repositories/:owner/:name/restore", func(c echo.Context) error {
+		name := repoFullName(c)
+		usecase := do.MustInvoke[usecase.RestoreRepositoryUsecase](injector)
+		repo, err := usecase.Execute(c.Request().Context(), name)
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			if err == entity.ErrConflict {
+				return c.NoContent(http.StatusConflict)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusOK, repo)
+	}, requireDeletedRepoAccess(injector, entity.AccessWrite))
+	api.POST("/repositories/:owner/:name/rename", func(c echo.Context) error {
+		type request struct {
+			Name string `json:"name"`
+		}
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		// only admins and the new owner may move a repository to another namespace
+		user := c.Get("user").(*entity.User)
+		if owner, _ := entity.SplitFullName(req.Name); owner != "" && owner != c.Param("owner") && !user.IsAdmin && user.Name != owner {
+			return c.NoContent(http.StatusForbidden)
+		}
+
+		name := repoFullName(c)
+		usecase := do.MustInvoke[usecase.RenameRepositoryUsecase](injector)
+		repo, err := usecase.Execute(c.Request().Context(), name, req.Name)
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			if err == entity.ErrInvalid {
+				return c.NoContent(http.StatusBadRequest)
+			}
+			if err == entity.ErrConflict {
+				return c.NoContent(http.StatusConflict)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusOK, repo)
+	}, requireRepoAccess(injector, entity.AccessWrite))
+	api.PUT("/repositories/:owner/:name/deploy-branch", func(c echo.Context) error {
+		type request struct {
+			DeployBranch string `json:"deploy_branch"`
+		}
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+
+		name := repoFullName(c)
+		usecase := do.MustInvoke[usecase.UpdateDeployBranchUsecase](injector)
+		repo, err := usecase.Execute(c.Request().Context(), name, req.DeployBranch)
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			if err == entity.ErrInvalid {
+				return c.NoContent(http.StatusBadRequest)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusOK, repo)
+	}, requireRepoAccess(injector, entity.AccessWrite))
+	api.PUT("/repositories/:owner/:name/host", func(c echo.Context) error {
+		type request struct {
+			Host string `json:"host"`
+		}
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+
+		name := repoFullName(c)
+		usecase := do.MustInvoke[usecase.UpdateRepositoryHostUsecase](injector)
+		repo, err := usecase.Execute(c.Request().Context(), name, req.Host)
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			if err == entity.ErrInvalid {
+				return c.NoContent(http.StatusBadRequest)
+			}
+			if err == entity.ErrConflict {
+				return c.NoContent(http.StatusConflict)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusOK, repo)
+	}, requireRepoAccess(injector, entity.AccessWrite))
+	api.PUT("/repositories/:owner/:name/max-previews", func(c echo.Context) error {
+		type request struct {
+			MaxPreviews *int `json:"max_previews"`
+		}
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+
+		name := repoFullName(c)
+		usecase := do.MustInvoke[usecase.UpdateRepositoryMaxPreviewsUsecase](injector)
+		repo, err := usecase.Execute(c.Request().Context(), name, req.MaxPreviews)
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			if err == entity.ErrInvalid {
+				return c.NoContent(http.StatusBadRequest)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusOK, repo)
+	}, requireRepoAccess(injector, entity.AccessWrite))
+	api.GET("/repositories/:owner/:name/env", func(c echo.Context) error {
+		name := repoFullName(c)
+		usecase := do.MustInvoke[usecase.ListEnvVarsUsecase](injector)
+		envs, err := usecase.Execute(c.Request().Context(), name)
+		if err != nil {
+			return c.NoContent(envVarErrorStatus(err))
+		}
+		return c.JSON(http.StatusOK, map[string]any{"env": envs})
+	}, requireRepoAccess(injector, entity.AccessWrite))
+	api.PUT("/repositories/:owner/:name/env/:key", func(c echo.Context) error {
+		type request struct {
+			Value  string `json:"value"`
+			Secret bool   `json:"secret"`
+		}
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+
+		name := repoFullName(c)
+		usecase := do.MustInvoke[usecase.SetEnvVarUsecase](injector)
+		env, err := usecase.Execute(c.Request().Context(), name, c.Param("key"), req.Value, req.Secret)
+		if err != nil {
+			return c.NoContent(envVarErrorStatus(err))
+		}
+		return c.JSON(http.StatusOK, env)
+	}, requireRepoAccess(injector, entity.AccessWrite))
+	api.DELETE("/repositories/:owner/:name/env/:key", func(c echo.Context) error {
+		name := repoFullName(c)
+		usecase := do.MustInvoke[usecase.DeleteEnvVarUsecase](injector)
+		if err := usecase.Execute(c.Request().Context(), name, c.Param("key")); err != nil {
+			return c.NoContent(envVarErrorStatus(err))
+		}
+		return c.NoContent(http.StatusNoContent)
+	}, requireRepoAccess(injector, entity.AccessWrite))
+	api.GET("/repositories/:owner/:name/deployments", func(c echo.Context) error {
+		name := repoFullName(c)
+		usecase := do.MustInvoke[usecase.ListDeploymentsUsecase](injector)
+		deps, err := usecase.Execute(c.Request().Context(), name)
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		type response struct {
+			Deployments []*entity.Deployment `json:"deployments"`
+		}
+
+		result := &response{Deployments: make([]*entity.Deployment, len(deps))}
+		copy(result.Deployments, deps)
+
+		return c.JSON(http.StatusOK, result)
+	})
+	api.POST("/repositories/:owner/:name/deployments", func(c echo.Context) error {
+		type request struct {
+			CommitSHA string `json:"commit_sha"`
+			Clean     bool   `json:"clean"`
+		}
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+
+		name := repoFullName(c)
+		usecase := do.MustInvoke[usecase.TriggerDeploymentUsecase](injector)
+		dep, err := usecase.Execute(c.Request().Context(), name, req.CommitSHA, req.Clean)
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			if err == entity.ErrInvalid {
+				return c.NoContent(http.StatusBadRequest)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusAccepted, dep)
+	}, requireRepoAccess(injector, entity.AccessWrite))
+	registerRefRoutes(injector, api, "branches", entity.RefTypeBranch)
+	registerRefRoutes(injector, api, "tags", entity.RefTypeTag)
+	api.GET("/repositories/:owner/:name/commits", func(c echo.Context) error {
+		opts := usecase.ListCommitsOptions{
+			Ref:    c.QueryParam("ref"),
+			Path:   c.QueryParam("path"),
+			Author: c.QueryParam("author"),
+			Cursor: c.QueryParam("cursor"),
+		}
+		var ok bool
+		if opts.Limit, ok = parsePerPage(c); !ok {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		if opts.Since, ok = parseTime(c, "since"); !ok {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		if opts.Until, ok = parseTime(c, "until"); !ok {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		if v := c.QueryParam("stats"); v != "" {
+			stats, err := strconv.ParseBool(v)
+			if err != nil {
+				return c.NoContent(http.StatusBadRequest)
+			}
+			opts.Stats = stats
+		}
+
+		usecase := do.MustInvoke[usecase.ListCommitsUsecase](injector)
+		list, err := usecase.Execute(c.Request().Context(), repoFullName(c), opts)
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			if err == entity.ErrInvalid {
+				return c.NoContent(http.StatusBadRequest)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		type response struct {
+			Commits    []*entity.Commit `json:"commits"`
+			NextCursor string           `json:"next_cursor,omitempty"`
+		}
+		return c.JSON(http.StatusOK, &response{Commits: list.Commits, NextCursor: list.NextCursor})
+	}, requireRepoAccess(injector, entity.AccessRead))
+	api.GET("/repositories/:owner/:name/commits/:sha", func(c echo.Context) error {
+		usecase := do.MustInvoke[usecase.GetCommitUsecase](injector)
+		commit, err := usecase.Execute(c.Request().Context(), repoFullName(c), c.Param("sha"))
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusOK, commit)
+	}, requireRepoAccess(injector, entity.AccessRead))
+	api.GET("/repositories/:owner/:name/logs", func(c echo.Context) error {
+		opts := usecase.ContainerLogsOptions{
+			Branch: c.QueryParam("branch"),
+			Tail:   c.QueryParam("tail"),
+			Since:  c.QueryParam("since"),
+		}
+		if opts.Tail == "" {
+			opts.Tail = "100"
+		} else if _, err := strconv.ParseUint(opts.Tail, 10, 64); err != nil && opts.Tail != "all" {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		if v := c.QueryParam("follow"); v != "" {
+			follow, err := strconv.ParseBool(v)
+			if err != nil {
+				return c.NoContent(http.StatusBadRequest)
+			}
+			opts.Follow = follow
+		}
+
+		// the headers are sent with the first chunk, so a missing deployment can still be answered with 404
+		res := c.Response()
+		sse := strings.Contains(c.Request().Header.Get(echo.HeaderAccept), "text/event-stream")
+		var stdout, stderr io.Writer
+		var flush func()
+		if sse {
+			out := &sseLineWriter{res: res, event: "stdout"}
+			errOut := &sseLineWriter{res: res, event: "stderr"}
+			stdout, stderr = out, errOut
+			flush = func() { out.flush(); errOut.flush() }
+		} else {
+			w := &chunkWriter{res: res}
+			stdout, stderr = w, w
+			flush = func() {}
+		}
+
+		name := repoFullName(c)
+		usecase := do.MustInvoke[usecase.GetContainerLogsUsecase](injector)
+		err := usecase.Execute(c.Request().Context(), name, opts, stdout, stderr)
+		if res.Committed {
+			flush()
+			return nil
+		}
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if sse {
+			startSSE(res)
+			return nil
+		}
+		return c.NoContent(http.StatusOK)
+	}, requireRepoAccess(injector, entity.AccessRead))
+	api.POST("/organizations", func(c echo.Context) error {
+		type request struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+		}
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+
+		// the creator becomes the first admin of the organization
+		user := c.Get("user").(*entity.User)
+		usecase := do.MustInvoke[usecase.CreateOrganizationUsecase](injector)
+		org, err := usecase.Execute(c.Request().Context(), &entity.Organization{
+			Name:        req.Name,
+			Description: req.Description,
+		}, user.Name)
+		if err != nil {
+			if err == entity.ErrInvalid {
+				return c.NoContent(http.StatusBadRequest)
+			}
+			if err == entity.ErrConflict {
+				return c.NoContent(http.StatusConflict)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusCreated, org)
+	}, requireUser(injector, entity.AccessWrite))
+	api.GET("/organizations", func(c echo.Context) error {
+		usecase := do.MustInvoke[usecase.ListOrganizationsUsecase](injector)
+		orgs, err := usecase.Execute(c.Request().Context())
+		if err != nil {
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusOK, map[string]any{"organizations": orgs})
+	})
+	api.GET("/deployments/:id", func(c echo.Context) error {
+		id, ok := parseID(c.Param("id"))
+		if !ok {
+			return c.NoContent(http.StatusNotFound)
+		}
+		usecase := do.MustInvoke[usecase.GetDeploymentByIdUsecase](injector)
+		dep, err := usecase.Execute(c.Request().Context(), id)
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusOK, dep)
+	})
+	api.POST("/deployments/:id/rollback", func(c echo.Context) error {
+		id, ok := parseID(c.Param("id"))
+		if !ok {
+			return c.NoContent(http.StatusNotFound)
+		}
+		usecase := do.MustInvoke[usecase.RollbackDeploymentUsecase](injector)
+		dep, err := usecase.Execute(c.Request().Context(), id)
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			if err == entity.ErrInvalid {
+				return c.NoContent(http.StatusConflict)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusAccepted, dep)
+	}, requireDeploymentAccess(injector, entity.AccessWrite))
+	api.GET("/deployments/:id/logs", func(c echo.Context) error {
+		id, ok := parseID(c.Param("id"))
+		if !ok {
+			return c.NoContent(http.StatusNotFound)
+		}
+		ctx := c.Request().Context()
+
+		if follow, _ := strconv.ParseBool(c.QueryParam("follow")); !follow {
+			usecase := do.MustInvoke[usecase.GetDeploymentLogUsecase](injector)
+			r, err := usecase.Execute(ctx, id)
+			if err != nil {
+				if err == entity.ErrNotFound {
+					return c.NoContent(http.StatusNotFound)
+				}
+				return c.NoContent(http.StatusInternalServerError)
+			}
+			defer r.Close()
+			return c.Stream(http.StatusOK, echo.MIMETextPlainCharsetUTF8, r)
+		}
+
+		// the deployment was looked up by requireDeploymentAccess, so it exists
+		res := c.Response()
+		startSSE(res)
+
+		followUsecase := do.MustInvoke[usecase.FollowDeploymentLogUsecase](injector)
+		dep, err := followUsecase.Execute(ctx, id, 0, func(line string) error {
+			return writeSSE(res, "", line)
+		})
+		if err != nil {
+			// the client went away or the headers are already sent, nothing left to report
+			return nil
+		}
+		data, err := json.Marshal(dep)
+		if err != nil {
+			return nil
+		}
+		return writeSSE(res, "end", string(data))
+	}, requireDeploymentAccess(injector, entity.AccessRead))
+}
+
+// startSSE sends the headers of a Server-Sent Events response.
+func startSSE(res *echo.Response) {
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+}
+
+// sseLineWriter sends every complete line written to it as an event.
+type sseLineWriter struct {
+	res     *echo.Response
+	event   string
+	partial []byte
+}
+
+func (w *sseLineWriter) Write(p []byte) (int, error) {
+	if !w.res.Committed {
+		startSSE(w.res)
+	}
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(w.partial[:i])
+		w.partial = w.partial[i+1:]
+		if err := writeSSE(w.res, w.event, line); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// flush sends the last line if it was not terminated by a newline.
+func (w *sseLineWriter) flush() {
+	if len(w.partial) > 0 {
+		_ = writeSSE(w.res, w.event, string(w.partial))
+		w.partial = nil
+	}
+}
+
+// chunkWriter streams plain text, flushing after every write.
+type chunkWriter struct {
+	res *echo.Response
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	if !w.res.Committed {
+		w.res.Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+		w.res.WriteHeader(http.StatusOK)
+	}
+	n, err := w.res.Write(p)
+	w.res.Flush()
+	return n, err
+}
+
+// writeSSE writes a single Server-Sent Event and flushes it to the client.
+func writeSSE(res *echo.Response, event, data string) error {
+	if event != "" {
+		if _, err := fmt.Fprintf(res, "event: %s\n", event); err != nil {
+			return err
+		}
+	}
+	// CR and LF end a field in an event stream, so multi-line data becomes multiple data fields
+	data = strings.ReplaceAll(data, "\r", "")
+	for line := range strings.SplitSeq(data, "\n") {
+		if _, err := fmt.Fprintf(res, "data: %s\n", line); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprint(res, "\n"); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
+
+// envVarErrorStatus maps the errors of the env var usecases to a status code.
+func envVarErrorStatus(err error) int {
+	switch err {
+	case entity.ErrNotFound:
+		return http.StatusNotFound
+	case entity.ErrInvalid:
+		return http.StatusBadRequest
+	case entity.ErrUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// registerRefRoutes registers the routes that list, create and delete the branches or tags of a
+// repository at /repositories/:owner/:name/<path>.
+func registerRefRoutes(injector *do.Injector, api *echo.Group, path string, refType entity.RefType) {
+	api.GET("/repositories/:owner/:name/"+path, func(c echo.Context) error {
+		page, ok := parsePage(c)
+		if !ok {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		usecase := do.MustInvoke[usecase.ListRefsUsecase](injector)
+		list, err := usecase.Execute(c.Request().Context(), repoFullName(c), refType, page)
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusOK, map[string]any{path: list.Refs, "total": list.Total})
+	}, requireRepoAccess(injector, entity.AccessRead))
+	api.POST("/repositories/:owner/:name/"+path, func(c echo.Context) error {
+		type request struct {
+			Name      string `json:"name"`
+			CommitSHA string `json:"commit_sha"`
+		}
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+
+		usecase := do.MustInvoke[usecase.CreateRefUsecase](injector)
+		ref, err := usecase.Execute(c.Request().Context(), repoFullName(c), refType, req.Name, req.CommitSHA)
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			if err == entity.ErrInvalid {
+				return c.NoContent(http.StatusBadRequest)
+			}
+			if err == entity.ErrConflict {
+				return c.NoContent(http.StatusConflict)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusCreated, ref)
+	}, requireRepoAccess(injector, entity.AccessWrite))
+	// ref names may contain slashes, e.g. feature/login
+	api.DELETE("/repositories/:owner/:name/"+path+"/*", func(c echo.Context) error {
+		usecase := do.MustInvoke[usecase.DeleteRefUsecase](injector)
+		err := usecase.Execute(c.Request().Context(), repoFullName(c), refType, c.Param("*"))
+		if err != nil {
+			if err == entity.ErrNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+			if err == entity.ErrConflict {
+				return c.NoContent(http.StatusConflict)
+			}
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.NoContent(http.StatusNoContent)
+	}, requireRepoAccess(injector, entity.AccessWrite))
+}
+
+// parsePage reads the page and per_page query parameters, see parsePerPage.
+func parsePage(c echo.Context) (usecase.Page, bool) {
+	page := usecase.Page{Number: 1}
+	if v := c.QueryParam("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return page, false
+		}
+		page.Number = n
+	}
+	var ok bool
+	page.Size, ok = parsePerPage(c)
+	return page, ok
+}
+
+// parsePerPage reads the per_page query parameter, 30 items per page by default and 100 at most.
+func parsePerPage(c echo.Context) (int, bool) {
+	v := c.QueryParam("per_page")
+	if v == "" {
+		return 30, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > 100 {
+		return 0, false
+	}
+	return n, true
+}
+
+// parseTime reads an RFC 3339 timestamp from the query parameter, zero if it is absent.
+func parseTime(c echo.Context, name string) (time.Time, bool) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	return t, err == nil
+}
+
+// rewriteLegacyRepositoryPaths serves the repositories created before owners existed at
+// /api/repositories/<name>/... until they are migrated. Their paths are rewritten to
+// /api/repositories//<name>/..., whose empty owner the repository routes resolve to the repository
+// without an owner. A path whose first segment is a user or organization keeps addressing the
+// repositories of that owner.
+func rewriteLegacyRepositoryPaths(injector *do.Injector) echo.MiddlewareFunc {
+	const prefix = "/api/repositories/"
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			rest, ok := strings.CutPrefix(req.URL.Path, prefix)
+			if !ok || rest == "" || strings.HasPrefix(rest, "/") {
+				return next(c)
+			}
+			if owner, _, nested := strings.Cut(rest, "/"); nested {
+				usecase := do.MustInvoke[usecase.OwnerExistsUsecase](injector)
+				exists, err := usecase.Execute(req.Context(), owner)
+				if err != nil {
+					return c.NoContent(http.StatusInternalServerError)
+				}
+				if exists {
+					return next(c)
+				}
+			}
+			req.URL.Path = prefix + "/" + rest
+			if req.URL.RawPath != "" {
+				req.URL.RawPath = prefix + "/" + strings.TrimPrefix(req.URL.RawPath, prefix)
+			}
+			return next(c)
+		}
+	}
+}
+
+// repoFullName returns the full name of the repository in the request path, <owner>/<name>, or
+// <name> for a repository without an owner.
+func repoFullName(c echo.Context) string {
+	return entity.JoinFullName(c.Param("owner"), c.Param("name"))
+}
+
+// parseID validates a numeric ID taken from the request path.
+func parseID(s string) (entity.ID, bool) {
+	if _, err := strconv.ParseUint(s, 10, 64); err != nil {
+		return "", false
+	}
+	return entity.NewID(s), true
 }
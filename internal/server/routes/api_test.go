@@ -0,0 +1,528 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/queue"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/secret"
+	"github.com/yz4230/githost-poc/internal/storage"
+	"github.com/yz4230/githost-poc/internal/usecase"
+	"gorm.io/gorm"
+)
+
+const bobPassword = "bob-secret"
+
+// setupAPIServer is setupGitServer serving the API too. Besides the admin, it creates the user
+// bob, who has no access to acme/test.
+func setupAPIServer(t *testing.T) (*echo.Echo, *do.Injector) {
+	t.Helper()
+	e, injector, _ := setupGitServerWithInjector(t)
+	do.Provide(injector, func(i *do.Injector) (secret.Cipher, error) { return secret.NewCipher(make([]byte, 32)) })
+	do.Provide(injector, repository.NewEnvVarRepository)
+	do.Provide(injector, repository.NewDeploymentRepository)
+	do.Provide(injector, usecase.NewGetRepositoryByIdUsecase)
+	do.Provide(injector, usecase.NewGetDeploymentByIdUsecase)
+	do.Provide(injector, usecase.NewListDeploymentsUsecase)
+	do.Provide(injector, usecase.NewTriggerDeploymentUsecase)
+	do.Provide(injector, func(i *do.Injector) (queue.DeploymentQueue, error) {
+		// never run, the tests only look at the queued deployments
+		return queue.NewDeploymentQueue(1, do.MustInvoke[repository.DeploymentRepository](i), nil), nil
+	})
+	do.Provide(injector, usecase.NewGetDeletedRepositoryByNameUsecase)
+	do.Provide(injector, usecase.NewOwnerExistsUsecase)
+	do.Provide(injector, usecase.NewListEnvVarsUsecase)
+	do.Provide(injector, usecase.NewSetEnvVarUsecase)
+	do.Provide(injector, usecase.NewListCommitsUsecase)
+	do.Provide(injector, usecase.NewGetCommitUsecase)
+	do.Provide(injector, usecase.NewListRefsUsecase)
+	do.Provide(injector, usecase.NewCreateRefUsecase)
+	do.Provide(injector, usecase.NewUpdateDeployBranchUsecase)
+	do.ProvideValue[deployer.Runtime](injector, deployer.NewFakeRuntime())
+	do.Provide(injector, deployer.NewDeployer)
+	do.Provide(injector, usecase.NewGetContainerLogsUsecase)
+	do.ProvideValue(injector, storage.NewDeploymentLogStorage(t.TempDir()))
+	do.Provide(injector, usecase.NewGetDeploymentLogUsecase)
+	do.Provide(injector, usecase.NewFollowDeploymentLogUsecase)
+	if _, err := do.MustInvoke[usecase.CreateUserUsecase](injector).Execute(t.Context(), "bob", bobPassword, false); err != nil {
+		t.Fatal(err)
+	}
+	RegisterAPI(injector, e)
+	return e, injector
+}
+
+func doAPIRequest(e *echo.Echo, method, target, body, username, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIEnvRequiresWriteAccess(t *testing.T) {
+	e, injector := setupAPIServer(t)
+
+	for _, tc := range []struct {
+		method, target, body string
+	}{
+		{http.MethodGet, "/api/repositories/acme/test/env", ""},
+		{http.MethodPut, "/api/repositories/acme/test/env/LD_PRELOAD", `{"value": "/tmp/evil.so"}`},
+		{http.MethodDelete, "/api/repositories/acme/test/env/TOKEN", ""},
+	} {
+		if rec := doAPIRequest(e, tc.method, tc.target, tc.body, "", ""); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("anonymous %s %s status = %d; want %d", tc.method, tc.target, rec.Code, http.StatusUnauthorized)
+		}
+		if rec := doAPIRequest(e, tc.method, tc.target, tc.body, "bob", bobPassword); rec.Code != http.StatusForbidden {
+			t.Fatalf("%s %s by bob status = %d; want %d", tc.method, tc.target, rec.Code, http.StatusForbidden)
+		}
+	}
+	if rec := doAPIRequest(e, http.MethodGet, "/api/repositories/acme/test/env", "", "bob", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("GET env with a wrong password status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	// write access to the repository is enough
+	if err := do.MustInvoke[usecase.GrantRepositoryPermissionUsecase](injector).Execute(t.Context(), "acme/test", "bob", entity.AccessWrite); err != nil {
+		t.Fatal(err)
+	}
+	if rec := doAPIRequest(e, http.MethodPut, "/api/repositories/acme/test/env/GREETING", `{"value": "hello"}`, "bob", bobPassword); rec.Code != http.StatusOK {
+		t.Fatalf("PUT env by bob with write access status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	rec := doAPIRequest(e, http.MethodGet, "/api/repositories/acme/test/env", "", testUser, testPassword)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "GREETING") {
+		t.Fatalf("GET env by the admin = %d %s; want the variable", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAPIDeleteRepositoryRequiresWriteAccess(t *testing.T) {
+	e, injector := setupAPIServer(t)
+
+	for _, target := range []string{"/api/repositories/acme/test", "/api/repositories/acme/test?purge=true"} {
+		if rec := doAPIRequest(e, http.MethodDelete, target, "", "", ""); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("anonymous DELETE %s status = %d; want %d", target, rec.Code, http.StatusUnauthorized)
+		}
+		if rec := doAPIRequest(e, http.MethodDelete, target, "", "bob", bobPassword); rec.Code != http.StatusForbidden {
+			t.Fatalf("DELETE %s by bob status = %d; want %d", target, rec.Code, http.StatusForbidden)
+		}
+	}
+	if rec := doAPIRequest(e, http.MethodPatch, "/api/repositories/acme/test", `{"description": "x"}`, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous PATCH status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := doAPIRequest(e, http.MethodPost, "/api/repositories/acme/test/restore", "", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous restore status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if _, err := do.MustInvoke[usecase.GetRepositoryByNameUsecase](injector).Execute(t.Context(), "acme/test"); err != nil {
+		t.Fatalf("repository after rejected deletes: %v", err)
+	}
+}
+
+func TestAPIRenameRepositoryRequiresAuth(t *testing.T) {
+	e, injector := setupAPIServer(t)
+
+	if rec := doAPIRequest(e, http.MethodPost, "/api/repositories/acme/test/rename", `{"name": "renamed"}`, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous rename status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+	// write access is not enough to move the repository into the namespace of another user
+	if err := do.MustInvoke[usecase.GrantRepositoryPermissionUsecase](injector).Execute(t.Context(), "acme/test", "bob", entity.AccessWrite); err != nil {
+		t.Fatal(err)
+	}
+	if rec := doAPIRequest(e, http.MethodPost, "/api/repositories/acme/test/rename", `{"name": "admin/test"}`, "bob", bobPassword); rec.Code != http.StatusForbidden {
+		t.Fatalf("transfer to admin by bob status = %d; want %d", rec.Code, http.StatusForbidden)
+	}
+	if _, err := do.MustInvoke[usecase.GetRepositoryByNameUsecase](injector).Execute(t.Context(), "acme/test"); err != nil {
+		t.Fatalf("repository after rejected renames: %v", err)
+	}
+}
+
+func TestAPILegacyRepositoryPaths(t *testing.T) {
+	e, injector := setupAPIServer(t)
+	// a repository created before owners existed, and one whose name is also a user
+	db := do.MustInvoke[*gorm.DB](injector)
+	for _, name := range []string{"legacy", "bob"} {
+		if err := db.Create(&repository.Repository{Name: name, DeployBranch: "main"}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := doAPIRequest(e, http.MethodGet, "/api/repositories/legacy", "", "", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"name":"legacy"`) {
+		t.Fatalf("GET legacy repository = %d %s; want the repository", rec.Code, rec.Body.String())
+	}
+	if rec := doAPIRequest(e, http.MethodGet, "/api/repositories/legacy/env", "", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous GET env of legacy repository status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := doAPIRequest(e, http.MethodGet, "/api/repositories/legacy/env", "", testUser, testPassword); rec.Code != http.StatusOK {
+		t.Fatalf("GET env of legacy repository status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	// owners keep their namespace
+	if rec := doAPIRequest(e, http.MethodGet, "/api/repositories/acme/test", "", "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("GET acme/test status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	if rec := doAPIRequest(e, http.MethodGet, "/api/repositories/bob/env", "", testUser, testPassword); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET bob/env, a repository of bob, status = %d; want %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := doAPIRequest(e, http.MethodGet, "/api/repositories/bob", "", "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("GET legacy repository bob status = %d; want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestAPIAuthenticatesBeforeLookingUpRepository(t *testing.T) {
+	e, _ := setupAPIServer(t)
+
+	// bad credentials do not tell whether the repository exists
+	for _, target := range []string{"/api/repositories/acme/test/env", "/api/repositories/acme/missing/env"} {
+		if rec := doAPIRequest(e, http.MethodGet, target, "", "nobody", "guess"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("GET %s with bad credentials status = %d; want %d", target, rec.Code, http.StatusUnauthorized)
+		}
+	}
+	if rec := doAPIRequest(e, http.MethodGet, "/api/repositories/acme/missing/env", "", testUser, testPassword); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET env of a missing repository status = %d; want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestAPICommitsRequireReadAccess(t *testing.T) {
+	e, injector := setupAPIServer(t)
+	sha := runGit(t, do.MustInvoke[storage.GitStorage](injector).GetRepoDir("acme/test"), "rev-parse", "main")
+
+	for _, target := range []string{"/api/repositories/acme/test/commits", "/api/repositories/acme/test/commits/" + sha} {
+		if rec := doAPIRequest(e, http.MethodGet, target, "", "", ""); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("anonymous GET %s status = %d; want %d", target, rec.Code, http.StatusUnauthorized)
+		}
+		if rec := doAPIRequest(e, http.MethodGet, target, "", "bob", bobPassword); rec.Code != http.StatusForbidden {
+			t.Fatalf("GET %s by bob status = %d; want %d", target, rec.Code, http.StatusForbidden)
+		}
+	}
+	if err := do.MustInvoke[usecase.GrantRepositoryPermissionUsecase](injector).Execute(t.Context(), "acme/test", "bob", entity.AccessRead); err != nil {
+		t.Fatal(err)
+	}
+	rec := doAPIRequest(e, http.MethodGet, "/api/repositories/acme/test/commits/"+sha, "", "bob", bobPassword)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), sha) {
+		t.Fatalf("GET commit by bob with read access = %d %s; want the commit", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAPIRefsRequireAccess(t *testing.T) {
+	e, injector := setupAPIServer(t)
+
+	for _, tc := range []struct {
+		method, target, body string
+	}{
+		{http.MethodGet, "/api/repositories/acme/test/branches", ""},
+		{http.MethodPost, "/api/repositories/acme/test/branches", `{"name": "evil"}`},
+		{http.MethodDelete, "/api/repositories/acme/test/branches/main", ""},
+		{http.MethodGet, "/api/repositories/acme/test/tags", ""},
+		{http.MethodPost, "/api/repositories/acme/test/tags", `{"name": "evil"}`},
+		{http.MethodDelete, "/api/repositories/acme/test/tags/v0.0.1", ""},
+	} {
+		if rec := doAPIRequest(e, tc.method, tc.target, tc.body, "", ""); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("anonymous %s %s status = %d; want %d", tc.method, tc.target, rec.Code, http.StatusUnauthorized)
+		}
+	}
+
+	// read access lists the refs, but does not change them
+	if err := do.MustInvoke[usecase.GrantRepositoryPermissionUsecase](injector).Execute(t.Context(), "acme/test", "bob", entity.AccessRead); err != nil {
+		t.Fatal(err)
+	}
+	if rec := doAPIRequest(e, http.MethodGet, "/api/repositories/acme/test/tags", "", "bob", bobPassword); rec.Code != http.StatusOK {
+		t.Fatalf("GET tags by bob with read access status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	for _, target := range []string{"/api/repositories/acme/test/branches", "/api/repositories/acme/test/tags"} {
+		if rec := doAPIRequest(e, http.MethodPost, target, `{"name": "evil"}`, "bob", bobPassword); rec.Code != http.StatusForbidden {
+			t.Fatalf("POST %s by bob with read access status = %d; want %d", target, rec.Code, http.StatusForbidden)
+		}
+	}
+	if rec := doAPIRequest(e, http.MethodDelete, "/api/repositories/acme/test/tags/v0.0.1", "", "bob", bobPassword); rec.Code != http.StatusForbidden {
+		t.Fatalf("DELETE tag by bob with read access status = %d; want %d", rec.Code, http.StatusForbidden)
+	}
+	if rec := doAPIRequest(e, http.MethodPost, "/api/repositories/acme/test/tags", `{"name": "v1"}`, testUser, testPassword); rec.Code != http.StatusCreated {
+		t.Fatalf("POST tag by the admin status = %d; want %d", rec.Code, http.StatusCreated)
+	}
+}
+
+// createTestDeployment records a deployment of acme/test.
+func createTestDeployment(t *testing.T, injector *do.Injector, status entity.DeploymentStatus) *entity.Deployment {
+	t.Helper()
+	repo, err := do.MustInvoke[usecase.GetRepositoryByNameUsecase](injector).Execute(t.Context(), "acme/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dep, err := do.MustInvoke[repository.DeploymentRepository](injector).Create(t.Context(), &entity.Deployment{
+		RepoID:    repo.ID,
+		Branch:    "main",
+		CommitSHA: strings.Repeat("a", 40),
+		Status:    status,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dep
+}
+
+func TestAPIRollbackRequiresWriteAccess(t *testing.T) {
+	e, injector := setupAPIServer(t)
+	dep := createTestDeployment(t, injector, entity.DeploymentStatusSuccess)
+	target := "/api/deployments/" + dep.ID.String() + "/rollback"
+
+	if rec := doAPIRequest(e, http.MethodPost, target, "", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous rollback status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := doAPIRequest(e, http.MethodPost, "/api/deployments/999/rollback", "", "nobody", "guess"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("rollback of an unknown deployment with bad credentials status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if err := do.MustInvoke[usecase.GrantRepositoryPermissionUsecase](injector).Execute(t.Context(), "acme/test", "bob", entity.AccessRead); err != nil {
+		t.Fatal(err)
+	}
+	if rec := doAPIRequest(e, http.MethodPost, target, "", "bob", bobPassword); rec.Code != http.StatusForbidden {
+		t.Fatalf("rollback by bob with read access status = %d; want %d", rec.Code, http.StatusForbidden)
+	}
+	if rec := doAPIRequest(e, http.MethodPost, "/api/deployments/999/rollback", "", testUser, testPassword); rec.Code != http.StatusNotFound {
+		t.Fatalf("rollback of an unknown deployment status = %d; want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestAPITriggerDeployment(t *testing.T) {
+	e, injector := setupAPIServer(t)
+	sha := runGit(t, do.MustInvoke[storage.GitStorage](injector).GetRepoDir("acme/test"), "rev-parse", "main")
+
+	if rec := doAPIRequest(e, http.MethodPost, "/api/repositories/acme/test/deployments", `{"clean": true}`, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous trigger status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if err := do.MustInvoke[usecase.GrantRepositoryPermissionUsecase](injector).Execute(t.Context(), "acme/test", "bob", entity.AccessRead); err != nil {
+		t.Fatal(err)
+	}
+	if rec := doAPIRequest(e, http.MethodPost, "/api/repositories/acme/test/deployments", `{"clean": true}`, "bob", bobPassword); rec.Code != http.StatusForbidden {
+		t.Fatalf("trigger by bob with read access status = %d; want %d", rec.Code, http.StatusForbidden)
+	}
+	if rec := doAPIRequest(e, http.MethodPost, "/api/repositories/acme/test/deployments", `{"commit_sha": "unknown"}`, testUser, testPassword); rec.Code != http.StatusBadRequest {
+		t.Fatalf("trigger of an unknown commit status = %d; want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec := doAPIRequest(e, http.MethodPost, "/api/repositories/acme/test/deployments", `{"clean": true}`, testUser, testPassword)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("trigger status = %d; want %d", rec.Code, http.StatusAccepted)
+	}
+	var dep entity.Deployment
+	if err := json.Unmarshal(rec.Body.Bytes(), &dep); err != nil {
+		t.Fatal(err)
+	}
+	if dep.Status != entity.DeploymentStatusPending || dep.CommitSHA != sha || !dep.Clean {
+		t.Fatalf("triggered deployment = %+v, want a pending clean deployment of %s", dep, sha)
+	}
+
+	rec = doAPIRequest(e, http.MethodGet, "/api/repositories/acme/test/deployments", "", testUser, testPassword)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"id":"`+dep.ID.String()+`"`) {
+		t.Fatalf("GET deployments = %d %s; want the triggered deployment", rec.Code, rec.Body.String())
+	}
+	rec = doAPIRequest(e, http.MethodGet, "/api/deployments/"+dep.ID.String(), "", testUser, testPassword)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), sha) {
+		t.Fatalf("GET deployment = %d %s; want the triggered deployment", rec.Code, rec.Body.String())
+	}
+	if rec := doAPIRequest(e, http.MethodGet, "/api/deployments/999", "", testUser, testPassword); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET unknown deployment status = %d; want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestAPIRepositorySettingsRequireWriteAccess(t *testing.T) {
+	e, injector := setupAPIServer(t)
+	if err := do.MustInvoke[usecase.GrantRepositoryPermissionUsecase](injector).Execute(t.Context(), "acme/test", "bob", entity.AccessRead); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		target, body string
+	}{
+		{"/api/repositories/acme/test/deploy-branch", `{"deploy_branch": "evil"}`},
+		{"/api/repositories/acme/test/host", `{"host": "evil.example.com"}`},
+		{"/api/repositories/acme/test/max-previews", `{"max_previews": 100}`},
+	} {
+		if rec := doAPIRequest(e, http.MethodPut, tc.target, tc.body, "", ""); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("anonymous PUT %s status = %d; want %d", tc.target, rec.Code, http.StatusUnauthorized)
+		}
+		if rec := doAPIRequest(e, http.MethodPut, tc.target, tc.body, "bob", bobPassword); rec.Code != http.StatusForbidden {
+			t.Fatalf("PUT %s by bob with read access status = %d; want %d", tc.target, rec.Code, http.StatusForbidden)
+		}
+	}
+}
+
+func TestAPICreateOrganization(t *testing.T) {
+	e, injector := setupAPIServer(t)
+
+	body := `{"name": "bobco"}`
+	if rec := doAPIRequest(e, http.MethodPost, "/api/organizations", body, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous POST /api/organizations status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := doAPIRequest(e, http.MethodPost, "/api/organizations", body, "bob", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("POST /api/organizations with a wrong password status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if exists, err := do.MustInvoke[usecase.OwnerExistsUsecase](injector).Execute(t.Context(), "bobco"); err != nil || exists {
+		t.Fatalf("organization exists after unauthenticated requests = %v, %v; want false", exists, err)
+	}
+	if rec := doAPIRequest(e, http.MethodPost, "/api/organizations", body, "bob", bobPassword); rec.Code != http.StatusCreated {
+		t.Fatalf("POST /api/organizations by bob status = %d; want %d", rec.Code, http.StatusCreated)
+	}
+	if rec := doAPIRequest(e, http.MethodPost, "/api/organizations", `{"name": "bob"}`, "bob", bobPassword); rec.Code != http.StatusConflict {
+		t.Fatalf("POST /api/organizations with the name of a user status = %d; want %d", rec.Code, http.StatusConflict)
+	}
+
+	// the creator administers the repositories of the organization, other users do not
+	if _, err := do.MustInvoke[repository.RepositoryRepository](injector).Create(t.Context(), &entity.Repository{Owner: "bobco", Name: "site", DeployBranch: "main"}); err != nil {
+		t.Fatal(err)
+	}
+	target := "/api/repositories/bobco/site/deploy-branch"
+	if rec := doAPIRequest(e, http.MethodPut, target, `{"deploy_branch": "release"}`, "bob", bobPassword); rec.Code != http.StatusOK {
+		t.Fatalf("PUT %s by the organization admin status = %d; want %d", target, rec.Code, http.StatusOK)
+	}
+	if _, err := do.MustInvoke[usecase.CreateUserUsecase](injector).Execute(t.Context(), "carol", "carol-secret", false); err != nil {
+		t.Fatal(err)
+	}
+	if rec := doAPIRequest(e, http.MethodPut, target, `{"deploy_branch": "evil"}`, "carol", "carol-secret"); rec.Code != http.StatusForbidden {
+		t.Fatalf("PUT %s by another user status = %d; want %d", target, rec.Code, http.StatusForbidden)
+	}
+}
+
+// startTestInstance makes the deployment active and starts its container in the fake runtime.
+func startTestInstance(t *testing.T, injector *do.Injector, dep *entity.Deployment) *deployer.FakeRuntime {
+	t.Helper()
+	ctx := t.Context()
+	if err := do.MustInvoke[repository.DeploymentRepository](injector).SetActive(ctx, dep); err != nil {
+		t.Fatal(err)
+	}
+	runtime := do.MustInvoke[deployer.Runtime](injector).(*deployer.FakeRuntime)
+	if err := runtime.Build(ctx, &deployer.BuildRequest{RepoName: "acme/test", CommitSHA: dep.CommitSHA}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := runtime.Start(ctx, &deployer.InstanceSpec{
+		Name:      "acme-test-" + dep.ID.String(),
+		RepoName:  "acme/test",
+		CommitSHA: dep.CommitSHA,
+		Labels:    map[string]string{deployer.LabelEnabled: "true", deployer.LabelDeployment: dep.ID.String()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runtime
+}
+
+func TestAPIContainerLogs(t *testing.T) {
+	e, injector := setupAPIServer(t)
+	runtime := startTestInstance(t, injector, createTestDeployment(t, injector, entity.DeploymentStatusSuccess))
+	runtime.LogsFunc = func(inst *deployer.Instance, opts *deployer.LogsOptions, stdout, stderr io.Writer) error {
+		fmt.Fprintf(stdout, "listening, tail %s\n", opts.Tail)
+		fmt.Fprint(stderr, "SECRET_TOKEN=hunter2")
+		return nil
+	}
+	target := "/api/repositories/acme/test/logs"
+
+	if rec := doAPIRequest(e, http.MethodGet, target, "", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous GET %s status = %d; want %d", target, rec.Code, http.StatusUnauthorized)
+	}
+	if rec := doAPIRequest(e, http.MethodGet, target, "", "bob", bobPassword); rec.Code != http.StatusForbidden {
+		t.Fatalf("GET %s by bob without access status = %d; want %d", target, rec.Code, http.StatusForbidden)
+	}
+	if err := do.MustInvoke[usecase.GrantRepositoryPermissionUsecase](injector).Execute(t.Context(), "acme/test", "bob", entity.AccessRead); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doAPIRequest(e, http.MethodGet, target, "", "bob", bobPassword)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d; want %d", target, rec.Code, http.StatusOK)
+	}
+	if got, want := rec.Body.String(), "listening, tail 100\nSECRET_TOKEN=hunter2"; got != want {
+		t.Fatalf("plain logs = %q; want %q", got, want)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, target+"?tail=5&follow=true", nil)
+	req.Header.Set(echo.HeaderAccept, "text/event-stream")
+	req.SetBasicAuth("bob", bobPassword)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderContentType) != "text/event-stream" {
+		t.Fatalf("SSE logs status = %d, content type %q; want an event stream", rec.Code, rec.Header().Get(echo.HeaderContentType))
+	}
+	// the unterminated last line of stderr is sent once the container output ends
+	want := "event: stdout\ndata: listening, tail 5\n\nevent: stderr\ndata: SECRET_TOKEN=hunter2\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("SSE logs = %q; want %q", got, want)
+	}
+
+	if rec := doAPIRequest(e, http.MethodGet, target+"?tail=some", "", "bob", bobPassword); rec.Code != http.StatusBadRequest {
+		t.Fatalf("GET %s with an invalid tail status = %d; want %d", target, rec.Code, http.StatusBadRequest)
+	}
+	if rec := doAPIRequest(e, http.MethodGet, target+"?branch=feature", "", "bob", bobPassword); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET %s of a branch without a preview status = %d; want %d", target, rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestAPIDeploymentLogs(t *testing.T) {
+	e, injector := setupAPIServer(t)
+	dep := createTestDeployment(t, injector, entity.DeploymentStatusRunning)
+	w, err := do.MustInvoke[storage.DeploymentLogStorage](injector).Create(dep.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	fmt.Fprint(w, "Step 1/1 : ARG TOKEN=hunter2\n")
+	target := "/api/deployments/" + dep.ID.String() + "/logs"
+
+	for _, query := range []string{"", "?follow=true"} {
+		if rec := doAPIRequest(e, http.MethodGet, target+query, "", "", ""); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("anonymous GET %s%s status = %d; want %d", target, query, rec.Code, http.StatusUnauthorized)
+		}
+		if rec := doAPIRequest(e, http.MethodGet, target+query, "", "bob", bobPassword); rec.Code != http.StatusForbidden {
+			t.Fatalf("GET %s%s by bob without access status = %d; want %d", target, query, rec.Code, http.StatusForbidden)
+		}
+	}
+	if rec := doAPIRequest(e, http.MethodGet, "/api/deployments/999/logs", "", testUser, testPassword); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET logs of an unknown deployment status = %d; want %d", rec.Code, http.StatusNotFound)
+	}
+	if err := do.MustInvoke[usecase.GrantRepositoryPermissionUsecase](injector).Execute(t.Context(), "acme/test", "bob", entity.AccessRead); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doAPIRequest(e, http.MethodGet, target, "", "bob", bobPassword)
+	if rec.Code != http.StatusOK || rec.Body.String() != "Step 1/1 : ARG TOKEN=hunter2\n" {
+		t.Fatalf("GET %s = %d %q; want the log written so far", target, rec.Code, rec.Body.String())
+	}
+
+	// following streams the lines written until the deployment finishes, then the deployment
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		fmt.Fprint(w, "Deployment succeeded\n")
+		dep.Status = entity.DeploymentStatusSuccess
+		if _, err := do.MustInvoke[repository.DeploymentRepository](injector).Update(t.Context(), dep); err != nil {
+			t.Error(err)
+		}
+	}()
+	rec = doAPIRequest(e, http.MethodGet, target+"?follow=true", "", "bob", bobPassword)
+	if rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderContentType) != "text/event-stream" {
+		t.Fatalf("GET %s?follow=true status = %d, content type %q; want an event stream", target, rec.Code, rec.Header().Get(echo.HeaderContentType))
+	}
+	events := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n\n"), "\n\n")
+	if len(events) != 3 || events[0] != "data: Step 1/1 : ARG TOKEN=hunter2" || events[1] != "data: Deployment succeeded" {
+		t.Fatalf("followed events = %q; want the two log lines and the end", events)
+	}
+	end, ok := strings.CutPrefix(events[2], "event: end\ndata: ")
+	if !ok {
+		t.Fatalf("last event = %q; want the end event", events[2])
+	}
+	var finished entity.Deployment
+	if err := json.Unmarshal([]byte(end), &finished); err != nil {
+		t.Fatal(err)
+	}
+	if finished.ID != dep.ID || finished.Status != entity.DeploymentStatusSuccess {
+		t.Fatalf("end event deployment = %+v; want %s succeeded", finished, dep.ID)
+	}
+}
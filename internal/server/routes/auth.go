@@ -0,0 +1,132 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/usecase"
+)
+
+// challenge asks the client for HTTP Basic credentials.
+func challenge(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="githost"`)
+	return c.NoContent(http.StatusUnauthorized)
+}
+
+// authError maps an error of AuthenticateUserUsecase or AuthorizeRepositoryAccessUsecase to a response.
+func authError(c echo.Context, err error) error {
+	switch err {
+	case entity.ErrUnauthorized:
+		return challenge(c)
+	case entity.ErrForbidden:
+		return c.NoContent(http.StatusForbidden)
+	}
+	return c.NoContent(http.StatusInternalServerError)
+}
+
+// lookupError maps an error looking up what a request addresses to a response.
+func lookupError(c echo.Context, err error) error {
+	if err == entity.ErrNotFound {
+		return c.NoContent(http.StatusNotFound)
+	}
+	return c.NoContent(http.StatusInternalServerError)
+}
+
+// authenticate verifies the HTTP Basic credentials of the request, a password or a personal
+// access token with a scope covering the required access.
+func authenticate(injector *do.Injector, c echo.Context, required entity.Access) (*entity.User, error) {
+	username, secret, ok := c.Request().BasicAuth()
+	if !ok {
+		return nil, entity.ErrUnauthorized
+	}
+	usecase := do.MustInvoke[usecase.AuthenticateUserUsecase](injector)
+	return usecase.Execute(c.Request().Context(), username, secret, required)
+}
+
+// requireUser authenticates the request and stores the user as "user".
+func requireUser(injector *do.Injector, required entity.Access) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, err := authenticate(injector, c, required)
+			if err != nil {
+				return authError(c, err)
+			}
+			c.Set("user", user)
+			return next(c)
+		}
+	}
+}
+
+// requireRepoAccess authenticates the request and checks that the user has the required access
+// to the repository of the path, see AuthorizeRepositoryAccessUsecase. The repository is only
+// looked up once the credentials are verified, so clients without an account cannot tell which
+// repositories exist. The user is stored as "user".
+func requireRepoAccess(injector *do.Injector, required entity.Access) echo.MiddlewareFunc {
+	return authorizeRepo(injector, required, false)
+}
+
+// requireDeletedRepoAccess is requireRepoAccess for a deleted repository that is not purged yet.
+func requireDeletedRepoAccess(injector *do.Injector, required entity.Access) echo.MiddlewareFunc {
+	return authorizeRepo(injector, required, true)
+}
+
+func authorizeRepo(injector *do.Injector, required entity.Access, deleted bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, err := authenticate(injector, c, required)
+			if err != nil {
+				return authError(c, err)
+			}
+			ctx := c.Request().Context()
+			var repo *entity.Repository
+			if deleted {
+				repo, err = do.MustInvoke[usecase.GetDeletedRepositoryByNameUsecase](injector).Execute(ctx, repoFullName(c))
+			} else {
+				repo, err = do.MustInvoke[usecase.GetRepositoryByNameUsecase](injector).Execute(ctx, repoFullName(c))
+			}
+			if err != nil {
+				return lookupError(c, err)
+			}
+			return authorizeUser(injector, c, next, user, repo, required)
+		}
+	}
+}
+
+// requireDeploymentAccess is requireRepoAccess for the repository of the deployment whose ID is
+// in the path.
+func requireDeploymentAccess(injector *do.Injector, required entity.Access) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, err := authenticate(injector, c, required)
+			if err != nil {
+				return authError(c, err)
+			}
+			id, ok := parseID(c.Param("id"))
+			if !ok {
+				return c.NoContent(http.StatusNotFound)
+			}
+			ctx := c.Request().Context()
+			dep, err := do.MustInvoke[usecase.GetDeploymentByIdUsecase](injector).Execute(ctx, id)
+			if err != nil {
+				return lookupError(c, err)
+			}
+			repo, err := do.MustInvoke[usecase.GetRepositoryByIdUsecase](injector).Execute(ctx, dep.RepoID)
+			if err != nil {
+				return lookupError(c, err)
+			}
+			return authorizeUser(injector, c, next, user, repo, required)
+		}
+	}
+}
+
+// authorizeUser calls next if the authenticated user has the required access to the repository.
+func authorizeUser(injector *do.Injector, c echo.Context, next echo.HandlerFunc, user *entity.User, repo *entity.Repository, required entity.Access) error {
+	usecase := do.MustInvoke[usecase.AuthorizeRepositoryAccessUsecase](injector)
+	if err := usecase.Execute(c.Request().Context(), user, repo, required); err != nil {
+		return authError(c, err)
+	}
+	c.Set("user", user)
+	return next(c)
+}
@@ -2,26 +2,51 @@ package routes
 
 import (
 	"net/http"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
 	"github.com/samber/do"
+	cfg "github.com/yz4230/githost-poc/internal/config"
+	"github.com/yz4230/githost-poc/internal/entity"
 	"github.com/yz4230/githost-poc/internal/git"
 	"github.com/yz4230/githost-poc/internal/storage"
+	"github.com/yz4230/githost-poc/internal/usecase"
 )
 
+// RegisterGitSmartHTTP serves the repositories at /repos/<owner>/<name>.git. Repositories
+// without an owner, and the redirects of repositories migrated to one, are served at
+// /repos/<name>.git.
 func RegisterGitSmartHTTP(injector *do.Injector, e *echo.Echo) {
-	g := e.Group("/repos/:reponame")
+	registerGitRoutes(injector, e.Group("/repos/:owner/:reponame"))
+	registerGitRoutes(injector, e.Group("/repos/:reponame"))
+}
+
+// gitRepoFullName returns the full name of the repository in the request path.
+func gitRepoFullName(c echo.Context) string {
+	return entity.JoinFullName(c.Param("owner"), strings.TrimSuffix(c.Param("reponame"), ".git"))
+}
 
-	// Validate reponame
+func registerGitRoutes(injector *do.Injector, g *echo.Group) {
+	isReceivePack := func(c echo.Context) bool {
+		return strings.HasSuffix(c.Path(), "/"+git.ServiceReceivePack) || c.QueryParam("service") == git.ServiceReceivePack
+	}
+
+	// Validate owner and reponame
 	g.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		reOwner := regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
 		reReponame := regexp.MustCompile(`^[a-zA-Z0-9_-]+\.git$`)
 		return func(c echo.Context) error {
 			ua := c.Request().UserAgent()
 			if !strings.HasPrefix(ua, "git/") {
 				return c.NoContent(http.StatusBadRequest)
 			}
+			if owner := c.Param("owner"); owner != "" && !reOwner.MatchString(owner) {
+				return c.NoContent(http.StatusNotFound)
+			}
 			reponame := c.Param("reponame")
 			if !reReponame.MatchString(reponame) {
 				return c.NoContent(http.StatusNotFound)
@@ -30,21 +55,132 @@ func RegisterGitSmartHTTP(injector *do.Injector, e *echo.Echo) {
 		}
 	})
 
+	// Resolve the repository, following the former names of renamed repositories. Unknown
+	// repositories are not found unless they may be created on push.
+	g.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			config := do.MustInvoke[*cfg.Config](injector)
+			ctx := c.Request().Context()
+			reponame := gitRepoFullName(c)
+			getUsecase := do.MustInvoke[usecase.GetRepositoryByNameUsecase](injector)
+			repo, err := getUsecase.Execute(ctx, reponame)
+			if err == entity.ErrNotFound {
+				redirectUsecase := do.MustInvoke[usecase.GetRepositoryByRedirectUsecase](injector)
+				repo, err = redirectUsecase.Execute(ctx, reponame)
+				if err == nil {
+					zerolog.Ctx(ctx).Warn().Str("repo", repo.FullName()).Str("old_name", reponame).
+						Msg("repository accessed by its old name, the URL is deprecated and should be updated to the new name")
+				}
+			}
+			if err != nil {
+				if err != entity.ErrNotFound {
+					return c.NoContent(http.StatusInternalServerError)
+				}
+				// repositories are only created in the namespace of an owner
+				if !config.CreateOnPush || !isReceivePack(c) || c.Param("owner") == "" {
+					return c.NoContent(http.StatusNotFound)
+				}
+				return next(c)
+			}
+			c.Set("repository", repo)
+			return next(c)
+		}
+	})
+
+	// Authenticate with HTTP Basic credentials (password or personal access token)
+	// and check read access for upload-pack and write access for receive-pack.
+	g.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			challenge := func() error {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="githost"`)
+				return c.NoContent(http.StatusUnauthorized)
+			}
+			username, secret, ok := c.Request().BasicAuth()
+			if !ok {
+				return challenge()
+			}
+
+			required := entity.AccessRead
+			if isReceivePack(c) {
+				required = entity.AccessWrite
+			}
+
+			var user *entity.User
+			var err error
+			if repo, ok := c.Get("repository").(*entity.Repository); ok {
+				usecase := do.MustInvoke[usecase.AuthorizeGitAccessUsecase](injector)
+				user, err = usecase.Execute(c.Request().Context(), username, secret, repo, required)
+			} else {
+				usecase := do.MustInvoke[usecase.AuthenticateUserUsecase](injector)
+				user, err = usecase.Execute(c.Request().Context(), username, secret, required)
+			}
+			if err != nil {
+				if err == entity.ErrUnauthorized {
+					return challenge()
+				}
+				if err == entity.ErrForbidden {
+					return c.NoContent(http.StatusForbidden)
+				}
+				return c.NoContent(http.StatusInternalServerError)
+			}
+			c.Set("user", user)
+			return next(c)
+		}
+	})
+
+	// Create the repository on push when it was not resolved above. Users create repositories in
+	// their own namespace and the namespaces of the organizations they administer, which gives
+	// them full access, and admins in any namespace.
+	g.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if _, ok := c.Get("repository").(*entity.Repository); ok {
+				return next(c)
+			}
+			ctx := c.Request().Context()
+			owner := c.Param("owner")
+			reponame := strings.TrimSuffix(c.Param("reponame"), ".git")
+			user := c.Get("user").(*entity.User)
+			if err := do.MustInvoke[usecase.AuthorizeOwnerUsecase](injector).Execute(ctx, user, owner); err != nil {
+				if err == entity.ErrForbidden {
+					return c.NoContent(http.StatusForbidden)
+				}
+				return c.NoContent(http.StatusInternalServerError)
+			}
+
+			createUsecase := do.MustInvoke[usecase.CreateRepositoryUsecase](injector)
+			repo, err := createUsecase.Execute(ctx, &entity.Repository{Owner: owner, Name: reponame})
+			if err == entity.ErrConflict {
+				getUsecase := do.MustInvoke[usecase.GetRepositoryByNameUsecase](injector)
+				repo, err = getUsecase.Execute(ctx, entity.JoinFullName(owner, reponame))
+			}
+			if err == entity.ErrInvalid {
+				// no user or organization of the name
+				return c.NoContent(http.StatusNotFound)
+			}
+			if err == entity.ErrNotFound {
+				// the bare repository exists on disk without a database row
+				return c.NoContent(http.StatusConflict)
+			}
+			if err != nil {
+				return c.NoContent(http.StatusInternalServerError)
+			}
+			c.Set("repository", repo)
+			return next(c)
+		}
+	})
+
 	g.GET("/info/refs", func(c echo.Context) error {
 		storage := do.MustInvoke[storage.GitStorage](injector)
 
 		req, res := c.Request(), c.Response()
-		reponame := strings.TrimSuffix(c.Param("reponame"), ".git")
-		repodir := storage.GetRepoDir(reponame)
-
-		if err := storage.EnsureBareRepo(req.Context(), reponame); err != nil {
-			return c.NoContent(http.StatusInternalServerError)
-		}
+		repo := c.Get("repository").(*entity.Repository)
+		repodir := storage.GetRepoDir(repo.FullName())
 
 		service := c.QueryParam("service")
+		gitProtocol := git.SanitizeGitProtocol(req.Header.Get("Git-Protocol"))
 		res.Header().Set("Content-Type", "application/x-"+service+"-advertisement")
 		res.Header().Set("Cache-Control", "no-cache")
-		if err := git.AdvertiseRefs(req.Context(), service, repodir, res.Writer); err != nil {
+		if err := git.AdvertiseRefs(req.Context(), service, repodir, gitProtocol, res.Writer); err != nil {
 			return c.NoContent(http.StatusInternalServerError)
 		}
 		return nil
@@ -52,15 +188,26 @@ func RegisterGitSmartHTTP(injector *do.Injector, e *echo.Echo) {
 
 	smartHandler := func(service string) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			config := do.MustInvoke[*cfg.Config](injector)
 			storage := do.MustInvoke[storage.GitStorage](injector)
 
 			req, res := c.Request(), c.Response()
-			reponame := strings.TrimSuffix(c.Param("reponame"), ".git")
-			repodir := storage.GetRepoDir(reponame)
+			repo := c.Get("repository").(*entity.Repository)
+			repodir := storage.GetRepoDir(repo.FullName())
+
+			dataDir, err := filepath.Abs(config.Root)
+			if err != nil {
+				return c.NoContent(http.StatusInternalServerError)
+			}
+			env := []string{
+				cfg.EnvDataDir + "=" + dataDir,
+				cfg.EnvMaxPreviews + "=" + strconv.Itoa(config.MaxPreviews),
+			}
 
+			gitProtocol := git.SanitizeGitProtocol(req.Header.Get("Git-Protocol"))
 			res.Header().Set("Content-Type", "application/x-"+service+"-result")
 			res.Header().Set("Cache-Control", "no-cache")
-			if err := git.ExecStatelessRPC(req.Context(), service, repodir, req.Body, res.Writer); err != nil {
+			if err := git.ExecStatelessRPC(req.Context(), service, repodir, gitProtocol, env, req.Body, res.Writer); err != nil {
 				return c.NoContent(http.StatusInternalServerError)
 			}
 			return nil
@@ -0,0 +1,423 @@
+package routes
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/config"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+	"github.com/yz4230/githost-poc/internal/usecase"
+	"gorm.io/gorm"
+)
+
+const (
+	testUser     = "admin"
+	testPassword = "secret"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func newTestInjector(t *testing.T, root string, cfg *config.Config) *do.Injector {
+	t.Helper()
+	db, err := repository.NewSQLiteDB(filepath.Join(root, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	injector := do.New()
+	do.ProvideValue(injector, cfg)
+	do.ProvideValue(injector, db)
+	do.ProvideValue(injector, storage.NewGitStorage(filepath.Join(root, "repositories"), zerolog.Nop()))
+	do.Provide(injector, repository.NewRepositoryRepository)
+	do.Provide(injector, repository.NewUserRepository)
+	do.Provide(injector, repository.NewAccessTokenRepository)
+	do.Provide(injector, repository.NewRepositoryPermissionRepository)
+	do.Provide(injector, repository.NewRepositoryRedirectRepository)
+	do.Provide(injector, repository.NewOrganizationRepository)
+	do.Provide(injector, repository.NewOrganizationMemberRepository)
+	do.Provide(injector, usecase.NewCreateRepositoryUsecase)
+	do.Provide(injector, usecase.NewGetRepositoryByNameUsecase)
+	do.Provide(injector, usecase.NewGetRepositoryByRedirectUsecase)
+	do.Provide(injector, usecase.NewCreateUserUsecase)
+	do.Provide(injector, usecase.NewCreateAccessTokenUsecase)
+	do.Provide(injector, usecase.NewGrantRepositoryPermissionUsecase)
+	do.Provide(injector, usecase.NewAuthenticateUserUsecase)
+	do.Provide(injector, usecase.NewCreateOrganizationUsecase)
+	do.Provide(injector, usecase.NewAuthorizeOwnerUsecase)
+	do.Provide(injector, usecase.NewAuthorizeRepositoryAccessUsecase)
+	do.Provide(injector, usecase.NewAuthorizeGitAccessUsecase)
+	return injector
+}
+
+// setupGitServer creates a bare repository "acme/test" with a single commit on main
+// plus a large number of tags, and returns an echo instance serving it along with the commit SHA.
+func setupGitServer(t *testing.T) (*echo.Echo, string) {
+	e, _, sha := setupGitServerWithInjector(t)
+	return e, sha
+}
+
+func setupGitServerWithInjector(t *testing.T) (*echo.Echo, *do.Injector, string) {
+	t.Helper()
+	root := t.TempDir()
+	injector := newTestInjector(t, root, &config.Config{Root: root})
+	gitStorage := do.MustInvoke[storage.GitStorage](injector)
+
+	work := filepath.Join(root, "work")
+	runGit(t, root, "init", "--initial-branch=main", work)
+	runGit(t, work, "commit", "--allow-empty", "-m", "initial")
+	for i := range 50 {
+		runGit(t, work, "tag", fmt.Sprintf("v0.0.%d", i))
+	}
+	sha := runGit(t, work, "rev-parse", "HEAD")
+	runGit(t, root, "clone", "--bare", work, gitStorage.GetRepoDir("acme/test"))
+
+	ctx := t.Context()
+	if _, err := do.MustInvoke[repository.OrganizationRepository](injector).Create(ctx, &entity.Organization{Name: "acme"}); err != nil {
+		t.Fatal(err)
+	}
+	db := do.MustInvoke[*gorm.DB](injector)
+	if err := db.Create(&repository.Repository{Owner: "acme", Name: "test", DeployBranch: "main"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := do.MustInvoke[usecase.CreateUserUsecase](injector).Execute(ctx, testUser, testPassword, true); err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	RegisterGitSmartHTTP(injector, e)
+	return e, injector, sha
+}
+
+func pktLine(s string) string {
+	return fmt.Sprintf("%04x%s", len(s)+4, s)
+}
+
+func doGitRequest(e *echo.Echo, method, target, body string, v2 bool) *httptest.ResponseRecorder {
+	return doGitRequestAs(e, method, target, body, v2, testUser, testPassword)
+}
+
+func doGitRequestAs(e *echo.Echo, method, target, body string, v2 bool, username, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("User-Agent", "git/2.39.5")
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	if v2 {
+		req.Header.Set("Git-Protocol", "version=2")
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestInfoRefsProtocolV0(t *testing.T) {
+	e, sha := setupGitServer(t)
+
+	rec := doGitRequest(e, http.MethodGet, "/repos/acme/test.git/info/refs?service=git-upload-pack", "", false)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, "001e# service=git-upload-pack\n0000") {
+		t.Errorf("missing service preamble: %q", body)
+	}
+	if !strings.Contains(body, sha+" refs/heads/main") {
+		t.Errorf("missing refs/heads/main advertisement: %q", body)
+	}
+}
+
+func TestInfoRefsProtocolV2(t *testing.T) {
+	e, _ := setupGitServer(t)
+
+	rec := doGitRequest(e, http.MethodGet, "/repos/acme/test.git/info/refs?service=git-upload-pack", "", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "# service=") {
+		t.Errorf("unexpected service preamble in v2 advertisement: %q", body)
+	}
+	if !strings.HasPrefix(body, pktLine("version 2\n")) {
+		t.Errorf("missing version 2 capability advertisement: %q", body)
+	}
+	for _, capability := range []string{"ls-refs", "fetch"} {
+		if !strings.Contains(body, capability) {
+			t.Errorf("missing %s capability: %q", capability, body)
+		}
+	}
+}
+
+func TestUploadPackProtocolV2LsRefs(t *testing.T) {
+	e, sha := setupGitServer(t)
+
+	var reqBody bytes.Buffer
+	reqBody.WriteString(pktLine("command=ls-refs\n"))
+	reqBody.WriteString("0001")
+	reqBody.WriteString(pktLine("peel\n"))
+	reqBody.WriteString(pktLine("ref-prefix refs/heads/\n"))
+	reqBody.WriteString("0000")
+
+	rec := doGitRequest(e, http.MethodPost, "/repos/acme/test.git/git-upload-pack", reqBody.String(), true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	want := pktLine(sha+" refs/heads/main\n") + "0000"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("ls-refs response = %q; want %q", got, want)
+	}
+}
+
+func TestUploadPackProtocolV2Fetch(t *testing.T) {
+	e, sha := setupGitServer(t)
+
+	var reqBody bytes.Buffer
+	reqBody.WriteString(pktLine("command=fetch\n"))
+	reqBody.WriteString("0001")
+	reqBody.WriteString(pktLine("no-progress\n"))
+	reqBody.WriteString(pktLine("want " + sha + "\n"))
+	reqBody.WriteString(pktLine("done\n"))
+	reqBody.WriteString("0000")
+
+	rec := doGitRequest(e, http.MethodPost, "/repos/acme/test.git/git-upload-pack", reqBody.String(), true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, pktLine("packfile\n")) {
+		t.Errorf("missing packfile section: %q", body)
+	}
+	if !strings.Contains(body, "PACK") {
+		t.Errorf("missing pack data: %q", body)
+	}
+}
+
+func TestGitAuthChallenge(t *testing.T) {
+	e, _ := setupGitServer(t)
+
+	rec := doGitRequestAs(e, http.MethodGet, "/repos/acme/test.git/info/refs?service=git-upload-pack", "", false, "", "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if got := rec.Header().Get("WWW-Authenticate"); !strings.HasPrefix(got, "Basic ") {
+		t.Errorf("WWW-Authenticate = %q; want Basic challenge", got)
+	}
+
+	rec = doGitRequestAs(e, http.MethodGet, "/repos/acme/test.git/info/refs?service=git-upload-pack", "", false, testUser, "wrong")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status with wrong password = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestGitAuthPermissions(t *testing.T) {
+	e, injector, _ := setupGitServerWithInjector(t)
+	ctx := t.Context()
+
+	if _, err := do.MustInvoke[usecase.CreateUserUsecase](injector).Execute(ctx, "reader", "pw", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := do.MustInvoke[usecase.CreateUserUsecase](injector).Execute(ctx, "stranger", "pw", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := do.MustInvoke[usecase.GrantRepositoryPermissionUsecase](injector).Execute(ctx, "acme/test", "reader", entity.AccessRead); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		user     string
+		password string
+		service  string
+		want     int
+	}{
+		{"reader", "pw", "git-upload-pack", http.StatusOK},
+		{"reader", "pw", "git-receive-pack", http.StatusForbidden},
+		{"stranger", "pw", "git-upload-pack", http.StatusForbidden},
+		{testUser, testPassword, "git-receive-pack", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user+"/"+tt.service, func(t *testing.T) {
+			rec := doGitRequestAs(e, http.MethodGet, "/repos/acme/test.git/info/refs?service="+tt.service, "", false, tt.user, tt.password)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d; want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	rec := doGitRequestAs(e, http.MethodPost, "/repos/acme/test.git/git-receive-pack", "0000", false, "reader", "pw")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("receive-pack status = %d; want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestGitAuthAccessToken(t *testing.T) {
+	e, injector, _ := setupGitServerWithInjector(t)
+	ctx := t.Context()
+
+	createToken := do.MustInvoke[usecase.CreateAccessTokenUsecase](injector)
+	_, readToken, err := createToken.Execute(ctx, testUser, "read", []entity.TokenScope{entity.TokenScopeRepoRead}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, expiredToken, err := createToken.Execute(ctx, testUser, "expired", []entity.TokenScope{entity.TokenScopeRepoWrite}, time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		service string
+		want    int
+	}{
+		{"read scope allows upload-pack", readToken, "git-upload-pack", http.StatusOK},
+		{"read scope denies receive-pack", readToken, "git-receive-pack", http.StatusForbidden},
+		{"expired token", expiredToken, "git-upload-pack", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doGitRequestAs(e, http.MethodGet, "/repos/acme/test.git/info/refs?service="+tt.service, "", false, testUser, tt.token)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d; want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitUnknownRepository(t *testing.T) {
+	e, _ := setupGitServer(t)
+
+	for _, service := range []string{"git-upload-pack", "git-receive-pack"} {
+		rec := doGitRequest(e, http.MethodGet, "/repos/acme/typo.git/info/refs?service="+service, "", false)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s status = %d; want %d", service, rec.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestGitRenamedRepository(t *testing.T) {
+	e, injector, sha := setupGitServerWithInjector(t)
+	repo, err := do.MustInvoke[usecase.GetRepositoryByNameUsecase](injector).Execute(t.Context(), "acme/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirects := do.MustInvoke[repository.RepositoryRedirectRepository](injector)
+	// a former name in the namespace, and the name from before the repository had an owner
+	for _, name := range []string{"acme/old", "legacy"} {
+		if err := redirects.Set(t.Context(), name, repo.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, target := range []string{"/repos/acme/old.git", "/repos/legacy.git"} {
+		rec := doGitRequest(e, http.MethodGet, target+"/info/refs?service=git-upload-pack", "", false)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s status = %d; want %d", target, rec.Code, http.StatusOK)
+		}
+		if !strings.Contains(rec.Body.String(), sha+" refs/heads/main") {
+			t.Errorf("%s does not serve the renamed repository: %q", target, rec.Body.String())
+		}
+	}
+}
+
+func TestGitRepositoryWithoutOwner(t *testing.T) {
+	e, injector, _ := setupGitServerWithInjector(t)
+	root := do.MustInvoke[*config.Config](injector).Root
+	flat := do.MustInvoke[storage.GitStorage](injector).GetRepoDir("flat")
+	runGit(t, root, "init", "--bare", flat)
+	if err := do.MustInvoke[*gorm.DB](injector).Create(&repository.Repository{Name: "flat", DeployBranch: "main"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doGitRequest(e, http.MethodGet, "/repos/flat.git/info/refs?service=git-upload-pack", "", false)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	if filepath.Dir(flat) != filepath.Join(root, "repositories") {
+		t.Errorf("repository without an owner is stored at %s, want directly in the repositories directory", flat)
+	}
+}
+
+func TestGitCreateOnPush(t *testing.T) {
+	root := t.TempDir()
+	injector := newTestInjector(t, root, &config.Config{Root: root, CreateOnPush: true})
+	ctx := t.Context()
+	for _, name := range []string{"alice", "bob"} {
+		if _, err := do.MustInvoke[usecase.CreateUserUsecase](injector).Execute(ctx, name, "pw", false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := do.MustInvoke[usecase.CreateOrganizationUsecase](injector).Execute(ctx, &entity.Organization{Name: "acme"}, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	e := echo.New()
+	RegisterGitSmartHTTP(injector, e)
+
+	rec := doGitRequestAs(e, http.MethodGet, "/repos/alice/fresh.git/info/refs?service=git-upload-pack", "", false, "alice", "pw")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("upload-pack status = %d; want %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec = doGitRequestAs(e, http.MethodGet, "/repos/alice/fresh.git/info/refs?service=git-receive-pack", "", false, "alice", "pw")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("receive-pack status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	repo, err := do.MustInvoke[usecase.GetRepositoryByNameUsecase](injector).Execute(ctx, "alice/fresh")
+	if err != nil {
+		t.Fatalf("repository row not created: %v", err)
+	}
+	if !do.MustInvoke[storage.GitStorage](injector).IsRepoExist(repo.FullName()) {
+		t.Error("bare repository not created")
+	}
+
+	rec = doGitRequestAs(e, http.MethodGet, "/repos/alice/fresh.git/info/refs?service=git-upload-pack", "", false, "alice", "pw")
+	if rec.Code != http.StatusOK {
+		t.Errorf("upload-pack status after create = %d; want %d", rec.Code, http.StatusOK)
+	}
+	rec = doGitRequestAs(e, http.MethodGet, "/repos/alice/fresh.git/info/refs?service=git-upload-pack", "", false, "bob", "pw")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("upload-pack status of another user = %d; want %d", rec.Code, http.StatusForbidden)
+	}
+
+	// users only create repositories in their own namespace and in the organizations they
+	// administer, and every repository has an owner
+	tests := []struct {
+		user   string
+		target string
+		want   int
+	}{
+		{"alice", "/repos/bob/fresh.git", http.StatusForbidden},
+		{"alice", "/repos/acme/fresh.git", http.StatusForbidden},
+		{"alice", "/repos/fresh.git", http.StatusNotFound},
+		{"bob", "/repos/acme/fresh.git", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := doGitRequestAs(e, http.MethodGet, tt.target+"/info/refs?service=git-receive-pack", "", false, tt.user, "pw")
+		if rec.Code != tt.want {
+			t.Errorf("%s receive-pack status by %s = %d; want %d", tt.target, tt.user, rec.Code, tt.want)
+		}
+	}
+	// the admin of the organization has full access to the repository created in it
+	rec = doGitRequestAs(e, http.MethodGet, "/repos/acme/fresh.git/info/refs?service=git-receive-pack", "", false, "bob", "pw")
+	if rec.Code != http.StatusOK {
+		t.Errorf("receive-pack status of the organization admin after create = %d; want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -2,29 +2,44 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/rs/zerolog"
 	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/config"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/proxy"
+	"github.com/yz4230/githost-poc/internal/queue"
 	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/secret"
 	"github.com/yz4230/githost-poc/internal/server/routes"
 	"github.com/yz4230/githost-poc/internal/storage"
 	"github.com/yz4230/githost-poc/internal/usecase"
 	"gorm.io/gorm"
 )
 
-type Config struct {
-	Root   string
-	Port   int
-	Logger zerolog.Logger
-}
+type Config = config.Config
+type HealthCheckConfig = config.HealthCheckConfig
+
+const EnvMasterKey = config.EnvMasterKey
+
+// purgeInterval is how often repositories whose restore window has passed are purged.
+const purgeInterval = 10 * time.Minute
 
 type Server struct {
-	e      *echo.Echo
-	config *Config
+	e        *echo.Echo
+	proxy    *http.Server
+	config   *Config
+	injector *do.Injector
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
 }
 
 func New(config *Config) *Server {
@@ -74,26 +89,105 @@ func New(config *Config) *Server {
 }
 
 func (s *Server) init() {
+	s.injector = NewInjector(s.config)
+	s.registerRoutes(s.injector)
+}
+
+// NewInjector wires the dependencies for the data directory in config.
+// It is shared by the server and the CLI commands that operate on the same data.
+func NewInjector(config *Config) *do.Injector {
 	injector := do.New()
-	s.injectDependencies(injector)
-	s.registerRoutes(injector)
+	injectDependencies(injector, config)
+	return injector
 }
 
-func (s *Server) injectDependencies(injector *do.Injector) {
+func injectDependencies(injector *do.Injector, config *Config) {
+	do.ProvideValue(injector, config)
 	do.Provide(injector, func(i *do.Injector) (*gorm.DB, error) {
-		filename := filepath.Join(s.config.Root, "data.db")
+		filename := filepath.Join(config.Root, "data.db")
 		return repository.NewSQLiteDB(filename)
 	})
 	do.Provide(injector, func(i *do.Injector) (storage.GitStorage, error) {
-		root := filepath.Join(s.config.Root, "repositories")
-		return storage.NewGitStorage(root, s.config.Logger), nil
+		root := filepath.Join(config.Root, "repositories")
+		return storage.NewGitStorage(root, config.Logger), nil
+	})
+	do.Provide(injector, func(i *do.Injector) (storage.DeploymentLogStorage, error) {
+		root := filepath.Join(config.Root, "logs", "deployments")
+		return storage.NewDeploymentLogStorage(root), nil
+	})
+	do.Provide(injector, func(i *do.Injector) (secret.Cipher, error) {
+		return secret.NewCipher(config.MasterKey)
 	})
+	do.Provide(injector, deployer.NewRuntime)
+	do.Provide(injector, deployer.NewDeployer)
+	do.Provide(injector, proxy.NewRouter)
 	do.Provide(injector, repository.NewRepositoryRepository)
+	do.Provide(injector, repository.NewDeploymentRepository)
+	do.Provide(injector, repository.NewUserRepository)
+	do.Provide(injector, repository.NewAccessTokenRepository)
+	do.Provide(injector, repository.NewRepositoryPermissionRepository)
+	do.Provide(injector, repository.NewEnvVarRepository)
+	do.Provide(injector, repository.NewRepositoryRedirectRepository)
+	do.Provide(injector, repository.NewOrganizationRepository)
+	do.Provide(injector, repository.NewOrganizationMemberRepository)
 	do.Provide(injector, usecase.NewCreateRepositoryUsecase)
 	do.Provide(injector, usecase.NewListRepositoryUsecase)
 	do.Provide(injector, usecase.NewCheckRepositoryNameUsecase)
 	do.Provide(injector, usecase.NewGetRepositoryByIdUsecase)
 	do.Provide(injector, usecase.NewGetRepositoryByNameUsecase)
+	do.Provide(injector, usecase.NewGetDeletedRepositoryByNameUsecase)
+	do.Provide(injector, usecase.NewOwnerExistsUsecase)
+	do.Provide(injector, usecase.NewGetRepositoryByRedirectUsecase)
+	do.Provide(injector, usecase.NewUpdateDeployBranchUsecase)
+	do.Provide(injector, usecase.NewUpdateRepositoryMaxPreviewsUsecase)
+	do.Provide(injector, usecase.NewUpdateRepositoryUsecase)
+	do.Provide(injector, usecase.NewDeleteRepositoryUsecase)
+	do.Provide(injector, usecase.NewRestoreRepositoryUsecase)
+	do.Provide(injector, usecase.NewPurgeDeletedRepositoriesUsecase)
+	do.Provide(injector, usecase.NewRenameRepositoryUsecase)
+	do.Provide(injector, usecase.NewMigrateRepositoryOwnersUsecase)
+	do.Provide(injector, usecase.NewUpdateRepositoryHooksUsecase)
+	do.Provide(injector, usecase.NewCreateDeploymentUsecase)
+	do.Provide(injector, usecase.NewCreatePreviewDeploymentUsecase)
+	do.Provide(injector, usecase.NewTeardownPreviewUsecase)
+	do.Provide(injector, usecase.NewUpdateDeploymentStatusUsecase)
+	do.Provide(injector, usecase.NewRunDeploymentUsecase)
+	do.Provide(injector, usecase.NewTriggerDeploymentUsecase)
+	do.Provide(injector, usecase.NewListDeploymentsUsecase)
+	do.Provide(injector, usecase.NewListRefsUsecase)
+	do.Provide(injector, usecase.NewCreateRefUsecase)
+	do.Provide(injector, usecase.NewDeleteRefUsecase)
+	do.Provide(injector, usecase.NewListCommitsUsecase)
+	do.Provide(injector, usecase.NewGetCommitUsecase)
+	do.Provide(injector, usecase.NewGetDeploymentByIdUsecase)
+	do.Provide(injector, usecase.NewGetDeploymentLogUsecase)
+	do.Provide(injector, usecase.NewFollowDeploymentLogUsecase)
+	do.Provide(injector, usecase.NewGetContainerLogsUsecase)
+	do.Provide(injector, usecase.NewRollbackDeploymentUsecase)
+	do.Provide(injector, usecase.NewRollbackRepositoryUsecase)
+	do.Provide(injector, usecase.NewUpdateRepositoryHostUsecase)
+	do.Provide(injector, usecase.NewListEnvVarsUsecase)
+	do.Provide(injector, usecase.NewSetEnvVarUsecase)
+	do.Provide(injector, usecase.NewDeleteEnvVarUsecase)
+	do.Provide(injector, usecase.NewPruneImagesUsecase)
+	do.Provide(injector, func(i *do.Injector) (queue.DeploymentQueue, error) {
+		return queue.NewDeploymentQueue(
+			config.DeployWorkers,
+			do.MustInvoke[repository.DeploymentRepository](i),
+			do.MustInvoke[usecase.RunDeploymentUsecase](i),
+		), nil
+	})
+	do.Provide(injector, usecase.NewCreateUserUsecase)
+	do.Provide(injector, usecase.NewCreateOrganizationUsecase)
+	do.Provide(injector, usecase.NewListOrganizationsUsecase)
+	do.Provide(injector, usecase.NewCreateAccessTokenUsecase)
+	do.Provide(injector, usecase.NewListAccessTokensUsecase)
+	do.Provide(injector, usecase.NewRevokeAccessTokenUsecase)
+	do.Provide(injector, usecase.NewGrantRepositoryPermissionUsecase)
+	do.Provide(injector, usecase.NewAuthenticateUserUsecase)
+	do.Provide(injector, usecase.NewAuthorizeOwnerUsecase)
+	do.Provide(injector, usecase.NewAuthorizeRepositoryAccessUsecase)
+	do.Provide(injector, usecase.NewAuthorizeGitAccessUsecase)
 }
 
 func (s *Server) registerRoutes(injector *do.Injector) {
@@ -103,11 +197,112 @@ func (s *Server) registerRoutes(injector *do.Injector) {
 }
 
 func (s *Server) Start() error {
+	ctx, cancel := context.WithCancel(s.config.Logger.WithContext(context.Background()))
+	s.cancel = cancel
+	// pushes to repositories with outdated hooks would not deploy
+	hooks, err := do.MustInvoke[usecase.UpdateRepositoryHooksUsecase](s.injector).Execute(ctx)
+	if err != nil {
+		s.config.Logger.Error().Err(err).Msg("failed to update repository hooks")
+	}
+	if len(hooks) > 0 {
+		s.config.Logger.Info().Int("repositories", len(hooks)).Msg("updated repository hooks")
+	}
+	deploymentQueue := do.MustInvoke[queue.DeploymentQueue](s.injector)
+	s.wg.Go(func() {
+		deploymentQueue.Run(ctx)
+	})
+
+	if s.config.ProxyPort != 0 {
+		router := do.MustInvoke[proxy.Router](s.injector)
+		s.wg.Go(func() {
+			router.Run(ctx)
+		})
+		s.proxy = &http.Server{Addr: fmt.Sprintf(":%d", s.config.ProxyPort), Handler: router}
+		s.wg.Go(func() {
+			s.config.Logger.Info().Str("addr", s.proxy.Addr).Str("apps_domain", s.config.AppsDomain).Msg("starting reverse proxy")
+			if err := s.proxy.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.config.Logger.Error().Err(err).Msg("reverse proxy error")
+			}
+		})
+	}
+
+	if s.config.PruneInterval > 0 {
+		s.wg.Go(func() {
+			s.runPrune(ctx)
+		})
+	}
+
+	s.wg.Go(func() {
+		s.runPurge(ctx)
+	})
+
+	// repositories created before owners existed keep working until they are migrated
+	if flat, err := do.MustInvoke[repository.RepositoryRepository](s.injector).ListByOwner(ctx, ""); err == nil && len(flat) > 0 {
+		s.config.Logger.Warn().Int("repositories", len(flat)).
+			Msg("repositories without an owner are served at deprecated URLs, move them with githost migrate owners <owner>")
+	}
+
 	addr := fmt.Sprintf(":%d", s.config.Port)
-	s.config.Logger.Info().Str("addr", addr).Msg("starting server")
+	s.config.Logger.Info().Str("addr", addr).Int("deploy_workers", s.config.DeployWorkers).Msg("starting server")
 	return s.e.Start(addr)
 }
 
+// runPrune prunes stopped containers and old images every PruneInterval until ctx is done.
+func (s *Server) runPrune(ctx context.Context) {
+	log := zerolog.Ctx(ctx)
+	prune := do.MustInvoke[usecase.PruneImagesUsecase](s.injector)
+	ticker := time.NewTicker(s.config.PruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		report, err := prune.Execute(ctx, usecase.PruneImagesOptions{Keep: s.config.KeepImages})
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error().Err(err).Msg("failed to prune images")
+			}
+			continue
+		}
+		if len(report.Images) > 0 || len(report.Containers) > 0 {
+			log.Info().Int("images", len(report.Images)).Int("containers", len(report.Containers)).Int64("freed_bytes", report.FreedBytes).Msg("pruned images")
+		}
+	}
+}
+
+// runPurge purges repositories whose restore window has passed every purgeInterval until ctx is done.
+func (s *Server) runPurge(ctx context.Context) {
+	log := zerolog.Ctx(ctx)
+	purge := do.MustInvoke[usecase.PurgeDeletedRepositoriesUsecase](s.injector)
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		repos, err := purge.Execute(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("failed to purge deleted repositories")
+		}
+		if len(repos) > 0 {
+			log.Info().Int("repositories", len(repos)).Msg("purged deleted repositories")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func (s *Server) Stop(ctx context.Context) error {
-	return s.e.Shutdown(ctx)
+	err := s.e.Shutdown(ctx)
+	if s.proxy != nil {
+		err = errors.Join(err, s.proxy.Shutdown(ctx))
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+	// stops the apps of runtimes that only live as long as the server
+	return errors.Join(err, s.injector.Shutdown())
 }
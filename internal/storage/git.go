@@ -12,12 +12,19 @@ import (
 	"github.com/samber/lo"
 )
 
+// GitStorage stores the bare repositories at <root>/<owner>/<name>.git, or at <root>/<name>.git
+// for repositories without an owner. Names are full names, see entity.Repository.FullName.
 type GitStorage interface {
 	GetRepoDir(name string) string
 	IsRepoExist(name string) bool
 	EnsureBareRepo(ctx context.Context, name string) error
 	InitBareRepo(ctx context.Context, name string) error
+	// InstallHooks writes the hooks of the repository unless they are current already and reports
+	// whether they were rewritten. Repositories created by earlier versions, or before the
+	// executable moved, have outdated hooks.
+	InstallHooks(ctx context.Context, name string) (bool, error)
 	RemoveRepo(ctx context.Context, name string) error
+	MoveRepo(ctx context.Context, from, to string) error
 }
 
 type gitStorageImpl struct {
@@ -57,18 +64,37 @@ func (g *gitStorageImpl) InitBareRepo(ctx context.Context, reponame string) erro
 		return fmt.Errorf("init bare repo: %w", err)
 	}
 
-	hooksDir := filepath.Join(repodir, "hooks")
+	if _, err := g.InstallHooks(ctx, reponame); err != nil {
+		return err
+	}
+	return nil
+}
+
+// InstallHooks implements GitStorage.
+func (g *gitStorageImpl) InstallHooks(ctx context.Context, reponame string) (bool, error) {
+	hooksDir := filepath.Join(g.GetRepoDir(reponame), "hooks")
 	if err := os.MkdirAll(hooksDir, os.ModePerm); err != nil {
-		return fmt.Errorf("create hooks dir: %w", err)
+		return false, fmt.Errorf("create hooks dir: %w", err)
 	}
 
+	// hooks run inside the repository directory, so the executable must be referenced by absolute path
+	executable, err := os.Executable()
+	if err != nil {
+		return false, fmt.Errorf("resolve executable: %w", err)
+	}
 	scriptPath := filepath.Join(hooksDir, "post-receive")
-	scriptContent := shellScript(fmt.Sprintf("echo $(cat) | %s hook post-receive", os.Args[0]))
+	scriptContent := shellScript(fmt.Sprintf("exec %q hook post-receive", executable))
+	if current, err := os.ReadFile(scriptPath); err == nil && string(current) == scriptContent {
+		return false, nil
+	}
+	// WriteFile keeps the mode of an existing file, which may not be executable
+	if err := os.Remove(scriptPath); err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("remove post-receive hook: %w", err)
+	}
 	if err := os.WriteFile(scriptPath, []byte(scriptContent), os.ModePerm); err != nil {
-		return fmt.Errorf("write post-receive hook: %w", err)
+		return false, fmt.Errorf("write post-receive hook: %w", err)
 	}
-
-	return nil
+	return true, nil
 }
 
 // RemoveRepo implements GitStorage.
@@ -80,9 +106,38 @@ func (g *gitStorageImpl) RemoveRepo(ctx context.Context, reponame string) error
 	if err := os.RemoveAll(repodir); err != nil {
 		return fmt.Errorf("remove repo dir: %w", err)
 	}
+	g.removeEmptyOwnerDir(repodir)
 	return nil
 }
 
+// MoveRepo implements GitStorage. The hooks call the executable by absolute path, so they keep
+// working from the new directory.
+func (g *gitStorageImpl) MoveRepo(ctx context.Context, from, to string) error {
+	if g.IsRepoExist(to) {
+		return fmt.Errorf("move repo: %s already exists", to)
+	}
+	fromDir, toDir := g.GetRepoDir(from), g.GetRepoDir(to)
+	if err := os.MkdirAll(filepath.Dir(toDir), os.ModePerm); err != nil {
+		return fmt.Errorf("create owner dir: %w", err)
+	}
+	if err := os.Rename(fromDir, toDir); err != nil {
+		return fmt.Errorf("move repo dir: %w", err)
+	}
+	g.removeEmptyOwnerDir(fromDir)
+	return nil
+}
+
+// removeEmptyOwnerDir removes the directory of the owner of the repository once its last
+// repository is gone.
+func (g *gitStorageImpl) removeEmptyOwnerDir(repodir string) {
+	ownerDir := filepath.Dir(repodir)
+	if ownerDir == lo.Must(filepath.Abs(g.rootDir)) {
+		return
+	}
+	// fails as long as the directory is not empty
+	_ = os.Remove(ownerDir)
+}
+
 func shellScript(lines ...string) string {
 	return "#!/bin/sh\n" + strings.Join(lines, "\n") + "\n"
 }
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/yz4230/githost-poc/internal/entity"
+)
+
+// DeploymentLogStorage stores the build output of deployments as plain text files.
+type DeploymentLogStorage interface {
+	// Create truncates the log of the deployment and returns a writer appending to it.
+	Create(id entity.ID) (io.WriteCloser, error)
+	// Open opens the log of the deployment for reading. It returns entity.ErrNotFound
+	// if nothing has been written yet.
+	Open(id entity.ID) (io.ReadCloser, error)
+	// Remove deletes the log of the deployment, if there is one.
+	Remove(id entity.ID) error
+}
+
+type deploymentLogStorageImpl struct {
+	rootDir string
+}
+
+func (d *deploymentLogStorageImpl) path(id entity.ID) string {
+	return filepath.Join(d.rootDir, id.String()+".log")
+}
+
+// Create implements DeploymentLogStorage.
+func (d *deploymentLogStorageImpl) Create(id entity.ID) (io.WriteCloser, error) {
+	if err := os.MkdirAll(d.rootDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("create log dir: %w", err)
+	}
+	return os.Create(d.path(id))
+}
+
+// Open implements DeploymentLogStorage.
+func (d *deploymentLogStorageImpl) Open(id entity.ID) (io.ReadCloser, error) {
+	f, err := os.Open(d.path(id))
+	if os.IsNotExist(err) {
+		return nil, entity.ErrNotFound
+	}
+	return f, err
+}
+
+// Remove implements DeploymentLogStorage.
+func (d *deploymentLogStorageImpl) Remove(id entity.ID) error {
+	if err := os.Remove(d.path(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove log: %w", err)
+	}
+	return nil
+}
+
+func NewDeploymentLogStorage(root string) DeploymentLogStorage {
+	return &deploymentLogStorageImpl{rootDir: root}
+}
@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type AuthenticateUserUsecase interface {
+	// Execute verifies a password or personal access token of the user. Tokens must
+	// additionally carry a scope covering the required access.
+	Execute(ctx context.Context, username, secret string, required entity.Access) (*entity.User, error)
+}
+
+type authenticateUserUsecaseImpl struct {
+	userRepository        repository.UserRepository
+	accessTokenRepository repository.AccessTokenRepository
+}
+
+// Execute implements AuthenticateUserUsecase.
+func (a *authenticateUserUsecaseImpl) Execute(ctx context.Context, username, secret string, required entity.Access) (*entity.User, error) {
+	user, err := a.userRepository.GetByName(ctx, username)
+	if err == entity.ErrNotFound {
+		return nil, entity.ErrUnauthorized
+	} else if err != nil {
+		return nil, err
+	}
+
+	token, err := a.accessTokenRepository.GetByHash(ctx, utils.HashToken(secret))
+	if err == nil && token.UserID == user.ID {
+		if token.IsExpired(time.Now()) {
+			return nil, entity.ErrUnauthorized
+		}
+		if !token.Allows(required) {
+			return nil, entity.ErrForbidden
+		}
+		return user, nil
+	} else if err != nil && err != entity.ErrNotFound {
+		return nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(secret)) != nil {
+		return nil, entity.ErrUnauthorized
+	}
+	return user, nil
+}
+
+func NewAuthenticateUserUsecase(injector *do.Injector) (AuthenticateUserUsecase, error) {
+	return &authenticateUserUsecaseImpl{
+		userRepository:        do.MustInvoke[repository.UserRepository](injector),
+		accessTokenRepository: do.MustInvoke[repository.AccessTokenRepository](injector),
+	}, nil
+}
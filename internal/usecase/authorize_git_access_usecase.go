@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+)
+
+type AuthorizeGitAccessUsecase interface {
+	// Execute authenticates the user with a password or personal access token and checks
+	// that it has the required access to the repository, see AuthorizeRepositoryAccessUsecase.
+	// It returns entity.ErrUnauthorized for bad credentials and entity.ErrForbidden for missing
+	// permissions.
+	Execute(ctx context.Context, username, secret string, repo *entity.Repository, required entity.Access) (*entity.User, error)
+}
+
+type authorizeGitAccessUsecaseImpl struct {
+	authenticateUserUsecase          AuthenticateUserUsecase
+	authorizeRepositoryAccessUsecase AuthorizeRepositoryAccessUsecase
+}
+
+// Execute implements AuthorizeGitAccessUsecase.
+func (a *authorizeGitAccessUsecaseImpl) Execute(ctx context.Context, username, secret string, repo *entity.Repository, required entity.Access) (*entity.User, error) {
+	user, err := a.authenticateUserUsecase.Execute(ctx, username, secret, required)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.authorizeRepositoryAccessUsecase.Execute(ctx, user, repo, required); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func NewAuthorizeGitAccessUsecase(injector *do.Injector) (AuthorizeGitAccessUsecase, error) {
+	return &authorizeGitAccessUsecaseImpl{
+		authenticateUserUsecase:          do.MustInvoke[AuthenticateUserUsecase](injector),
+		authorizeRepositoryAccessUsecase: do.MustInvoke[AuthorizeRepositoryAccessUsecase](injector),
+	}, nil
+}
@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type AuthorizeOwnerUsecase interface {
+	// Execute checks that an authenticated user may act for the owner of a namespace, which
+	// gives full access to its repositories and creates repositories in it. Admins, the user of
+	// the name and the admins of the organization of the name may. It returns
+	// entity.ErrForbidden for everyone else.
+	Execute(ctx context.Context, user *entity.User, owner string) error
+}
+
+type authorizeOwnerUsecaseImpl struct {
+	organizationRepository       repository.OrganizationRepository
+	organizationMemberRepository repository.OrganizationMemberRepository
+}
+
+// Execute implements AuthorizeOwnerUsecase.
+func (a *authorizeOwnerUsecaseImpl) Execute(ctx context.Context, user *entity.User, owner string) error {
+	if user.IsAdmin || (owner != "" && owner == user.Name) {
+		return nil
+	}
+	if owner == "" {
+		return entity.ErrForbidden
+	}
+
+	org, err := a.organizationRepository.GetByName(ctx, owner)
+	if err == entity.ErrNotFound {
+		return entity.ErrForbidden
+	} else if err != nil {
+		return err
+	}
+	member, err := a.organizationMemberRepository.Get(ctx, org.ID, user.ID)
+	if err == entity.ErrNotFound {
+		return entity.ErrForbidden
+	} else if err != nil {
+		return err
+	}
+	if member.Role != entity.OrganizationRoleAdmin {
+		return entity.ErrForbidden
+	}
+	return nil
+}
+
+func NewAuthorizeOwnerUsecase(injector *do.Injector) (AuthorizeOwnerUsecase, error) {
+	return &authorizeOwnerUsecaseImpl{
+		organizationRepository:       do.MustInvoke[repository.OrganizationRepository](injector),
+		organizationMemberRepository: do.MustInvoke[repository.OrganizationMemberRepository](injector),
+	}, nil
+}
@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type AuthorizeRepositoryAccessUsecase interface {
+	// Execute checks that an authenticated user has the required access to the repository.
+	// Admins, the user owning the repository and the admins of the organization owning it have
+	// full access, see AuthorizeOwnerUsecase. It returns
+	// entity.ErrForbidden for missing permissions.
+	Execute(ctx context.Context, user *entity.User, repo *entity.Repository, required entity.Access) error
+}
+
+type authorizeRepositoryAccessUsecaseImpl struct {
+	authorizeOwnerUsecase          AuthorizeOwnerUsecase
+	repositoryPermissionRepository repository.RepositoryPermissionRepository
+}
+
+// Execute implements AuthorizeRepositoryAccessUsecase.
+func (a *authorizeRepositoryAccessUsecaseImpl) Execute(ctx context.Context, user *entity.User, repo *entity.Repository, required entity.Access) error {
+	if err := a.authorizeOwnerUsecase.Execute(ctx, user, repo.Owner); err != entity.ErrForbidden {
+		return err
+	}
+
+	perm, err := a.repositoryPermissionRepository.Get(ctx, repo.ID, user.ID)
+	if err == entity.ErrNotFound {
+		return entity.ErrForbidden
+	} else if err != nil {
+		return err
+	}
+	if !perm.Access.Allows(required) {
+		return entity.ErrForbidden
+	}
+	return nil
+}
+
+func NewAuthorizeRepositoryAccessUsecase(injector *do.Injector) (AuthorizeRepositoryAccessUsecase, error) {
+	return &authorizeRepositoryAccessUsecaseImpl{
+		authorizeOwnerUsecase:          do.MustInvoke[AuthorizeOwnerUsecase](injector),
+		repositoryPermissionRepository: do.MustInvoke[repository.RepositoryPermissionRepository](injector),
+	}, nil
+}
@@ -18,6 +18,11 @@ type checkRepositoryNameUsecaseImpl struct {
 
 func (c *checkRepositoryNameUsecaseImpl) Execute(ctx context.Context, name string) (bool, error) {
 	_, err := c.repositoryRepository.GetByName(ctx, name)
+	if err != entity.ErrNotFound {
+		return false, err
+	}
+	// the name of a deleted repository is taken until it is purged
+	_, err = c.repositoryRepository.GetDeletedByName(ctx, name)
 	if err == entity.ErrNotFound {
 		return true, nil
 	}
@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/utils"
+)
+
+const accessTokenPrefix = "githost_"
+
+type CreateAccessTokenUsecase interface {
+	// Execute creates a token for the user and returns it along with the plaintext secret,
+	// which is not stored and cannot be recovered later.
+	Execute(ctx context.Context, username, name string, scopes []entity.TokenScope, ttl time.Duration) (*entity.AccessToken, string, error)
+}
+
+type createAccessTokenUsecaseImpl struct {
+	userRepository        repository.UserRepository
+	accessTokenRepository repository.AccessTokenRepository
+}
+
+// Execute implements CreateAccessTokenUsecase.
+func (c *createAccessTokenUsecaseImpl) Execute(ctx context.Context, username, name string, scopes []entity.TokenScope, ttl time.Duration) (*entity.AccessToken, string, error) {
+	if len(scopes) == 0 || ttl <= 0 {
+		return nil, "", entity.ErrInvalid
+	}
+	for _, s := range scopes {
+		if !s.IsValid() {
+			return nil, "", entity.ErrInvalid
+		}
+	}
+	user, err := c.userRepository.GetByName(ctx, username)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := utils.GenerateToken(accessTokenPrefix)
+	if err != nil {
+		return nil, "", entity.ErrInternal
+	}
+	token, err := c.accessTokenRepository.Create(ctx, &entity.AccessToken{
+		UserID:    user.ID,
+		Name:      name,
+		TokenHash: utils.HashToken(secret),
+		Scopes:    scopes,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return nil, "", entity.ErrInternal
+	}
+	return token, secret, nil
+}
+
+func NewCreateAccessTokenUsecase(injector *do.Injector) (CreateAccessTokenUsecase, error) {
+	return &createAccessTokenUsecaseImpl{
+		userRepository:        do.MustInvoke[repository.UserRepository](injector),
+		accessTokenRepository: do.MustInvoke[repository.AccessTokenRepository](injector),
+	}, nil
+}
@@ -0,0 +1,51 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type CreateDeploymentUsecase interface {
+	// Execute queues a deployment of the pushed commit and makes it the latest SHA of the repository.
+	// The deployment is picked up by the deployment queue of the server. A clean deployment
+	// builds without the build cache.
+	Execute(ctx context.Context, reponame, branch, commitSHA string, clean bool) (*entity.Deployment, error)
+}
+
+type createDeploymentUsecaseImpl struct {
+	repositoryRepository repository.RepositoryRepository
+	deploymentRepository repository.DeploymentRepository
+}
+
+// Execute implements CreateDeploymentUsecase.
+func (c *createDeploymentUsecaseImpl) Execute(ctx context.Context, reponame, branch, commitSHA string, clean bool) (*entity.Deployment, error) {
+	repo, err := c.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	dep, err := c.deploymentRepository.Create(ctx, &entity.Deployment{
+		RepoID:    repo.ID,
+		Branch:    branch,
+		CommitSHA: commitSHA,
+		Status:    entity.DeploymentStatusPending,
+		Clean:     clean,
+	})
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	repo.LatestSHA = commitSHA
+	if _, err := c.repositoryRepository.Update(ctx, repo); err != nil {
+		return nil, entity.ErrInternal
+	}
+	return dep, nil
+}
+
+func NewCreateDeploymentUsecase(injector *do.Injector) (CreateDeploymentUsecase, error) {
+	return &createDeploymentUsecaseImpl{
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+		deploymentRepository: do.MustInvoke[repository.DeploymentRepository](injector),
+	}, nil
+}
@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+func TestCreateDeployment(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	create := do.MustInvoke[CreateDeploymentUsecase](d.injector)
+
+	runGit(t, d.work, "commit", "--allow-empty", "-m", "change")
+	sha := runGit(t, d.work, "rev-parse", "HEAD")
+	dep, err := create.Execute(ctx, d.name, "main", sha, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dep.Status != entity.DeploymentStatusPending || dep.RepoID != d.repo.ID || dep.Branch != "main" ||
+		dep.CommitSHA != sha || !dep.Clean || dep.IsActive || dep.Preview {
+		t.Fatalf("deployment = %+v, want a pending clean deployment of %s", dep, sha)
+	}
+	repo, err := do.MustInvoke[repository.RepositoryRepository](d.injector).GetByID(ctx, d.repo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.LatestSHA != sha {
+		t.Fatalf("latest SHA = %s, want %s", repo.LatestSHA, sha)
+	}
+	deps, err := do.MustInvoke[ListDeploymentsUsecase](d.injector).Execute(ctx, d.name)
+	if err != nil || len(deps) != 1 || deps[0].ID != dep.ID {
+		t.Fatalf("deployments = %v, %v, want [%s]", deps, err, dep.ID)
+	}
+	if _, err := create.Execute(ctx, "alice/missing", "main", sha, false); err != entity.ErrNotFound {
+		t.Fatalf("deployment of a missing repository error = %v, want %v", err, entity.ErrNotFound)
+	}
+}
+
+func TestTriggerDeployment(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	trigger := do.MustInvoke[TriggerDeploymentUsecase](d.injector)
+
+	first, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without a commit, the tip of the deploy branch is deployed again
+	dep, err := trigger.Execute(ctx, d.name, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dep.Status != entity.DeploymentStatusPending || dep.CommitSHA != second.CommitSHA || dep.Branch != "main" || dep.Clean {
+		t.Fatalf("deployment = %+v, want a pending deployment of %s", dep, second.CommitSHA)
+	}
+	dep, err = trigger.Execute(ctx, d.name, first.CommitSHA[:7], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dep.CommitSHA != first.CommitSHA || !dep.Clean {
+		t.Fatalf("deployment of %s = %+v, want a clean deployment of %s", first.CommitSHA[:7], dep, first.CommitSHA)
+	}
+	if _, err := trigger.Execute(ctx, d.name, "unknown", false); err != entity.ErrInvalid {
+		t.Fatalf("deployment of an unknown commit error = %v, want %v", err, entity.ErrInvalid)
+	}
+	if _, err := trigger.Execute(ctx, "alice/missing", "", false); err != entity.ErrNotFound {
+		t.Fatalf("deployment of a missing repository error = %v, want %v", err, entity.ErrNotFound)
+	}
+}
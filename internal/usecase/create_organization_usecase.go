@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+// ownerNamePattern matches the names of users and organizations. They name a directory of the
+// repositories on disk, so they must not start with a dot.
+var ownerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+type CreateOrganizationUsecase interface {
+	// Execute creates the organization with the user named admin as its first admin, or without
+	// admins if admin is empty. Users and organizations share one namespace, so it returns
+	// entity.ErrConflict if a user or an organization of the name exists, and entity.ErrInvalid
+	// if the admin does not exist.
+	Execute(ctx context.Context, org *entity.Organization, admin string) (*entity.Organization, error)
+}
+
+type createOrganizationUsecaseImpl struct {
+	userRepository               repository.UserRepository
+	organizationRepository       repository.OrganizationRepository
+	organizationMemberRepository repository.OrganizationMemberRepository
+}
+
+// Execute implements CreateOrganizationUsecase.
+func (c *createOrganizationUsecaseImpl) Execute(ctx context.Context, org *entity.Organization, admin string) (*entity.Organization, error) {
+	if !ownerNamePattern.MatchString(org.Name) {
+		return nil, entity.ErrInvalid
+	}
+	var adminUser *entity.User
+	if admin != "" {
+		user, err := c.userRepository.GetByName(ctx, admin)
+		if err == entity.ErrNotFound {
+			return nil, entity.ErrInvalid
+		} else if err != nil {
+			return nil, entity.ErrInternal
+		}
+		adminUser = user
+	}
+	if taken, err := ownerExists(ctx, c.userRepository, c.organizationRepository, org.Name); err != nil {
+		return nil, entity.ErrInternal
+	} else if taken {
+		return nil, entity.ErrConflict
+	}
+	org, err := c.organizationRepository.Create(ctx, org)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	if adminUser != nil {
+		_, err := c.organizationMemberRepository.Set(ctx, &entity.OrganizationMember{
+			OrgID:  org.ID,
+			UserID: adminUser.ID,
+			Role:   entity.OrganizationRoleAdmin,
+		})
+		if err != nil {
+			return nil, entity.ErrInternal
+		}
+	}
+	return org, nil
+}
+
+func NewCreateOrganizationUsecase(injector *do.Injector) (CreateOrganizationUsecase, error) {
+	return &createOrganizationUsecaseImpl{
+		userRepository:               do.MustInvoke[repository.UserRepository](injector),
+		organizationRepository:       do.MustInvoke[repository.OrganizationRepository](injector),
+		organizationMemberRepository: do.MustInvoke[repository.OrganizationMemberRepository](injector),
+	}, nil
+}
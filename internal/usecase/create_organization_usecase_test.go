@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+func TestCreateOrganization(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	create := do.MustInvoke[CreateOrganizationUsecase](d.injector)
+	authorize := do.MustInvoke[AuthorizeRepositoryAccessUsecase](d.injector)
+	users := do.MustInvoke[repository.UserRepository](d.injector)
+	repos := do.MustInvoke[repository.RepositoryRepository](d.injector)
+
+	alice, err := users.GetByName(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := users.Create(ctx, &entity.User{Name: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := create.Execute(ctx, &entity.Organization{Name: "acme"}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	site, err := repos.Create(ctx, &entity.Repository{Owner: "acme", Name: "site", DeployBranch: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := authorize.Execute(ctx, alice, site, entity.AccessWrite); err != nil {
+		t.Fatalf("write access of the organization admin = %v, want nil", err)
+	}
+	if err := authorize.Execute(ctx, bob, site, entity.AccessRead); err != entity.ErrForbidden {
+		t.Fatalf("read access of another user = %v, want %v", err, entity.ErrForbidden)
+	}
+	if err := do.MustInvoke[AuthorizeOwnerUsecase](d.injector).Execute(ctx, bob, "acme"); err != entity.ErrForbidden {
+		t.Fatalf("bob acting for acme = %v, want %v", err, entity.ErrForbidden)
+	}
+
+	// organizations created without an admin are left to the server admins
+	if _, err := create.Execute(ctx, &entity.Organization{Name: "lonely"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := do.MustInvoke[AuthorizeOwnerUsecase](d.injector).Execute(ctx, alice, "lonely"); err != entity.ErrForbidden {
+		t.Fatalf("alice acting for an organization without admins = %v, want %v", err, entity.ErrForbidden)
+	}
+
+	if _, err := create.Execute(ctx, &entity.Organization{Name: "ghost"}, "nobody"); err != entity.ErrInvalid {
+		t.Fatalf("organization with a missing admin error = %v, want %v", err, entity.ErrInvalid)
+	}
+	if _, err := do.MustInvoke[repository.OrganizationRepository](d.injector).GetByName(ctx, "ghost"); err != entity.ErrNotFound {
+		t.Fatalf("organization with a missing admin was created: %v", err)
+	}
+	for _, name := range []string{"alice", "acme"} {
+		if _, err := create.Execute(ctx, &entity.Organization{Name: name}, "bob"); err != entity.ErrConflict {
+			t.Fatalf("organization %q error = %v, want %v", name, err, entity.ErrConflict)
+		}
+	}
+}
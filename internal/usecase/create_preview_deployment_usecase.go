@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/config"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type CreatePreviewDeploymentUsecase interface {
+	// Execute queues a deployment of the pushed commit as the preview environment of the branch.
+	// It returns entity.ErrDisabled if the repository may have no previews at all, and
+	// entity.ErrLimitExceeded if the branch has no preview yet and the repository already has as
+	// many previews as it may have.
+	Execute(ctx context.Context, reponame, branch, commitSHA string, clean bool) (*entity.Deployment, error)
+}
+
+type createPreviewDeploymentUsecaseImpl struct {
+	maxPreviews          int
+	repositoryRepository repository.RepositoryRepository
+	deploymentRepository repository.DeploymentRepository
+}
+
+// Execute implements CreatePreviewDeploymentUsecase.
+func (c *createPreviewDeploymentUsecaseImpl) Execute(ctx context.Context, reponame, branch, commitSHA string, clean bool) (*entity.Deployment, error) {
+	repo, err := c.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	limit := repo.PreviewLimit(c.maxPreviews)
+	if limit == 0 {
+		return nil, entity.ErrDisabled
+	}
+	deps, err := c.deploymentRepository.ListByRepo(ctx, repo.ID)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	previews := previewBranches(deps)
+	if !previews[branch] && len(previews) >= limit {
+		return nil, entity.ErrLimitExceeded
+	}
+
+	dep, err := c.deploymentRepository.Create(ctx, &entity.Deployment{
+		RepoID:    repo.ID,
+		Branch:    branch,
+		CommitSHA: commitSHA,
+		Status:    entity.DeploymentStatusPending,
+		Clean:     clean,
+		Preview:   true,
+	})
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	return dep, nil
+}
+
+// previewBranches returns the branches that have a preview, running or queued, given the
+// deployments of a repository newest first. Branches being torn down do not count.
+func previewBranches(deps []*entity.Deployment) map[string]bool {
+	active := map[string]bool{}
+	for _, dep := range deps {
+		if dep.Preview && dep.IsActive {
+			active[dep.Branch] = true
+		}
+	}
+	branches := map[string]bool{}
+	seen := map[string]bool{}
+	for _, dep := range deps {
+		if !dep.Preview || seen[dep.Branch] {
+			continue
+		}
+		// the newest deployment of the branch tells whether it is being torn down
+		seen[dep.Branch] = true
+		if dep.IsTeardown() {
+			continue
+		}
+		if active[dep.Branch] || dep.Status == entity.DeploymentStatusPending || dep.Status == entity.DeploymentStatusRunning {
+			branches[dep.Branch] = true
+		}
+	}
+	return branches
+}
+
+func newestPreview(deps []*entity.Deployment, branch string) *entity.Deployment {
+	for _, dep := range deps {
+		if dep.Preview && dep.Branch == branch {
+			return dep
+		}
+	}
+	return nil
+}
+
+func NewCreatePreviewDeploymentUsecase(injector *do.Injector) (CreatePreviewDeploymentUsecase, error) {
+	return &createPreviewDeploymentUsecaseImpl{
+		maxPreviews:          do.MustInvoke[*config.Config](injector).MaxPreviews,
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+		deploymentRepository: do.MustInvoke[repository.DeploymentRepository](injector),
+	}, nil
+}
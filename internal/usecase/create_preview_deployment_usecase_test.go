@@ -0,0 +1,128 @@
+package usecase
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+func TestPreviewDeployments(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+
+	app, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	preview, err := d.pushBranch("feature/login", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !preview.Preview || preview.Status != entity.DeploymentStatusSuccess {
+		t.Fatalf("preview deployment = %+v, want a successful preview", preview)
+	}
+	got := d.instances()
+	slices.Sort(got)
+	if !slices.Equal(got, []entity.ID{app.ID, preview.ID}) {
+		t.Fatalf("instances = %v, want the app %s and the preview %s", got, app.ID, preview.ID)
+	}
+	if got := d.active(); got != app.ID {
+		t.Fatalf("active deployment = %s, want the app %s", got, app.ID)
+	}
+	instances, _ := d.runtime.List(ctx, map[string]string{deployer.LabelPreview: "app-feature-login"})
+	if len(instances) != 1 || instances[0].Labels[deployer.LabelBranch] != "feature/login" {
+		t.Fatalf("preview instances = %+v, want one labeled with the branch", instances)
+	}
+
+	// pushing to main again replaces the app only
+	next, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = d.instances()
+	slices.Sort(got)
+	if !slices.Equal(got, []entity.ID{preview.ID, next.ID}) {
+		t.Fatalf("instances after pushing main = %v, want %s and %s", got, preview.ID, next.ID)
+	}
+
+	if _, err := d.pushBranch("other", nil); err != entity.ErrLimitExceeded {
+		t.Fatalf("second preview error = %v, want %v", err, entity.ErrLimitExceeded)
+	}
+	// the existing preview is still updated
+	updated, err := d.pushBranch("feature/login", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	teardown, err := do.MustInvoke[TeardownPreviewUsecase](d.injector).Execute(ctx, d.name, "feature/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the cap counts branches being torn down as gone
+	if _, err := do.MustInvoke[CreatePreviewDeploymentUsecase](d.injector).Execute(ctx, d.name, "other", app.CommitSHA, false); err != nil {
+		t.Fatalf("preview after teardown was queued: %v", err)
+	}
+	teardown, err = do.MustInvoke[RunDeploymentUsecase](d.injector).Execute(ctx, teardown.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if teardown.Status != entity.DeploymentStatusSuccess {
+		t.Fatalf("teardown status = %s, want success", teardown.Status)
+	}
+	if got := d.instances(); len(got) != 1 || got[0] != next.ID {
+		t.Fatalf("instances after teardown = %v, want only the app %s", got, next.ID)
+	}
+	previews, err := do.MustInvoke[repository.DeploymentRepository](d.injector).ListActivePreviews(ctx, d.repo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.ContainsFunc(previews, func(dep *entity.Deployment) bool { return dep.ID == updated.ID }) {
+		t.Fatalf("preview %s is still active after teardown", updated.ID)
+	}
+	if _, err := do.MustInvoke[TeardownPreviewUsecase](d.injector).Execute(ctx, d.name, "feature/login"); err != entity.ErrNotFound {
+		t.Fatalf("second teardown error = %v, want %v", err, entity.ErrNotFound)
+	}
+}
+
+func TestPreviewsDisabled(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	update := do.MustInvoke[UpdateRepositoryMaxPreviewsUsecase](d.injector)
+
+	zero := 0
+	if _, err := update.Execute(ctx, d.name, &zero); err != nil {
+		t.Fatal(err)
+	}
+	// disabled previews are told apart from a repository that is at its limit
+	if _, err := d.pushBranch("feature", nil); err != entity.ErrDisabled {
+		t.Fatalf("preview error with previews disabled = %v, want %v", err, entity.ErrDisabled)
+	}
+	if got := d.instances(); len(got) != 0 {
+		t.Fatalf("instances with previews disabled = %v, want none", got)
+	}
+
+	// nil restores the server default of one preview
+	if _, err := update.Execute(ctx, d.name, nil); err != nil {
+		t.Fatal(err)
+	}
+	preview, err := d.pushBranch("feature", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !preview.Preview || preview.Status != entity.DeploymentStatusSuccess {
+		t.Fatalf("preview deployment = %+v, want a successful preview", preview)
+	}
+	if _, err := d.pushBranch("other", nil); err != entity.ErrLimitExceeded {
+		t.Fatalf("second preview error = %v, want %v", err, entity.ErrLimitExceeded)
+	}
+
+	negative := -1
+	if _, err := update.Execute(ctx, d.name, &negative); err != entity.ErrInvalid {
+		t.Fatalf("negative limit error = %v, want %v", err, entity.ErrInvalid)
+	}
+}
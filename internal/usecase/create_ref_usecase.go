@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/git"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+type CreateRefUsecase interface {
+	// Execute creates a branch or tag pointing to the commit, a SHA, branch or tag. An empty
+	// commitSHA points it to the tip of the deploy branch. Branches created this way are not
+	// deployed, only pushes are.
+	// It returns entity.ErrInvalid for an invalid name or unknown commit and entity.ErrConflict if
+	// the ref exists.
+	Execute(ctx context.Context, reponame string, refType entity.RefType, name, commitSHA string) (*entity.Ref, error)
+}
+
+type createRefUsecaseImpl struct {
+	gitStorage           storage.GitStorage
+	repositoryRepository repository.RepositoryRepository
+}
+
+// Execute implements CreateRefUsecase.
+func (c *createRefUsecaseImpl) Execute(ctx context.Context, reponame string, refType entity.RefType, name, commitSHA string) (*entity.Ref, error) {
+	if !isValidRefName(refType, name) {
+		return nil, entity.ErrInvalid
+	}
+	repo, err := c.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	repoDir := c.gitStorage.GetRepoDir(repo.FullName())
+	if commitSHA == "" {
+		commitSHA = repo.DeployRef()
+	}
+	resolved, err := git.ResolveCommit(ctx, repoDir, commitSHA)
+	if err != nil {
+		return nil, entity.ErrInvalid
+	}
+
+	if err := git.CreateRef(ctx, repoDir, refType.Prefix()+name, resolved); err != nil {
+		if errors.Is(err, git.ErrRefConflict) {
+			return nil, entity.ErrConflict
+		}
+		return nil, err
+	}
+	return git.NewReader(repoDir).Ref(ctx, refType, name)
+}
+
+func isValidRefName(refType entity.RefType, name string) bool {
+	if refType == entity.RefTypeTag {
+		return git.IsValidTagName(name)
+	}
+	return git.IsValidBranchName(name)
+}
+
+func NewCreateRefUsecase(injector *do.Injector) (CreateRefUsecase, error) {
+	return &createRefUsecaseImpl{
+		gitStorage:           do.MustInvoke[storage.GitStorage](injector),
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+	}, nil
+}
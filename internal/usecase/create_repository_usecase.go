@@ -11,34 +11,67 @@ import (
 )
 
 type CreateRepositoryUsecase interface {
+	// Execute creates the repository in the namespace of its owner, a user or an organization.
+	// It returns entity.ErrInvalid if the owner does not exist.
 	Execute(ctx context.Context, repo *entity.Repository) (*entity.Repository, error)
 }
 
 type createRepositoryUsecaseImpl struct {
-	gitStorage           storage.GitStorage
-	repositoryRepository repository.RepositoryRepository
+	gitStorage             storage.GitStorage
+	repositoryRepository   repository.RepositoryRepository
+	redirectRepository     repository.RepositoryRedirectRepository
+	userRepository         repository.UserRepository
+	organizationRepository repository.OrganizationRepository
 }
 
 // Execute implements CreateRepositoryUsecase.
 func (c *createRepositoryUsecaseImpl) Execute(ctx context.Context, repo *entity.Repository) (*entity.Repository, error) {
 	repo.Name = utils.SanitizeName(repo.Name)
 	repo.FillDefaults()
-	if exists := c.gitStorage.IsRepoExist(repo.Name); exists {
+	exists, err := ownerExists(ctx, c.userRepository, c.organizationRepository, repo.Owner)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	if !exists {
+		return nil, entity.ErrInvalid
+	}
+	if exists := c.gitStorage.IsRepoExist(repo.FullName()); exists {
 		return nil, entity.ErrConflict
 	}
-	if err := c.gitStorage.InitBareRepo(ctx, repo.Name); err != nil {
+	if err := c.gitStorage.InitBareRepo(ctx, repo.FullName()); err != nil {
 		return nil, entity.ErrInternal
 	}
-	repo, err := c.repositoryRepository.Create(ctx, repo)
+	repo, err = c.repositoryRepository.Create(ctx, repo)
 	if err != nil {
 		return nil, entity.ErrInternal
 	}
+	// the name no longer leads to the repository that was renamed from it
+	if err := c.redirectRepository.Delete(ctx, repo.FullName()); err != nil {
+		return nil, entity.ErrInternal
+	}
 	return repo, nil
 }
 
+// ownerExists reports whether a user or an organization of the name exists to own repositories.
+func ownerExists(ctx context.Context, userRepository repository.UserRepository, organizationRepository repository.OrganizationRepository, name string) (bool, error) {
+	if name == "" {
+		return false, nil
+	}
+	if _, err := userRepository.GetByName(ctx, name); err != entity.ErrNotFound {
+		return err == nil, err
+	}
+	if _, err := organizationRepository.GetByName(ctx, name); err != entity.ErrNotFound {
+		return err == nil, err
+	}
+	return false, nil
+}
+
 func NewCreateRepositoryUsecase(injector *do.Injector) (CreateRepositoryUsecase, error) {
 	return &createRepositoryUsecaseImpl{
-		gitStorage:           do.MustInvoke[storage.GitStorage](injector),
-		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+		gitStorage:             do.MustInvoke[storage.GitStorage](injector),
+		repositoryRepository:   do.MustInvoke[repository.RepositoryRepository](injector),
+		redirectRepository:     do.MustInvoke[repository.RepositoryRedirectRepository](injector),
+		userRepository:         do.MustInvoke[repository.UserRepository](injector),
+		organizationRepository: do.MustInvoke[repository.OrganizationRepository](injector),
 	}, nil
 }
@@ -0,0 +1,53 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type CreateUserUsecase interface {
+	// Execute creates the user. Users and organizations share one namespace, so it returns
+	// entity.ErrConflict if a user or an organization of the name exists.
+	Execute(ctx context.Context, name, password string, isAdmin bool) (*entity.User, error)
+}
+
+type createUserUsecaseImpl struct {
+	userRepository         repository.UserRepository
+	organizationRepository repository.OrganizationRepository
+}
+
+// Execute implements CreateUserUsecase.
+func (c *createUserUsecaseImpl) Execute(ctx context.Context, name, password string, isAdmin bool) (*entity.User, error) {
+	if !ownerNamePattern.MatchString(name) || password == "" {
+		return nil, entity.ErrInvalid
+	}
+	if taken, err := ownerExists(ctx, c.userRepository, c.organizationRepository, name); err != nil {
+		return nil, entity.ErrInternal
+	} else if taken {
+		return nil, entity.ErrConflict
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, entity.ErrInvalid
+	}
+	user, err := c.userRepository.Create(ctx, &entity.User{
+		Name:         name,
+		PasswordHash: string(hash),
+		IsAdmin:      isAdmin,
+	})
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	return user, nil
+}
+
+func NewCreateUserUsecase(injector *do.Injector) (CreateUserUsecase, error) {
+	return &createUserUsecaseImpl{
+		userRepository:         do.MustInvoke[repository.UserRepository](injector),
+		organizationRepository: do.MustInvoke[repository.OrganizationRepository](injector),
+	}, nil
+}
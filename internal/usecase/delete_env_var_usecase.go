@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type DeleteEnvVarUsecase interface {
+	// Execute removes an environment variable of the repository. It takes effect with the next deployment.
+	Execute(ctx context.Context, reponame, name string) error
+}
+
+type deleteEnvVarUsecaseImpl struct {
+	repositoryRepository repository.RepositoryRepository
+	envVarRepository     repository.EnvVarRepository
+}
+
+// Execute implements DeleteEnvVarUsecase.
+func (d *deleteEnvVarUsecaseImpl) Execute(ctx context.Context, reponame, name string) error {
+	repo, err := d.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return err
+	}
+	if err := d.envVarRepository.Delete(ctx, repo.ID, name); err != nil {
+		return envVarError(err)
+	}
+	return nil
+}
+
+func NewDeleteEnvVarUsecase(injector *do.Injector) (DeleteEnvVarUsecase, error) {
+	return &deleteEnvVarUsecaseImpl{
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+		envVarRepository:     do.MustInvoke[repository.EnvVarRepository](injector),
+	}, nil
+}
@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/git"
+	"github.com/yz4230/githost-poc/internal/queue"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+type DeleteRefUsecase interface {
+	// Execute deletes a branch or tag. The preview of a deleted branch is torn down like when the
+	// branch is deleted by a push.
+	// It returns entity.ErrConflict for the deploy branch, which cannot be deleted this way.
+	Execute(ctx context.Context, reponame string, refType entity.RefType, name string) error
+}
+
+type deleteRefUsecaseImpl struct {
+	gitStorage             storage.GitStorage
+	repositoryRepository   repository.RepositoryRepository
+	teardownPreviewUsecase TeardownPreviewUsecase
+	deploymentQueue        queue.DeploymentQueue
+}
+
+// Execute implements DeleteRefUsecase.
+func (d *deleteRefUsecaseImpl) Execute(ctx context.Context, reponame string, refType entity.RefType, name string) error {
+	repo, err := d.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return err
+	}
+	if refType == entity.RefTypeBranch && name == repo.DeployBranch {
+		return entity.ErrConflict
+	}
+	repoDir := d.gitStorage.GetRepoDir(repo.FullName())
+	_, err = git.NewReader(repoDir).Ref(ctx, refType, name)
+	if errors.Is(err, os.ErrNotExist) {
+		return entity.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := git.DeleteRef(ctx, repoDir, refType.Prefix()+name); err != nil {
+		if errors.Is(err, git.ErrRefConflict) {
+			return entity.ErrConflict
+		}
+		return err
+	}
+	if refType != entity.RefTypeBranch {
+		return nil
+	}
+	if _, err := d.teardownPreviewUsecase.Execute(ctx, repo.FullName(), name); err != nil && err != entity.ErrNotFound {
+		zerolog.Ctx(ctx).Error().Err(err).Str("branch", name).Msg("failed to queue preview removal")
+		return err
+	}
+	d.deploymentQueue.Notify()
+	return nil
+}
+
+func NewDeleteRefUsecase(injector *do.Injector) (DeleteRefUsecase, error) {
+	return &deleteRefUsecaseImpl{
+		gitStorage:             do.MustInvoke[storage.GitStorage](injector),
+		repositoryRepository:   do.MustInvoke[repository.RepositoryRepository](injector),
+		teardownPreviewUsecase: do.MustInvoke[TeardownPreviewUsecase](injector),
+		deploymentQueue:        do.MustInvoke[queue.DeploymentQueue](injector),
+	}, nil
+}
@@ -0,0 +1,201 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/config"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/proxy"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+type DeleteRepositoryUsecase interface {
+	// Execute deletes the repository. Its app and previews are stopped and queued deployments
+	// are failed right away, while the git repository, deployments, images and settings are kept
+	// for the restore window. With purge, or without a restore window, they are removed right away.
+	// It returns entity.ErrConflict while a deployment of the repository is running.
+	Execute(ctx context.Context, reponame string, purge bool) error
+}
+
+type deleteRepositoryUsecaseImpl struct {
+	restoreWindow                 time.Duration
+	repositoryRepository          repository.RepositoryRepository
+	deploymentRepository          repository.DeploymentRepository
+	updateDeploymentStatusUsecase UpdateDeploymentStatusUsecase
+	router                        proxy.Router
+	purger                        *repositoryPurger
+}
+
+// Execute implements DeleteRepositoryUsecase.
+func (d *deleteRepositoryUsecaseImpl) Execute(ctx context.Context, reponame string, purge bool) error {
+	log := zerolog.Ctx(ctx)
+	repo, err := d.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return err
+	}
+	deps, err := d.deploymentRepository.ListByRepo(ctx, repo.ID)
+	if err != nil {
+		return entity.ErrInternal
+	}
+	for _, dep := range deps {
+		// a running deployment would start its container after the others are stopped
+		if dep.Status == entity.DeploymentStatusRunning {
+			return entity.ErrConflict
+		}
+	}
+
+	// from here on pushes are rejected and the queue no longer starts deployments of the repository
+	if err := d.repositoryRepository.Delete(ctx, repo.ID); err != nil {
+		return entity.ErrInternal
+	}
+	log.Info().Str("repo", repo.FullName()).Msg("deleted repository")
+	for _, dep := range deps {
+		if dep.Status != entity.DeploymentStatusPending {
+			continue
+		}
+		if _, err := d.updateDeploymentStatusUsecase.Execute(ctx, dep.ID, entity.DeploymentStatusFailed); err != nil {
+			log.Error().Err(err).Str("deployment", dep.ID.String()).Msg("failed to cancel queued deployment")
+		}
+	}
+	d.router.RemoveRepository(repo)
+	// containers that fail to stop now are removed again when the repository is purged
+	if err := d.purger.stopInstances(ctx, repo); err != nil {
+		log.Error().Err(err).Str("repo", repo.FullName()).Msg("failed to stop containers of deleted repository")
+	}
+
+	if purge || d.restoreWindow == 0 {
+		if err := d.purger.purge(ctx, repo); err != nil {
+			log.Error().Err(err).Str("repo", repo.FullName()).Msg("failed to purge repository")
+			return entity.ErrInternal
+		}
+	}
+	return nil
+}
+
+// repositoryPurger permanently removes deleted repositories along with everything deployed from them.
+type repositoryPurger struct {
+	runtime                        deployer.Runtime
+	gitStorage                     storage.GitStorage
+	deploymentLogStorage           storage.DeploymentLogStorage
+	repositoryRepository           repository.RepositoryRepository
+	deploymentRepository           repository.DeploymentRepository
+	envVarRepository               repository.EnvVarRepository
+	repositoryPermissionRepository repository.RepositoryPermissionRepository
+	redirectRepository             repository.RepositoryRedirectRepository
+}
+
+// purge removes what runs before what it was built from, and the row of the repository last,
+// so that a purge that fails halfway is retried with the next one.
+func (p *repositoryPurger) purge(ctx context.Context, repo *entity.Repository) error {
+	log := zerolog.Ctx(ctx)
+	if err := p.stopInstances(ctx, repo); err != nil {
+		return err
+	}
+
+	names, err := p.names(ctx, repo)
+	if err != nil {
+		return err
+	}
+	images, err := p.runtime.Images(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list images: %w", err)
+	}
+	for _, image := range images {
+		if !slices.Contains(names, image.RepoName) {
+			continue
+		}
+		if err := p.runtime.RemoveImage(ctx, image.RepoName, image.CommitSHA); err != nil {
+			return fmt.Errorf("failed to remove image of %s: %w", image.CommitSHA, err)
+		}
+	}
+
+	deps, err := p.deploymentRepository.ListByRepo(ctx, repo.ID)
+	if err != nil {
+		return err
+	}
+	for _, dep := range deps {
+		if err := p.deploymentLogStorage.Remove(dep.ID); err != nil {
+			return err
+		}
+	}
+	if err := p.deploymentRepository.DeleteByRepo(ctx, repo.ID); err != nil {
+		return fmt.Errorf("failed to delete deployments: %w", err)
+	}
+	if err := p.envVarRepository.DeleteByRepo(ctx, repo.ID); err != nil {
+		return fmt.Errorf("failed to delete environment variables: %w", err)
+	}
+	if err := p.repositoryPermissionRepository.DeleteByRepo(ctx, repo.ID); err != nil {
+		return fmt.Errorf("failed to delete permissions: %w", err)
+	}
+	if err := p.redirectRepository.DeleteByRepo(ctx, repo.ID); err != nil {
+		return fmt.Errorf("failed to delete redirects: %w", err)
+	}
+	if err := p.gitStorage.RemoveRepo(ctx, repo.FullName()); err != nil {
+		return err
+	}
+	if err := p.repositoryRepository.Purge(ctx, repo.ID); err != nil {
+		return fmt.Errorf("failed to delete repository: %w", err)
+	}
+	log.Info().Str("repo", repo.FullName()).Int("images", len(images)).Int("deployments", len(deps)).Msg("purged repository")
+	return nil
+}
+
+// stopInstances stops and removes the containers of the app and the previews of the repository.
+func (p *repositoryPurger) stopInstances(ctx context.Context, repo *entity.Repository) error {
+	names, err := p.names(ctx, repo)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		instances, err := p.runtime.List(ctx, map[string]string{deployer.LabelEnabled: "true", deployer.LabelRepo: name})
+		if err != nil {
+			return fmt.Errorf("failed to list containers: %w", err)
+		}
+		for _, inst := range instances {
+			if err := p.runtime.Stop(ctx, inst.ID); err != nil {
+				return fmt.Errorf("failed to remove container %s: %w", inst.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// names returns the name of the repository followed by its former names.
+func (p *repositoryPurger) names(ctx context.Context, repo *entity.Repository) ([]string, error) {
+	formerNames, err := formerNames(ctx, p.redirectRepository, repo.ID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{repo.FullName()}, formerNames...), nil
+}
+
+func newRepositoryPurger(injector *do.Injector) *repositoryPurger {
+	return &repositoryPurger{
+		runtime:                        do.MustInvoke[deployer.Runtime](injector),
+		gitStorage:                     do.MustInvoke[storage.GitStorage](injector),
+		deploymentLogStorage:           do.MustInvoke[storage.DeploymentLogStorage](injector),
+		repositoryRepository:           do.MustInvoke[repository.RepositoryRepository](injector),
+		deploymentRepository:           do.MustInvoke[repository.DeploymentRepository](injector),
+		envVarRepository:               do.MustInvoke[repository.EnvVarRepository](injector),
+		repositoryPermissionRepository: do.MustInvoke[repository.RepositoryPermissionRepository](injector),
+		redirectRepository:             do.MustInvoke[repository.RepositoryRedirectRepository](injector),
+	}
+}
+
+func NewDeleteRepositoryUsecase(injector *do.Injector) (DeleteRepositoryUsecase, error) {
+	return &deleteRepositoryUsecaseImpl{
+		restoreWindow:                 do.MustInvoke[*config.Config](injector).RestoreWindow,
+		repositoryRepository:          do.MustInvoke[repository.RepositoryRepository](injector),
+		deploymentRepository:          do.MustInvoke[repository.DeploymentRepository](injector),
+		updateDeploymentStatusUsecase: do.MustInvoke[UpdateDeploymentStatusUsecase](injector),
+		router:                        do.MustInvoke[proxy.Router](injector),
+		purger:                        newRepositoryPurger(injector),
+	}, nil
+}
@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+func TestDeleteRepository(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	repos := do.MustInvoke[repository.RepositoryRepository](d.injector)
+	deployments := do.MustInvoke[repository.DeploymentRepository](d.injector)
+	bare := do.MustInvoke[storage.GitStorage](d.injector).GetRepoDir(d.name)
+	remove := do.MustInvoke[DeleteRepositoryUsecase](d.injector)
+
+	if _, err := d.push(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.pushBranch("feature", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	running, err := deployments.Create(ctx, &entity.Deployment{RepoID: d.repo.ID, Branch: "main", CommitSHA: entity.ZeroSHA, Status: entity.DeploymentStatusRunning})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remove.Execute(ctx, d.name, false); err != entity.ErrConflict {
+		t.Fatalf("delete during a deployment error = %v, want %v", err, entity.ErrConflict)
+	}
+	running.Status = entity.DeploymentStatusPending
+	if _, err := deployments.Update(ctx, running); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := remove.Execute(ctx, d.name, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.GetByName(ctx, d.name); err != entity.ErrNotFound {
+		t.Fatalf("get deleted repository error = %v, want %v", err, entity.ErrNotFound)
+	}
+	if got := d.instances(); len(got) != 0 {
+		t.Fatalf("instances after delete = %v, want none", got)
+	}
+	if queued, _ := deployments.GetByID(ctx, running.ID); queued.Status != entity.DeploymentStatusFailed {
+		t.Fatalf("queued deployment status = %s, want failed", queued.Status)
+	}
+	if _, err := os.Stat(bare); err != nil {
+		t.Fatalf("bare repository is gone before the restore window passed: %v", err)
+	}
+
+	restored, err := do.MustInvoke[RestoreRepositoryUsecase](d.injector).Execute(ctx, d.name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.ID != d.repo.ID || restored.DeletedAt != nil {
+		t.Fatalf("restored repository = %+v, want %s undeleted", restored, d.repo.ID)
+	}
+	// the app and the preview are relaunched from their images
+	pending, _ := deployments.ListByStatus(ctx, entity.DeploymentStatusPending)
+	if len(pending) != 2 {
+		t.Fatalf("queued deployments after restore = %d, want 2", len(pending))
+	}
+	for _, dep := range pending {
+		if _, err := do.MustInvoke[RunDeploymentUsecase](d.injector).Execute(ctx, dep.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := d.instances(); len(got) != 2 {
+		t.Fatalf("instances after restore = %v, want the app and the preview", got)
+	}
+
+	if err := remove.Execute(ctx, d.name, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.GetDeletedByName(ctx, d.name); err != entity.ErrNotFound {
+		t.Fatalf("get purged repository error = %v, want %v", err, entity.ErrNotFound)
+	}
+	if deps, _ := deployments.ListByRepo(ctx, d.repo.ID); len(deps) != 0 {
+		t.Fatalf("deployments after purge = %d, want none", len(deps))
+	}
+	if images, _ := d.runtime.Images(ctx); len(images) != 0 {
+		t.Fatalf("images after purge = %d, want none", len(images))
+	}
+	if _, err := os.Stat(bare); !os.IsNotExist(err) {
+		t.Fatalf("bare repository after purge: %v, want it removed", err)
+	}
+	if _, err := do.MustInvoke[RestoreRepositoryUsecase](d.injector).Execute(ctx, d.name); err != entity.ErrNotFound {
+		t.Fatalf("restore purged repository error = %v, want %v", err, entity.ErrNotFound)
+	}
+}
@@ -0,0 +1,93 @@
+package usecase
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+const followDeploymentLogInterval = 200 * time.Millisecond
+
+type FollowDeploymentLogUsecase interface {
+	// Execute calls onLine for every line of the build log, including the lines written while
+	// following, until the deployment finishes. If queuedTimeout is positive, it gives up once the
+	// deployment has been queued for that long and returns the still pending deployment.
+	Execute(ctx context.Context, id entity.ID, queuedTimeout time.Duration, onLine func(line string) error) (*entity.Deployment, error)
+}
+
+type followDeploymentLogUsecaseImpl struct {
+	deploymentRepository repository.DeploymentRepository
+	deploymentLogStorage storage.DeploymentLogStorage
+}
+
+// Execute implements FollowDeploymentLogUsecase.
+func (f *followDeploymentLogUsecaseImpl) Execute(ctx context.Context, id entity.ID, queuedTimeout time.Duration, onLine func(line string) error) (*entity.Deployment, error) {
+	var r *bufio.Reader
+	var partial string
+	queuedSince := time.Now()
+	for {
+		// check the status before reading, the log is complete once the deployment has finished
+		dep, err := f.deploymentRepository.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		finished := dep.Status == entity.DeploymentStatusSuccess || dep.Status == entity.DeploymentStatusFailed
+
+		if r == nil {
+			file, err := f.deploymentLogStorage.Open(id)
+			if err != nil && err != entity.ErrNotFound {
+				return nil, err
+			}
+			if file != nil {
+				defer file.Close()
+				r = bufio.NewReader(file)
+			}
+		}
+		if r != nil {
+			for {
+				line, err := r.ReadString('\n')
+				partial += line
+				if err != nil {
+					break
+				}
+				if err := onLine(strings.TrimSuffix(partial, "\n")); err != nil {
+					return nil, err
+				}
+				partial = ""
+			}
+		}
+
+		if finished {
+			if partial != "" {
+				if err := onLine(partial); err != nil {
+					return nil, err
+				}
+			}
+			return dep, nil
+		}
+		if dep.Status != entity.DeploymentStatusPending {
+			queuedSince = time.Now()
+		} else if queuedTimeout > 0 && time.Since(queuedSince) > queuedTimeout {
+			return dep, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(followDeploymentLogInterval):
+		}
+	}
+}
+
+func NewFollowDeploymentLogUsecase(injector *do.Injector) (FollowDeploymentLogUsecase, error) {
+	return &followDeploymentLogUsecaseImpl{
+		deploymentRepository: do.MustInvoke[repository.DeploymentRepository](injector),
+		deploymentLogStorage: do.MustInvoke[storage.DeploymentLogStorage](injector),
+	}, nil
+}
@@ -0,0 +1,115 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+func TestFollowDeploymentLog(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	deps := do.MustInvoke[repository.DeploymentRepository](d.injector)
+	logs := do.MustInvoke[storage.DeploymentLogStorage](d.injector)
+	follow := do.MustInvoke[FollowDeploymentLogUsecase](d.injector)
+
+	dep, err := deps.Create(ctx, &entity.Deployment{RepoID: d.repo.ID, Branch: "main", CommitSHA: entity.ZeroSHA, Status: entity.DeploymentStatusPending})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the build runs while the log is followed and ends with an unterminated line
+	go func() {
+		time.Sleep(2 * followDeploymentLogInterval)
+		dep.Status = entity.DeploymentStatusRunning
+		if _, err := deps.Update(ctx, dep); err != nil {
+			t.Error(err)
+			return
+		}
+		w, err := logs.Create(dep.ID)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer w.Close()
+		fmt.Fprint(w, "Step 1/2 : FROM scratch\n")
+		time.Sleep(2 * followDeploymentLogInterval)
+		fmt.Fprint(w, "Step 2/2 : COPY . .\nDeployment succ")
+		time.Sleep(followDeploymentLogInterval)
+		fmt.Fprint(w, "eeded")
+		dep.Status = entity.DeploymentStatusSuccess
+		if _, err := deps.Update(ctx, dep); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var lines []string
+	finished, err := follow.Execute(ctx, dep.ID, 0, func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if finished.Status != entity.DeploymentStatusSuccess {
+		t.Fatalf("followed deployment status = %s, want success", finished.Status)
+	}
+	want := []string{"Step 1/2 : FROM scratch", "Step 2/2 : COPY . .", "Deployment succeeded"}
+	if !slices.Equal(lines, want) {
+		t.Fatalf("followed lines = %q, want %q", lines, want)
+	}
+
+	// following a finished deployment replays its log
+	lines = nil
+	if _, err := follow.Execute(ctx, dep.ID, 0, func(line string) error {
+		lines = append(lines, line)
+		return nil
+	}); err != nil || !slices.Equal(lines, want) {
+		t.Fatalf("replayed lines = %q, %v, want %q", lines, err, want)
+	}
+	r, err := do.MustInvoke[GetDeploymentLogUsecase](d.injector).Execute(ctx, dep.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if data, _ := io.ReadAll(r); string(data) != "Step 1/2 : FROM scratch\nStep 2/2 : COPY . .\nDeployment succeeded" {
+		t.Fatalf("build log = %q", data)
+	}
+}
+
+func TestFollowQueuedDeploymentLog(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	follow := do.MustInvoke[FollowDeploymentLogUsecase](d.injector)
+
+	dep, err := do.MustInvoke[repository.DeploymentRepository](d.injector).Create(ctx, &entity.Deployment{RepoID: d.repo.ID, Branch: "main", CommitSHA: entity.ZeroSHA, Status: entity.DeploymentStatusPending})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a deployment that stays queued is given up on after the timeout
+	pending, err := follow.Execute(ctx, dep.ID, followDeploymentLogInterval, func(line string) error {
+		t.Errorf("line %q of a queued deployment", line)
+		return nil
+	})
+	if err != nil || pending.Status != entity.DeploymentStatusPending {
+		t.Fatalf("followed queued deployment = %+v, %v, want it still pending", pending, err)
+	}
+
+	// without a timeout, following ends with the context
+	ctx, cancel := context.WithTimeout(ctx, followDeploymentLogInterval)
+	defer cancel()
+	if _, err := follow.Execute(ctx, dep.ID, 0, func(string) error { return nil }); err != context.DeadlineExceeded {
+		t.Fatalf("follow error after the context ended = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if _, err := follow.Execute(context.Background(), "999", 0, func(string) error { return nil }); err != entity.ErrNotFound {
+		t.Fatalf("follow of an unknown deployment error = %v, want %v", err, entity.ErrNotFound)
+	}
+}
@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/git"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+// maxDiffBytes is the size of the largest patch returned with a commit, larger ones are cut.
+const maxDiffBytes = 1 << 20
+
+type GetCommitUsecase interface {
+	// Execute returns the commit with its stats and its patch against its first parent.
+	// It returns entity.ErrNotFound if the repository has no such commit.
+	Execute(ctx context.Context, reponame, sha string) (*entity.Commit, error)
+}
+
+type getCommitUsecaseImpl struct {
+	gitStorage           storage.GitStorage
+	repositoryRepository repository.RepositoryRepository
+}
+
+// Execute implements GetCommitUsecase.
+func (g *getCommitUsecaseImpl) Execute(ctx context.Context, reponame, sha string) (*entity.Commit, error) {
+	repo, err := g.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	reader := git.NewReader(g.gitStorage.GetRepoDir(repo.FullName()))
+	commit, err := reader.Commit(ctx, sha)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, entity.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	commit.Diff, commit.DiffTruncated, err = reader.Diff(ctx, commit.SHA, maxDiffBytes)
+	if err != nil {
+		return nil, err
+	}
+	return commit, nil
+}
+
+func NewGetCommitUsecase(injector *do.Injector) (GetCommitUsecase, error) {
+	return &getCommitUsecaseImpl{
+		gitStorage:           do.MustInvoke[storage.GitStorage](injector),
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+	}, nil
+}
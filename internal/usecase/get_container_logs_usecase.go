@@ -0,0 +1,66 @@
+package usecase
+
+import (
+	"context"
+	"io"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type ContainerLogsOptions struct {
+	// Branch selects the preview of the branch instead of the app, unless it is the deploy branch.
+	Branch string
+	Tail   string
+	Since  string
+	Follow bool
+}
+
+type GetContainerLogsUsecase interface {
+	// Execute copies the stdout and stderr of the container of the active deployment of the repository.
+	// It returns entity.ErrNotFound if the repository has no active deployment or its container is gone.
+	Execute(ctx context.Context, name string, opts ContainerLogsOptions, stdout, stderr io.Writer) error
+}
+
+type getContainerLogsUsecaseImpl struct {
+	repositoryRepository repository.RepositoryRepository
+	deploymentRepository repository.DeploymentRepository
+	deployer             deployer.Deployer
+}
+
+// Execute implements GetContainerLogsUsecase.
+func (g *getContainerLogsUsecaseImpl) Execute(ctx context.Context, name string, opts ContainerLogsOptions, stdout, stderr io.Writer) error {
+	repo, err := g.repositoryRepository.GetByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	var active *entity.Deployment
+	if opts.Branch != "" && opts.Branch != repo.DeployBranch {
+		active, err = g.deploymentRepository.GetActivePreview(ctx, repo.ID, opts.Branch)
+	} else {
+		active, err = g.deploymentRepository.GetActiveByRepo(ctx, repo.ID)
+	}
+	if err != nil {
+		return err
+	}
+	err = g.deployer.Logs(ctx, &deployer.LogsRequest{
+		DeploymentID: active.ID,
+		Tail:         opts.Tail,
+		Since:        opts.Since,
+		Follow:       opts.Follow,
+	}, stdout, stderr)
+	if err == deployer.ErrContainerNotFound {
+		return entity.ErrNotFound
+	}
+	return err
+}
+
+func NewGetContainerLogsUsecase(injector *do.Injector) (GetContainerLogsUsecase, error) {
+	return &getContainerLogsUsecaseImpl{
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+		deploymentRepository: do.MustInvoke[repository.DeploymentRepository](injector),
+		deployer:             do.MustInvoke[deployer.Deployer](injector),
+	}, nil
+}
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+)
+
+func TestGetContainerLogs(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	logs := do.MustInvoke[GetContainerLogsUsecase](d.injector)
+
+	if err := logs.Execute(ctx, d.name, ContainerLogsOptions{}, io.Discard, io.Discard); err != entity.ErrNotFound {
+		t.Fatalf("logs before the first deployment error = %v, want %v", err, entity.ErrNotFound)
+	}
+
+	var got *deployer.LogsOptions
+	d.runtime.LogsFunc = func(inst *deployer.Instance, opts *deployer.LogsOptions, stdout, stderr io.Writer) error {
+		got = opts
+		fmt.Fprintf(stdout, "out of %s\n", inst.Labels[deployer.LabelDeployment])
+		fmt.Fprintf(stderr, "err of %s\n", inst.Labels[deployer.LabelDeployment])
+		return nil
+	}
+	app, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	preview, err := d.pushBranch("feature", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the deploy branch and no branch both select the app, other branches their preview
+	for _, tc := range []struct {
+		branch string
+		want   entity.ID
+	}{
+		{"", app.ID},
+		{"main", app.ID},
+		{"feature", preview.ID},
+	} {
+		var stdout, stderr bytes.Buffer
+		opts := ContainerLogsOptions{Branch: tc.branch, Tail: "10", Since: "1h", Follow: true}
+		if err := logs.Execute(ctx, d.name, opts, &stdout, &stderr); err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("out of %s\n", tc.want); stdout.String() != want {
+			t.Errorf("stdout of branch %q = %q, want %q", tc.branch, stdout.String(), want)
+		}
+		if want := fmt.Sprintf("err of %s\n", tc.want); stderr.String() != want {
+			t.Errorf("stderr of branch %q = %q, want %q", tc.branch, stderr.String(), want)
+		}
+		if *got != (deployer.LogsOptions{Tail: "10", Since: "1h", Follow: true}) {
+			t.Errorf("logs options = %+v, want tail, since and follow passed on", got)
+		}
+	}
+
+	if err := logs.Execute(ctx, d.name, ContainerLogsOptions{Branch: "unknown"}, io.Discard, io.Discard); err != entity.ErrNotFound {
+		t.Fatalf("logs of a branch without a preview error = %v, want %v", err, entity.ErrNotFound)
+	}
+	// the container of the active deployment is gone
+	instances, err := d.runtime.List(ctx, map[string]string{deployer.LabelDeployment: app.ID.String()})
+	if err != nil || len(instances) != 1 {
+		t.Fatalf("instances of the app = %v, %v, want one", instances, err)
+	}
+	if err := d.runtime.Stop(ctx, instances[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := logs.Execute(ctx, d.name, ContainerLogsOptions{}, io.Discard, io.Discard); err != entity.ErrNotFound {
+		t.Fatalf("logs of a removed container error = %v, want %v", err, entity.ErrNotFound)
+	}
+}
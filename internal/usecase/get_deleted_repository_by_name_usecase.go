@@ -0,0 +1,29 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type GetDeletedRepositoryByNameUsecase interface {
+	// Execute returns the most recently deleted repository of the full name that is not purged yet.
+	Execute(ctx context.Context, name string) (*entity.Repository, error)
+}
+
+type getDeletedRepositoryByNameUsecaseImpl struct {
+	repositoryRepository repository.RepositoryRepository
+}
+
+// Execute implements GetDeletedRepositoryByNameUsecase.
+func (g *getDeletedRepositoryByNameUsecaseImpl) Execute(ctx context.Context, name string) (*entity.Repository, error) {
+	return g.repositoryRepository.GetDeletedByName(ctx, name)
+}
+
+func NewGetDeletedRepositoryByNameUsecase(injector *do.Injector) (GetDeletedRepositoryByNameUsecase, error) {
+	return &getDeletedRepositoryByNameUsecaseImpl{
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+	}, nil
+}
@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+type GetDeploymentLogUsecase interface {
+	// Execute returns the build log of the deployment written so far. It is empty while the deployment is queued.
+	Execute(ctx context.Context, id entity.ID) (io.ReadCloser, error)
+}
+
+type getDeploymentLogUsecaseImpl struct {
+	deploymentRepository repository.DeploymentRepository
+	deploymentLogStorage storage.DeploymentLogStorage
+}
+
+// Execute implements GetDeploymentLogUsecase.
+func (g *getDeploymentLogUsecaseImpl) Execute(ctx context.Context, id entity.ID) (io.ReadCloser, error) {
+	if _, err := g.deploymentRepository.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	r, err := g.deploymentLogStorage.Open(id)
+	if err == entity.ErrNotFound {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	return r, err
+}
+
+func NewGetDeploymentLogUsecase(injector *do.Injector) (GetDeploymentLogUsecase, error) {
+	return &getDeploymentLogUsecaseImpl{
+		deploymentRepository: do.MustInvoke[repository.DeploymentRepository](injector),
+		deploymentLogStorage: do.MustInvoke[storage.DeploymentLogStorage](injector),
+	}, nil
+}
@@ -0,0 +1,36 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type GetRepositoryByRedirectUsecase interface {
+	// Execute returns the repository that was renamed from the name.
+	// It returns entity.ErrNotFound if no repository had the name.
+	Execute(ctx context.Context, name string) (*entity.Repository, error)
+}
+
+type getRepositoryByRedirectUsecaseImpl struct {
+	repositoryRepository repository.RepositoryRepository
+	redirectRepository   repository.RepositoryRedirectRepository
+}
+
+// Execute implements GetRepositoryByRedirectUsecase.
+func (g *getRepositoryByRedirectUsecaseImpl) Execute(ctx context.Context, name string) (*entity.Repository, error) {
+	redirect, err := g.redirectRepository.GetByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return g.repositoryRepository.GetByID(ctx, redirect.RepoID)
+}
+
+func NewGetRepositoryByRedirectUsecase(injector *do.Injector) (GetRepositoryByRedirectUsecase, error) {
+	return &getRepositoryByRedirectUsecaseImpl{
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+		redirectRepository:   do.MustInvoke[repository.RepositoryRedirectRepository](injector),
+	}, nil
+}
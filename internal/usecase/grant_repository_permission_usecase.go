@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type GrantRepositoryPermissionUsecase interface {
+	// Execute grants the user access to the repository. Granting AccessNone revokes it.
+	Execute(ctx context.Context, reponame, username string, access entity.Access) error
+}
+
+type grantRepositoryPermissionUsecaseImpl struct {
+	repositoryRepository           repository.RepositoryRepository
+	userRepository                 repository.UserRepository
+	repositoryPermissionRepository repository.RepositoryPermissionRepository
+}
+
+// Execute implements GrantRepositoryPermissionUsecase.
+func (g *grantRepositoryPermissionUsecaseImpl) Execute(ctx context.Context, reponame, username string, access entity.Access) error {
+	if access != entity.AccessNone && !access.IsValid() {
+		return entity.ErrInvalid
+	}
+	repo, err := g.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return err
+	}
+	user, err := g.userRepository.GetByName(ctx, username)
+	if err != nil {
+		return err
+	}
+	if access == entity.AccessNone {
+		return g.repositoryPermissionRepository.Delete(ctx, repo.ID, user.ID)
+	}
+	_, err = g.repositoryPermissionRepository.Set(ctx, &entity.RepositoryPermission{
+		RepoID: repo.ID,
+		UserID: user.ID,
+		Access: access,
+	})
+	return err
+}
+
+func NewGrantRepositoryPermissionUsecase(injector *do.Injector) (GrantRepositoryPermissionUsecase, error) {
+	return &grantRepositoryPermissionUsecaseImpl{
+		repositoryRepository:           do.MustInvoke[repository.RepositoryRepository](injector),
+		userRepository:                 do.MustInvoke[repository.UserRepository](injector),
+		repositoryPermissionRepository: do.MustInvoke[repository.RepositoryPermissionRepository](injector),
+	}, nil
+}
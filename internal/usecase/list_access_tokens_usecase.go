@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type ListAccessTokensUsecase interface {
+	Execute(ctx context.Context, username string) ([]*entity.AccessToken, error)
+}
+
+type listAccessTokensUsecaseImpl struct {
+	userRepository        repository.UserRepository
+	accessTokenRepository repository.AccessTokenRepository
+}
+
+// Execute implements ListAccessTokensUsecase.
+func (l *listAccessTokensUsecaseImpl) Execute(ctx context.Context, username string) ([]*entity.AccessToken, error) {
+	user, err := l.userRepository.GetByName(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return l.accessTokenRepository.ListByUser(ctx, user.ID)
+}
+
+func NewListAccessTokensUsecase(injector *do.Injector) (ListAccessTokensUsecase, error) {
+	return &listAccessTokensUsecaseImpl{
+		userRepository:        do.MustInvoke[repository.UserRepository](injector),
+		accessTokenRepository: do.MustInvoke[repository.AccessTokenRepository](injector),
+	}, nil
+}
@@ -0,0 +1,147 @@
+package usecase
+
+import (
+	"context"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/git"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+type ListCommitsOptions struct {
+	// Ref is the SHA, branch or tag whose history is listed, the deploy branch if empty.
+	Ref string
+	// Path limits the history to the commits that change the file or directory.
+	Path string
+	// Author limits the history to the commits whose author name or email contains it.
+	Author string
+	// Since and Until limit the commit dates, zero means no limit.
+	Since time.Time
+	Until time.Time
+	// Stats reads the number of changed lines and files of the commits.
+	Stats bool
+	// Cursor continues a listing with the same filters from the NextCursor of its previous page.
+	// Ref is ignored with a cursor.
+	Cursor string
+	Limit  int
+}
+
+type CommitList struct {
+	Commits []*entity.Commit
+	// NextCursor reads the next page, empty on the last page. It keeps reading the history of the
+	// commit the first page started from, so pushes in between do not shift the pages.
+	NextCursor string
+}
+
+type ListCommitsUsecase interface {
+	// Execute returns a page of the history of a ref, newest first.
+	// It returns entity.ErrInvalid for an unknown ref or an invalid cursor.
+	Execute(ctx context.Context, reponame string, opts ListCommitsOptions) (*CommitList, error)
+}
+
+type listCommitsUsecaseImpl struct {
+	gitStorage           storage.GitStorage
+	repositoryRepository repository.RepositoryRepository
+}
+
+// Execute implements ListCommitsUsecase.
+func (l *listCommitsUsecaseImpl) Execute(ctx context.Context, reponame string, opts ListCommitsOptions) (*CommitList, error) {
+	if opts.Limit < 1 {
+		return nil, entity.ErrInvalid
+	}
+	repo, err := l.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	repoDir := l.gitStorage.GetRepoDir(repo.FullName())
+
+	var from string
+	var skip int
+	if opts.Cursor != "" {
+		var ok bool
+		if from, skip, ok = decodeCommitCursor(opts.Cursor); !ok {
+			return nil, entity.ErrInvalid
+		}
+	} else {
+		ref := opts.Ref
+		if ref == "" {
+			ref = repo.DeployRef()
+		}
+		from, err = git.ResolveCommit(ctx, repoDir, ref)
+		if err != nil {
+			// the deploy branch has not been pushed yet
+			if opts.Ref == "" {
+				return &CommitList{Commits: []*entity.Commit{}}, nil
+			}
+			return nil, entity.ErrInvalid
+		}
+	}
+
+	// one more commit than asked for tells whether there is a next page
+	commits, err := git.NewReader(repoDir).Log(ctx, git.LogOptions{
+		From:   from,
+		Skip:   skip,
+		Max:    opts.Limit + 1,
+		Path:   opts.Path,
+		Since:  opts.Since,
+		Until:  opts.Until,
+		Author: opts.Author,
+		Stats:  opts.Stats,
+	})
+	if err != nil {
+		// the commit of a cursor may be gone after a force push and garbage collection
+		if opts.Cursor != "" {
+			return nil, entity.ErrInvalid
+		}
+		return nil, err
+	}
+	list := &CommitList{Commits: commits}
+	if len(commits) > opts.Limit {
+		list.Commits = commits[:opts.Limit]
+		list.NextCursor = encodeCommitCursor(from, skip+opts.Limit)
+	}
+	return list, nil
+}
+
+// encodeCommitCursor encodes the position in the history of the commit from, as the number of
+// commits already listed.
+func encodeCommitCursor(from string, skip int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(from + ":" + strconv.Itoa(skip)))
+}
+
+func decodeCommitCursor(cursor string) (string, int, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, false
+	}
+	from, s, ok := strings.Cut(string(raw), ":")
+	if !ok || !isCommitSHA(from) {
+		return "", 0, false
+	}
+	skip, err := strconv.Atoi(s)
+	if err != nil || skip < 0 {
+		return "", 0, false
+	}
+	return from, skip, true
+}
+
+// isCommitSHA reports whether s is a full SHA-1 or SHA-256 object name.
+func isCommitSHA(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	return strings.Trim(s, "0123456789abcdef") == ""
+}
+
+func NewListCommitsUsecase(injector *do.Injector) (ListCommitsUsecase, error) {
+	return &listCommitsUsecaseImpl{
+		gitStorage:           do.MustInvoke[storage.GitStorage](injector),
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+	}, nil
+}
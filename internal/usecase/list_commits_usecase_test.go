@@ -0,0 +1,116 @@
+package usecase
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+func TestCommits(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	bare := do.MustInvoke[storage.GitStorage](d.injector).GetRepoDir(d.name)
+	list := do.MustInvoke[ListCommitsUsecase](d.injector)
+	get := do.MustInvoke[GetCommitUsecase](d.injector)
+
+	// the deploy branch has not been pushed yet
+	page, err := list.Execute(ctx, d.name, ListCommitsOptions{Limit: 10})
+	if err != nil || len(page.Commits) != 0 {
+		t.Fatalf("commits of an empty repository = %+v, %v, want none", page, err)
+	}
+
+	commit := func(file, content string, args ...string) string {
+		t.Helper()
+		if file != "" {
+			if err := os.MkdirAll(filepath.Join(d.work, filepath.Dir(file)), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(d.work, file), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		runGit(t, d.work, "add", "-A")
+		runGit(t, d.work, append([]string{"commit", "--allow-empty", "-m", "change " + file}, args...)...)
+		runGit(t, d.work, "push", bare, "HEAD:main")
+		return runGit(t, d.work, "rev-parse", "HEAD")
+	}
+	shas := func(commits []*entity.Commit) []string {
+		var shas []string
+		for _, c := range commits {
+			shas = append(shas, c.SHA)
+		}
+		return shas
+	}
+	c1 := commit("a.txt", "one\n")
+	c2 := commit("docs/readme", "hello\n", "--author", "Bob <bob@example.com>")
+	c3 := commit("a.txt", "two\nthree\n")
+
+	page, err = list.Execute(ctx, d.name, ListCommitsOptions{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := shas(page.Commits); !slices.Equal(got, []string{c3, c2}) || page.NextCursor == "" {
+		t.Fatalf("first page = %v, next %q, want [%s %s] and a cursor", got, page.NextCursor, c3, c2)
+	}
+	if c := page.Commits[1]; c.Author.Name != "Bob" || c.Committer.Name != "test" || c.Message != "change docs/readme" ||
+		!slices.Equal(c.Parents, []string{c1}) || c.Stats != nil {
+		t.Fatalf("second commit = %+v, want by Bob, committed by test, after %s and without stats", c, c1)
+	}
+	// a push between pages does not shift them
+	commit("", "")
+	page, err = list.Execute(ctx, d.name, ListCommitsOptions{Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := shas(page.Commits); !slices.Equal(got, []string{c1}) || page.NextCursor != "" {
+		t.Fatalf("second page = %v, next %q, want [%s] and no cursor", got, page.NextCursor, c1)
+	}
+
+	page, err = list.Execute(ctx, d.name, ListCommitsOptions{Limit: 10, Path: "a.txt", Stats: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := shas(page.Commits); !slices.Equal(got, []string{c3, c1}) {
+		t.Fatalf("commits of a.txt = %v, want [%s %s]", got, c3, c1)
+	}
+	if stats := page.Commits[0].Stats; stats == nil || *stats != (entity.CommitStats{Additions: 2, Deletions: 1, Files: 1}) {
+		t.Fatalf("stats of %s = %+v, want 2 additions and 1 deletion in 1 file", c3, stats)
+	}
+	page, err = list.Execute(ctx, d.name, ListCommitsOptions{Limit: 10, Author: "BOB@example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := shas(page.Commits); !slices.Equal(got, []string{c2}) {
+		t.Fatalf("commits by bob = %v, want [%s]", got, c2)
+	}
+	page, err = list.Execute(ctx, d.name, ListCommitsOptions{Limit: 10, Ref: c2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := shas(page.Commits); !slices.Equal(got, []string{c2, c1}) {
+		t.Fatalf("commits of %s = %v, want [%s %s]", c2, got, c2, c1)
+	}
+	for _, opts := range []ListCommitsOptions{{Limit: 10, Ref: "unknown"}, {Limit: 10, Cursor: "garbage"}, {Limit: 0}} {
+		if _, err := list.Execute(ctx, d.name, opts); err != entity.ErrInvalid {
+			t.Fatalf("list commits with %+v error = %v, want %v", opts, err, entity.ErrInvalid)
+		}
+	}
+
+	got, err := get.Execute(ctx, d.name, c3[:7])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SHA != c3 || got.Stats == nil || got.Stats.Files != 1 || !strings.Contains(got.Diff, "+three\n") || got.DiffTruncated {
+		t.Fatalf("commit %s = %+v, want its stats and diff", c3, got)
+	}
+	if _, err := get.Execute(ctx, d.name, strings.Repeat("0", 40)); err != entity.ErrNotFound {
+		t.Fatalf("get unknown commit error = %v, want %v", err, entity.ErrNotFound)
+	}
+}
@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type ListDeploymentsUsecase interface {
+	Execute(ctx context.Context, reponame string) ([]*entity.Deployment, error)
+}
+
+type listDeploymentsUsecaseImpl struct {
+	repositoryRepository repository.RepositoryRepository
+	deploymentRepository repository.DeploymentRepository
+}
+
+// Execute implements ListDeploymentsUsecase.
+func (l *listDeploymentsUsecaseImpl) Execute(ctx context.Context, reponame string) ([]*entity.Deployment, error) {
+	repo, err := l.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	return l.deploymentRepository.ListByRepo(ctx, repo.ID)
+}
+
+func NewListDeploymentsUsecase(injector *do.Injector) (ListDeploymentsUsecase, error) {
+	return &listDeploymentsUsecaseImpl{
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+		deploymentRepository: do.MustInvoke[repository.DeploymentRepository](injector),
+	}, nil
+}
@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/secret"
+)
+
+type ListEnvVarsUsecase interface {
+	// Execute lists the environment variables of the repository, without the values of secrets.
+	Execute(ctx context.Context, reponame string) ([]*entity.EnvVar, error)
+}
+
+type listEnvVarsUsecaseImpl struct {
+	repositoryRepository repository.RepositoryRepository
+	envVarRepository     repository.EnvVarRepository
+}
+
+// Execute implements ListEnvVarsUsecase.
+func (l *listEnvVarsUsecaseImpl) Execute(ctx context.Context, reponame string) ([]*entity.EnvVar, error) {
+	repo, err := l.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	envs, err := l.envVarRepository.ListByRepo(ctx, repo.ID)
+	if err != nil {
+		return nil, envVarError(err)
+	}
+	for i, env := range envs {
+		envs[i] = env.Redacted()
+	}
+	return envs, nil
+}
+
+// envVarError hides why env vars are unreadable, a missing master key is reported as unavailable.
+func envVarError(err error) error {
+	if errors.Is(err, secret.ErrNoMasterKey) {
+		return entity.ErrUnavailable
+	}
+	if err == entity.ErrNotFound {
+		return err
+	}
+	return entity.ErrInternal
+}
+
+func NewListEnvVarsUsecase(injector *do.Injector) (ListEnvVarsUsecase, error) {
+	return &listEnvVarsUsecaseImpl{
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+		envVarRepository:     do.MustInvoke[repository.EnvVarRepository](injector),
+	}, nil
+}
@@ -0,0 +1,28 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type ListOrganizationsUsecase interface {
+	Execute(ctx context.Context) ([]*entity.Organization, error)
+}
+
+type listOrganizationsUsecaseImpl struct {
+	organizationRepository repository.OrganizationRepository
+}
+
+// Execute implements ListOrganizationsUsecase.
+func (l *listOrganizationsUsecaseImpl) Execute(ctx context.Context) ([]*entity.Organization, error) {
+	return l.organizationRepository.List(ctx)
+}
+
+func NewListOrganizationsUsecase(injector *do.Injector) (ListOrganizationsUsecase, error) {
+	return &listOrganizationsUsecaseImpl{
+		organizationRepository: do.MustInvoke[repository.OrganizationRepository](injector),
+	}, nil
+}
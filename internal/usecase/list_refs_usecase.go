@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/git"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+// Page selects Size items of a list, pages are numbered from 1.
+type Page struct {
+	Number int
+	Size   int
+}
+
+type RefList struct {
+	Refs []*entity.Ref
+	// Total is the number of refs on all pages.
+	Total int
+}
+
+type ListRefsUsecase interface {
+	// Execute returns a page of the branches, sorted by name, or of the tags, newest first.
+	Execute(ctx context.Context, reponame string, refType entity.RefType, page Page) (*RefList, error)
+}
+
+type listRefsUsecaseImpl struct {
+	gitStorage           storage.GitStorage
+	repositoryRepository repository.RepositoryRepository
+}
+
+// Execute implements ListRefsUsecase.
+func (l *listRefsUsecaseImpl) Execute(ctx context.Context, reponame string, refType entity.RefType, page Page) (*RefList, error) {
+	if page.Number < 1 || page.Size < 1 {
+		return nil, entity.ErrInvalid
+	}
+	repo, err := l.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	reader := git.NewReader(l.gitStorage.GetRepoDir(repo.FullName()))
+	var refs []*entity.Ref
+	if refType == entity.RefTypeTag {
+		refs, err = reader.Tags(ctx)
+	} else {
+		refs, err = reader.Branches(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := &RefList{Refs: []*entity.Ref{}, Total: len(refs)}
+	if start := (page.Number - 1) * page.Size; start < len(refs) {
+		list.Refs = refs[start:min(start+page.Size, len(refs))]
+	}
+	return list, nil
+}
+
+func NewListRefsUsecase(injector *do.Injector) (ListRefsUsecase, error) {
+	return &listRefsUsecaseImpl{
+		gitStorage:           do.MustInvoke[storage.GitStorage](injector),
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+	}, nil
+}
@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+func TestRefs(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	bare := do.MustInvoke[storage.GitStorage](d.injector).GetRepoDir(d.name)
+	list := do.MustInvoke[ListRefsUsecase](d.injector)
+	create := do.MustInvoke[CreateRefUsecase](d.injector)
+	remove := do.MustInvoke[DeleteRefUsecase](d.injector)
+
+	first, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.pushBranch("feature/login", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// without a commit, refs point to the tip of the deploy branch
+	ref, err := create.Execute(ctx, d.name, entity.RefTypeBranch, "release", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.SHA != second.CommitSHA || ref.Author.Name != "test" || ref.Author.Email != "test@example.com" || ref.Author.Date.IsZero() {
+		t.Fatalf("created branch = %+v, want the tip of main by test", ref)
+	}
+	if _, err := create.Execute(ctx, d.name, entity.RefTypeBranch, "release", first.CommitSHA); err != entity.ErrConflict {
+		t.Fatalf("create existing branch error = %v, want %v", err, entity.ErrConflict)
+	}
+	for _, tc := range []struct{ name, commit string }{{"-x", ""}, {"a..b", ""}, {"ok", "unknown"}} {
+		if _, err := create.Execute(ctx, d.name, entity.RefTypeBranch, tc.name, tc.commit); err != entity.ErrInvalid {
+			t.Fatalf("create branch %q of %q error = %v, want %v", tc.name, tc.commit, err, entity.ErrInvalid)
+		}
+	}
+	if _, err := create.Execute(ctx, d.name, entity.RefTypeTag, "v1", first.CommitSHA); err != nil {
+		t.Fatal(err)
+	}
+	// annotated tags are listed with their commit
+	runGit(t, d.work, "tag", "-a", "v2", "-m", "second release", second.CommitSHA)
+	runGit(t, d.work, "push", bare, "v2")
+
+	page, err := list.Execute(ctx, d.name, entity.RefTypeBranch, Page{Number: 1, Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || len(page.Refs) != 2 || page.Refs[0].Name != "feature/login" || page.Refs[1].Name != "main" {
+		t.Fatalf("first page of branches = %+v, want feature/login and main of 3", page)
+	}
+	page, err = list.Execute(ctx, d.name, entity.RefTypeBranch, Page{Number: 2, Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Refs) != 1 || page.Refs[0].Name != "release" {
+		t.Fatalf("second page of branches = %+v, want release", page)
+	}
+	page, err = list.Execute(ctx, d.name, entity.RefTypeBranch, Page{Number: 3, Size: 2})
+	if err != nil || len(page.Refs) != 0 {
+		t.Fatalf("page past the end = %+v, %v, want no branches", page, err)
+	}
+	page, err = list.Execute(ctx, d.name, entity.RefTypeTag, Page{Number: 1, Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shas := map[string]string{}
+	for _, ref := range page.Refs {
+		shas[ref.Name] = ref.SHA
+	}
+	if len(shas) != 2 || shas["v1"] != first.CommitSHA || shas["v2"] != second.CommitSHA {
+		t.Fatalf("tags = %v, want v1 at %s and v2 at %s", shas, first.CommitSHA, second.CommitSHA)
+	}
+
+	if err := remove.Execute(ctx, d.name, entity.RefTypeBranch, "main"); err != entity.ErrConflict {
+		t.Fatalf("delete deploy branch error = %v, want %v", err, entity.ErrConflict)
+	}
+	if err := remove.Execute(ctx, d.name, entity.RefTypeBranch, "feature"); err != entity.ErrNotFound {
+		t.Fatalf("delete parent of a branch error = %v, want %v", err, entity.ErrNotFound)
+	}
+	if err := remove.Execute(ctx, d.name, entity.RefTypeBranch, "feature/login"); err != nil {
+		t.Fatal(err)
+	}
+	deps, err := do.MustInvoke[repository.DeploymentRepository](d.injector).ListByRepo(ctx, d.repo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newest := newestPreview(deps, "feature/login"); newest == nil || !newest.IsTeardown() {
+		t.Fatalf("newest preview deployment = %+v, want its removal queued", newest)
+	}
+	if err := remove.Execute(ctx, d.name, entity.RefTypeTag, "v2"); err != nil {
+		t.Fatal(err)
+	}
+	page, err = list.Execute(ctx, d.name, entity.RefTypeTag, Page{Number: 1, Size: 10})
+	if err != nil || len(page.Refs) != 1 || page.Refs[0].Name != "v1" {
+		t.Fatalf("tags after delete = %+v, %v, want v1", page, err)
+	}
+}
@@ -9,20 +9,36 @@ import (
 )
 
 type ListRepositoryUsecase interface {
-	Execute(ctx context.Context) ([]*entity.Repository, error)
+	// Execute lists the repositories of the owner, or all repositories if owner is empty.
+	// It returns entity.ErrNotFound if the owner does not exist.
+	Execute(ctx context.Context, owner string) ([]*entity.Repository, error)
 }
 
 type listRepositoryUsecaseImpl struct {
-	repositoryRepository repository.RepositoryRepository
+	repositoryRepository   repository.RepositoryRepository
+	userRepository         repository.UserRepository
+	organizationRepository repository.OrganizationRepository
 }
 
 // Execute implements ListRepositoryUsecase.
-func (l *listRepositoryUsecaseImpl) Execute(ctx context.Context) ([]*entity.Repository, error) {
-	return l.repositoryRepository.List(ctx)
+func (l *listRepositoryUsecaseImpl) Execute(ctx context.Context, owner string) ([]*entity.Repository, error) {
+	if owner == "" {
+		return l.repositoryRepository.List(ctx)
+	}
+	exists, err := ownerExists(ctx, l.userRepository, l.organizationRepository, owner)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	if !exists {
+		return nil, entity.ErrNotFound
+	}
+	return l.repositoryRepository.ListByOwner(ctx, owner)
 }
 
 func NewListRepositoryUsecase(injector *do.Injector) (ListRepositoryUsecase, error) {
 	return &listRepositoryUsecaseImpl{
-		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+		repositoryRepository:   do.MustInvoke[repository.RepositoryRepository](injector),
+		userRepository:         do.MustInvoke[repository.UserRepository](injector),
+		organizationRepository: do.MustInvoke[repository.OrganizationRepository](injector),
 	}, nil
 }
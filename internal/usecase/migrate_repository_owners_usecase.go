@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type MigrateRepositoryOwnersUsecase interface {
+	// Execute moves the repositories created before owners existed into the namespace of the
+	// user or organization, see RenameRepositoryUsecase, and returns them. Their old names keep
+	// working as redirects. Repositories that fail to move, e.g. while one of their deployments is
+	// running, are reported in the error and are moved by the next call. It returns
+	// entity.ErrInvalid if the owner does not exist.
+	Execute(ctx context.Context, owner string) ([]*entity.Repository, error)
+}
+
+type migrateRepositoryOwnersUsecaseImpl struct {
+	repositoryRepository    repository.RepositoryRepository
+	userRepository          repository.UserRepository
+	organizationRepository  repository.OrganizationRepository
+	renameRepositoryUsecase RenameRepositoryUsecase
+}
+
+// Execute implements MigrateRepositoryOwnersUsecase.
+func (m *migrateRepositoryOwnersUsecaseImpl) Execute(ctx context.Context, owner string) ([]*entity.Repository, error) {
+	exists, err := ownerExists(ctx, m.userRepository, m.organizationRepository, owner)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	if !exists {
+		return nil, entity.ErrInvalid
+	}
+	repos, err := m.repositoryRepository.ListByOwner(ctx, "")
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	var migrated []*entity.Repository
+	var errs []error
+	for _, repo := range repos {
+		moved, err := m.renameRepositoryUsecase.Execute(ctx, repo.FullName(), entity.JoinFullName(owner, repo.Name))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", repo.FullName(), err))
+			continue
+		}
+		migrated = append(migrated, moved)
+	}
+	return migrated, errors.Join(errs...)
+}
+
+func NewMigrateRepositoryOwnersUsecase(injector *do.Injector) (MigrateRepositoryOwnersUsecase, error) {
+	return &migrateRepositoryOwnersUsecaseImpl{
+		repositoryRepository:    do.MustInvoke[repository.RepositoryRepository](injector),
+		userRepository:          do.MustInvoke[repository.UserRepository](injector),
+		organizationRepository:  do.MustInvoke[repository.OrganizationRepository](injector),
+		renameRepositoryUsecase: do.MustInvoke[RenameRepositoryUsecase](injector),
+	}, nil
+}
@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+func TestMigrateRepositoryOwners(t *testing.T) {
+	d := newDeployTestOwnedBy(t, "")
+	ctx := context.Background()
+	gitStorage := do.MustInvoke[storage.GitStorage](d.injector)
+	migrate := do.MustInvoke[MigrateRepositoryOwnersUsecase](d.injector)
+
+	// repositories without an owner keep deploying until they are migrated
+	before, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host := d.repo.AppHost("apps.local"); host != "app.apps.local" {
+		t.Fatalf("host without an owner = %s, want app.apps.local", host)
+	}
+
+	if _, err := migrate.Execute(ctx, "nobody"); err != entity.ErrInvalid {
+		t.Fatalf("migration to a missing owner error = %v, want %v", err, entity.ErrInvalid)
+	}
+	repos, err := migrate.Execute(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 || repos[0].FullName() != "alice/app" {
+		t.Fatalf("migrated repositories = %v, want alice/app", repos)
+	}
+	d.name = "alice/app"
+	if gitStorage.IsRepoExist("app") || !gitStorage.IsRepoExist("alice/app") {
+		t.Fatal("bare repository was not moved into the namespace of the owner")
+	}
+	if host := repos[0].AppHost("apps.local"); host != "app.alice.apps.local" {
+		t.Fatalf("host after migration = %s, want app.alice.apps.local", host)
+	}
+	if ok, _ := d.runtime.HasImage(ctx, "alice/app", before.CommitSHA); !ok {
+		t.Fatal("image was not moved to the full name")
+	}
+	redirected, err := do.MustInvoke[GetRepositoryByRedirectUsecase](d.injector).Execute(ctx, "app")
+	if err != nil || redirected.ID != d.repo.ID {
+		t.Fatalf("redirect of the name without owner = %+v, %v, want %s", redirected, err, d.repo.ID)
+	}
+	if flat, _ := do.MustInvoke[repository.RepositoryRepository](d.injector).ListByOwner(ctx, ""); len(flat) != 0 {
+		t.Fatalf("repositories without an owner after migration = %d, want none", len(flat))
+	}
+
+	// the relaunch replaces the container started under the old name
+	pending, _ := do.MustInvoke[repository.DeploymentRepository](d.injector).ListByStatus(ctx, entity.DeploymentStatusPending)
+	if len(pending) != 1 {
+		t.Fatalf("queued deployments after migration = %d, want 1", len(pending))
+	}
+	if _, err := do.MustInvoke[RunDeploymentUsecase](d.injector).Execute(ctx, pending[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.instances(); len(got) != 1 || got[0] != pending[0].ID {
+		t.Fatalf("instances of alice/app = %v, want [%s]", got, pending[0].ID)
+	}
+	after, err := d.push(nil)
+	if err != nil || after.Status != entity.DeploymentStatusSuccess {
+		t.Fatalf("deployment after migration = %+v, %v, want success", after, err)
+	}
+
+	// nothing is left to migrate
+	if repos, err := migrate.Execute(ctx, "alice"); err != nil || len(repos) != 0 {
+		t.Fatalf("second migration = %v, %v, want nothing", repos, err)
+	}
+}
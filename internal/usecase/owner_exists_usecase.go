@@ -0,0 +1,30 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type OwnerExistsUsecase interface {
+	// Execute reports whether a user or an organization of the name exists.
+	Execute(ctx context.Context, name string) (bool, error)
+}
+
+type ownerExistsUsecaseImpl struct {
+	userRepository         repository.UserRepository
+	organizationRepository repository.OrganizationRepository
+}
+
+// Execute implements OwnerExistsUsecase.
+func (o *ownerExistsUsecaseImpl) Execute(ctx context.Context, name string) (bool, error) {
+	return ownerExists(ctx, o.userRepository, o.organizationRepository, name)
+}
+
+func NewOwnerExistsUsecase(injector *do.Injector) (OwnerExistsUsecase, error) {
+	return &ownerExistsUsecaseImpl{
+		userRepository:         do.MustInvoke[repository.UserRepository](injector),
+		organizationRepository: do.MustInvoke[repository.OrganizationRepository](injector),
+	}, nil
+}
@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type PruneImagesOptions struct {
+	// Keep is the number of newest images kept per repository, at least one.
+	Keep int
+	// DryRun only reports what would be removed.
+	DryRun bool
+}
+
+type PruneImagesReport struct {
+	Images     []*deployer.Image
+	Containers []*deployer.Instance
+	// FreedBytes is the size of the removed images. Layers shared with kept images are counted
+	// too, so less disk space may actually be freed.
+	FreedBytes int64
+}
+
+type PruneImagesUsecase interface {
+	// Execute removes stopped containers and all but the newest images of every repository.
+	// The containers and images of active, queued and running deployments, and running containers
+	// with their images, are always kept.
+	Execute(ctx context.Context, opts PruneImagesOptions) (*PruneImagesReport, error)
+}
+
+type pruneImagesUsecaseImpl struct {
+	runtime              deployer.Runtime
+	repositoryRepository repository.RepositoryRepository
+	deploymentRepository repository.DeploymentRepository
+}
+
+// Execute implements PruneImagesUsecase.
+func (p *pruneImagesUsecaseImpl) Execute(ctx context.Context, opts PruneImagesOptions) (*PruneImagesReport, error) {
+	log := zerolog.Ctx(ctx)
+	if opts.Keep < 1 {
+		return nil, entity.ErrInvalid
+	}
+
+	protected, inUse, err := p.protectedImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PruneImagesReport{}
+	instances, err := p.runtime.List(ctx, map[string]string{deployer.LabelEnabled: "true"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	for _, inst := range instances {
+		status, err := p.runtime.Status(ctx, inst.ID, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect container: %w", err)
+		}
+		tag := inst.Labels[deployer.LabelRepo] + ":" + inst.Labels[deployer.LabelCommit]
+		// the crashed container of an active deployment is kept for its logs, and the container of
+		// a deployment in progress may not be started yet or restart during the health check
+		if status.Running || inUse[entity.ID(inst.Labels[deployer.LabelDeployment])] {
+			protected[tag] = true
+			continue
+		}
+		if !opts.DryRun {
+			if err := p.runtime.Stop(ctx, inst.ID); err != nil {
+				log.Error().Err(err).Str("container", inst.Name).Msg("failed to remove stopped container")
+				protected[tag] = true
+				continue
+			}
+			log.Info().Str("container", inst.Name).Msg("removed stopped container")
+		}
+		report.Containers = append(report.Containers, inst)
+	}
+
+	images, err := p.runtime.Images(ctx)
+	if err != nil {
+		return nil, err
+	}
+	kept := map[string]int{}
+	for _, image := range images {
+		// images are listed newest first
+		if kept[image.RepoName] < opts.Keep {
+			kept[image.RepoName]++
+			continue
+		}
+		if protected[image.RepoName+":"+image.CommitSHA] {
+			continue
+		}
+		if !opts.DryRun {
+			if err := p.runtime.RemoveImage(ctx, image.RepoName, image.CommitSHA); err != nil {
+				log.Error().Err(err).Str("repo", image.RepoName).Str("commit", image.CommitSHA).Msg("failed to remove image")
+				continue
+			}
+			log.Info().Str("repo", image.RepoName).Str("commit", image.CommitSHA).Msg("removed image")
+		}
+		report.Images = append(report.Images, image)
+		report.FreedBytes += image.Size
+	}
+	return report, nil
+}
+
+// protectedImages returns the images, as <repo>:<sha>, that deployments may still start, along
+// with the IDs of the active, queued and running deployments, whose containers are kept.
+func (p *pruneImagesUsecaseImpl) protectedImages(ctx context.Context) (map[string]bool, map[entity.ID]bool, error) {
+	repos, err := p.repositoryRepository.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make(map[entity.ID]string, len(repos))
+	for _, repo := range repos {
+		names[repo.ID] = repo.FullName()
+	}
+	deployments, err := p.deploymentRepository.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	protected := map[string]bool{}
+	inUse := map[entity.ID]bool{}
+	for _, dep := range deployments {
+		name, ok := names[dep.RepoID]
+		if !ok {
+			continue
+		}
+		if dep.IsActive || dep.Status == entity.DeploymentStatusPending || dep.Status == entity.DeploymentStatusRunning {
+			protected[name+":"+dep.CommitSHA] = true
+			inUse[dep.ID] = true
+		}
+	}
+	return protected, inUse, nil
+}
+
+func NewPruneImagesUsecase(injector *do.Injector) (PruneImagesUsecase, error) {
+	return &pruneImagesUsecaseImpl{
+		runtime:              do.MustInvoke[deployer.Runtime](injector),
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+		deploymentRepository: do.MustInvoke[repository.DeploymentRepository](injector),
+	}, nil
+}
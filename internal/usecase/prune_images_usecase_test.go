@@ -0,0 +1,104 @@
+package usecase
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+func TestPruneImages(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+
+	var commits []string
+	for range 5 {
+		dep, err := d.push(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		commits = append(commits, dep.CommitSHA)
+	}
+	// a queued rollback still needs the image of the first commit
+	rollback, err := do.MustInvoke[repository.DeploymentRepository](d.injector).Create(ctx, &entity.Deployment{
+		RepoID:    d.repo.ID,
+		Branch:    "main",
+		CommitSHA: commits[0],
+		Status:    entity.DeploymentStatusPending,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a leftover container of the second commit that crashed
+	leftover, err := d.runtime.Start(ctx, &deployer.InstanceSpec{
+		Name:      "leftover",
+		RepoName:  d.name,
+		CommitSHA: commits[1],
+		Labels:    map[string]string{deployer.LabelEnabled: "true", deployer.LabelRepo: d.name, deployer.LabelCommit: commits[1]},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.runtime.Exit(leftover.ID, 1)
+	// the container of the rollback, created but not started yet
+	starting, err := d.runtime.Start(ctx, &deployer.InstanceSpec{
+		Name:      "starting",
+		RepoName:  d.name,
+		CommitSHA: commits[0],
+		Labels: map[string]string{deployer.LabelEnabled: "true", deployer.LabelRepo: d.name, deployer.LabelCommit: commits[0],
+			deployer.LabelDeployment: rollback.ID.String()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.runtime.Exit(starting.ID, 0)
+
+	prune := do.MustInvoke[PruneImagesUsecase](d.injector)
+	commitsOf := func(report *PruneImagesReport) []string {
+		var removed []string
+		for _, image := range report.Images {
+			removed = append(removed, image.CommitSHA)
+		}
+		slices.Sort(removed)
+		return removed
+	}
+	want := []string{commits[1], commits[2]}
+	slices.Sort(want)
+
+	report, err := prune.Execute(ctx, PruneImagesOptions{Keep: 2, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := commitsOf(report); !slices.Equal(got, want) || len(report.Containers) != 1 {
+		t.Fatalf("dry run removes images %v and %d containers, want %v and 1", got, len(report.Containers), want)
+	}
+	if images, _ := d.runtime.Images(ctx); len(images) != 5 {
+		t.Fatalf("dry run removed images, %d left", len(images))
+	}
+
+	report, err = prune.Execute(ctx, PruneImagesOptions{Keep: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := commitsOf(report); !slices.Equal(got, want) {
+		t.Fatalf("removed images %v, want %v", got, want)
+	}
+	if report.FreedBytes != 2<<20 {
+		t.Fatalf("freed %d bytes, want %d", report.FreedBytes, 2<<20)
+	}
+	images, _ := d.runtime.Images(ctx)
+	var kept []string
+	for _, image := range images {
+		kept = append(kept, image.CommitSHA)
+	}
+	if !slices.Equal(kept, []string{commits[4], commits[3], commits[0]}) {
+		t.Fatalf("kept images %v, want the two newest and the queued rollback", kept)
+	}
+	if got := d.instances(); len(got) != 2 || !slices.Contains(got, d.active()) || !slices.Contains(got, rollback.ID) {
+		t.Fatalf("instances after pruning = %v, want the active one and the one of the rollback", got)
+	}
+}
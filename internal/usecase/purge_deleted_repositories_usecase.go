@@ -0,0 +1,54 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/config"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type PurgeDeletedRepositoriesUsecase interface {
+	// Execute permanently removes the repositories deleted longer than the restore window ago,
+	// and returns them. A repository that fails to be purged is retried with the next call.
+	Execute(ctx context.Context) ([]*entity.Repository, error)
+}
+
+type purgeDeletedRepositoriesUsecaseImpl struct {
+	restoreWindow        time.Duration
+	repositoryRepository repository.RepositoryRepository
+	purger               *repositoryPurger
+}
+
+// Execute implements PurgeDeletedRepositoriesUsecase.
+func (p *purgeDeletedRepositoriesUsecaseImpl) Execute(ctx context.Context) ([]*entity.Repository, error) {
+	log := zerolog.Ctx(ctx)
+	repos, err := p.repositoryRepository.ListDeleted(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var purged []*entity.Repository
+	for _, repo := range repos {
+		if time.Since(*repo.DeletedAt) < p.restoreWindow {
+			// deleted repositories are listed oldest first
+			break
+		}
+		if err := p.purger.purge(ctx, repo); err != nil {
+			log.Error().Err(err).Str("repo", repo.FullName()).Msg("failed to purge repository")
+			continue
+		}
+		purged = append(purged, repo)
+	}
+	return purged, nil
+}
+
+func NewPurgeDeletedRepositoriesUsecase(injector *do.Injector) (PurgeDeletedRepositoriesUsecase, error) {
+	return &purgeDeletedRepositoriesUsecaseImpl{
+		restoreWindow:        do.MustInvoke[*config.Config](injector).RestoreWindow,
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+		purger:               newRepositoryPurger(injector),
+	}, nil
+}
@@ -0,0 +1,157 @@
+package usecase
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/proxy"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+// repositoryNamePattern matches the names the git routes serve.
+var repositoryNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+type RenameRepositoryUsecase interface {
+	// Execute renames the repository. newName is a name, which keeps the owner, or a full name,
+	// <owner>/<name>, which transfers the repository to another user or organization. The bare
+	// repository and the images move to the new full name, the old one redirects to the
+	// repository, and the app and previews that are running are relaunched under the new name.
+	// It returns entity.ErrConflict if the name is taken or a deployment of the repository is
+	// running.
+	Execute(ctx context.Context, reponame, newName string) (*entity.Repository, error)
+}
+
+type renameRepositoryUsecaseImpl struct {
+	gitStorage                storage.GitStorage
+	runtime                   deployer.Runtime
+	router                    proxy.Router
+	repositoryRepository      repository.RepositoryRepository
+	deploymentRepository      repository.DeploymentRepository
+	redirectRepository        repository.RepositoryRedirectRepository
+	userRepository            repository.UserRepository
+	organizationRepository    repository.OrganizationRepository
+	rollbackDeploymentUsecase RollbackDeploymentUsecase
+}
+
+// Execute implements RenameRepositoryUsecase.
+func (r *renameRepositoryUsecaseImpl) Execute(ctx context.Context, reponame, newName string) (*entity.Repository, error) {
+	log := zerolog.Ctx(ctx)
+	repo, err := r.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	newOwner, newName := entity.SplitFullName(newName)
+	if !repositoryNamePattern.MatchString(newName) {
+		return nil, entity.ErrInvalid
+	}
+	if newOwner == "" {
+		newOwner = repo.Owner
+	}
+	if newOwner != repo.Owner {
+		exists, err := ownerExists(ctx, r.userRepository, r.organizationRepository, newOwner)
+		if err != nil {
+			return nil, entity.ErrInternal
+		}
+		if !exists {
+			return nil, entity.ErrInvalid
+		}
+	}
+	newFullName := entity.JoinFullName(newOwner, newName)
+	if newFullName == repo.FullName() {
+		return repo, nil
+	}
+	if _, err := r.repositoryRepository.GetByName(ctx, newFullName); err != entity.ErrNotFound {
+		if err != nil {
+			return nil, entity.ErrInternal
+		}
+		return nil, entity.ErrConflict
+	}
+	// the name of a deleted repository is taken until it is purged
+	if _, err := r.repositoryRepository.GetDeletedByName(ctx, newFullName); err != entity.ErrNotFound {
+		if err != nil {
+			return nil, entity.ErrInternal
+		}
+		return nil, entity.ErrConflict
+	}
+	deps, err := r.deploymentRepository.ListByRepo(ctx, repo.ID)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	for _, dep := range deps {
+		// a running deployment builds and labels its container with the old name
+		if dep.Status == entity.DeploymentStatusRunning {
+			return nil, entity.ErrConflict
+		}
+	}
+
+	if err := r.gitStorage.MoveRepo(ctx, repo.FullName(), newFullName); err != nil {
+		log.Error().Err(err).Str("repo", repo.FullName()).Str("new_name", newFullName).Msg("failed to move repository")
+		return nil, entity.ErrConflict
+	}
+	old := *repo
+	repo.Owner, repo.Name = newOwner, newName
+	repo, err = r.repositoryRepository.Update(ctx, repo)
+	if err != nil {
+		if err := r.gitStorage.MoveRepo(ctx, newFullName, old.FullName()); err != nil {
+			log.Error().Err(err).Str("repo", old.FullName()).Msg("failed to move repository back")
+		}
+		return nil, entity.ErrInternal
+	}
+	log.Info().Str("repo", repo.FullName()).Str("old_name", old.FullName()).Msg("renamed repository")
+
+	// from here on the repository is renamed, failures leave it working under the new name
+	if err := r.redirectRepository.Delete(ctx, newFullName); err != nil {
+		log.Error().Err(err).Str("name", newFullName).Msg("failed to remove redirect of the new name")
+	}
+	if err := r.redirectRepository.Set(ctx, old.FullName(), repo.ID); err != nil {
+		log.Error().Err(err).Str("name", old.FullName()).Msg("failed to redirect the old name")
+	}
+	if err := r.runtime.RenameImages(ctx, old.FullName(), repo.FullName()); err != nil {
+		log.Error().Err(err).Str("repo", repo.FullName()).Msg("failed to rename images")
+	}
+	r.router.RemoveRepository(&old)
+	if err := r.router.Refresh(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to refresh proxy routes")
+	}
+	for _, dep := range deps {
+		if !dep.IsActive {
+			continue
+		}
+		if _, err := r.rollbackDeploymentUsecase.Execute(ctx, dep.ID); err != nil {
+			log.Error().Err(err).Str("deployment", dep.ID.String()).Msg("failed to relaunch deployment under the new name")
+		}
+	}
+	return repo, nil
+}
+
+// formerNames returns the names the repository had before it was renamed, oldest first.
+func formerNames(ctx context.Context, redirectRepository repository.RepositoryRedirectRepository, repoID entity.ID) ([]string, error) {
+	redirects, err := redirectRepository.ListByRepo(ctx, repoID)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(redirects))
+	for i, redirect := range redirects {
+		names[i] = redirect.Name
+	}
+	return names, nil
+}
+
+func NewRenameRepositoryUsecase(injector *do.Injector) (RenameRepositoryUsecase, error) {
+	return &renameRepositoryUsecaseImpl{
+		gitStorage:                do.MustInvoke[storage.GitStorage](injector),
+		runtime:                   do.MustInvoke[deployer.Runtime](injector),
+		router:                    do.MustInvoke[proxy.Router](injector),
+		repositoryRepository:      do.MustInvoke[repository.RepositoryRepository](injector),
+		deploymentRepository:      do.MustInvoke[repository.DeploymentRepository](injector),
+		redirectRepository:        do.MustInvoke[repository.RepositoryRedirectRepository](injector),
+		userRepository:            do.MustInvoke[repository.UserRepository](injector),
+		organizationRepository:    do.MustInvoke[repository.OrganizationRepository](injector),
+		rollbackDeploymentUsecase: do.MustInvoke[RollbackDeploymentUsecase](injector),
+	}, nil
+}
@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+func TestRenameRepository(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	gitStorage := do.MustInvoke[storage.GitStorage](d.injector)
+	deployments := do.MustInvoke[repository.DeploymentRepository](d.injector)
+	rename := do.MustInvoke[RenameRepositoryUsecase](d.injector)
+
+	app, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.pushBranch("feature", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rename.Execute(ctx, "alice/app", "bad name"); err != entity.ErrInvalid {
+		t.Fatalf("rename to an invalid name error = %v, want %v", err, entity.ErrInvalid)
+	}
+	if _, err := rename.Execute(ctx, "alice/app", "nobody/web"); err != entity.ErrInvalid {
+		t.Fatalf("transfer to a missing owner error = %v, want %v", err, entity.ErrInvalid)
+	}
+	repo, err := rename.Execute(ctx, "alice/app", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.FullName() != "alice/web" || repo.ID != d.repo.ID {
+		t.Fatalf("renamed repository = %+v, want %s named alice/web", repo, d.repo.ID)
+	}
+	d.name = repo.FullName()
+	if gitStorage.IsRepoExist("alice/app") || !gitStorage.IsRepoExist("alice/web") {
+		t.Fatal("bare repository was not moved to the new name")
+	}
+	if ok, _ := d.runtime.HasImage(ctx, "alice/web", app.CommitSHA); !ok {
+		t.Fatal("image was not renamed")
+	}
+	redirected, err := do.MustInvoke[GetRepositoryByRedirectUsecase](d.injector).Execute(ctx, "alice/app")
+	if err != nil || redirected.ID != repo.ID {
+		t.Fatalf("redirect of the old name = %+v, %v, want %s", redirected, err, repo.ID)
+	}
+
+	// the app and the preview are relaunched under the new name and replace the old containers
+	pending, _ := deployments.ListByStatus(ctx, entity.DeploymentStatusPending)
+	if len(pending) != 2 {
+		t.Fatalf("queued deployments after rename = %d, want 2", len(pending))
+	}
+	for _, dep := range pending {
+		if _, err := do.MustInvoke[RunDeploymentUsecase](d.injector).Execute(ctx, dep.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if old, _ := d.runtime.List(ctx, map[string]string{deployer.LabelRepo: "alice/app"}); len(old) != 0 {
+		t.Fatalf("instances under the old name = %d, want none", len(old))
+	}
+	instances, _ := d.runtime.List(ctx, map[string]string{deployer.LabelRepo: "alice/web"})
+	if len(instances) != 2 {
+		t.Fatalf("instances under the new name = %d, want the app and the preview", len(instances))
+	}
+	for _, inst := range instances {
+		if inst.Labels[deployer.LabelPreview] != "" && inst.Labels[deployer.LabelPreview] != "web-feature" {
+			t.Fatalf("preview instance is labeled %q, want web-feature", inst.Labels[deployer.LabelPreview])
+		}
+	}
+
+	if _, err := rename.Execute(ctx, "alice/web", "web"); err != nil {
+		t.Fatalf("rename to the same name: %v", err)
+	}
+	if _, err := do.MustInvoke[repository.RepositoryRepository](d.injector).Create(ctx, &entity.Repository{Owner: "alice", Name: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rename.Execute(ctx, "alice/web", "other"); err != entity.ErrConflict {
+		t.Fatalf("rename to a taken name error = %v, want %v", err, entity.ErrConflict)
+	}
+
+	// a full name transfers the repository to another owner
+	if _, err := do.MustInvoke[repository.OrganizationRepository](d.injector).Create(ctx, &entity.Organization{Name: "acme"}); err != nil {
+		t.Fatal(err)
+	}
+	repo, err = rename.Execute(ctx, "alice/web", "acme/web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Owner != "acme" || !gitStorage.IsRepoExist("acme/web") {
+		t.Fatalf("transferred repository = %+v, want it owned and stored by acme", repo)
+	}
+}
@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/config"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type RestoreRepositoryUsecase interface {
+	// Execute undoes the deletion of the repository within the restore window, and queues
+	// rollbacks that relaunch the app and the previews that were running when it was deleted.
+	// It returns entity.ErrNotFound if there is nothing to restore anymore and
+	// entity.ErrConflict if another repository took the name in the meantime.
+	Execute(ctx context.Context, reponame string) (*entity.Repository, error)
+}
+
+type restoreRepositoryUsecaseImpl struct {
+	restoreWindow             time.Duration
+	repositoryRepository      repository.RepositoryRepository
+	deploymentRepository      repository.DeploymentRepository
+	rollbackDeploymentUsecase RollbackDeploymentUsecase
+}
+
+// Execute implements RestoreRepositoryUsecase.
+func (r *restoreRepositoryUsecaseImpl) Execute(ctx context.Context, reponame string) (*entity.Repository, error) {
+	log := zerolog.Ctx(ctx)
+	repo, err := r.repositoryRepository.GetDeletedByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	// the repository is about to be purged, if it has not been already
+	if time.Since(*repo.DeletedAt) >= r.restoreWindow {
+		return nil, entity.ErrNotFound
+	}
+	if _, err := r.repositoryRepository.GetByName(ctx, reponame); err != entity.ErrNotFound {
+		if err != nil {
+			return nil, entity.ErrInternal
+		}
+		return nil, entity.ErrConflict
+	}
+
+	if err := r.repositoryRepository.Restore(ctx, repo.ID); err != nil {
+		return nil, entity.ErrInternal
+	}
+	repo, err = r.repositoryRepository.GetByID(ctx, repo.ID)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	log.Info().Str("repo", repo.FullName()).Msg("restored repository")
+
+	deps, err := r.deploymentRepository.ListByRepo(ctx, repo.ID)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	for _, dep := range deps {
+		if !dep.IsActive {
+			continue
+		}
+		if _, err := r.rollbackDeploymentUsecase.Execute(ctx, dep.ID); err != nil {
+			log.Error().Err(err).Str("deployment", dep.ID.String()).Msg("failed to relaunch deployment of restored repository")
+		}
+	}
+	return repo, nil
+}
+
+func NewRestoreRepositoryUsecase(injector *do.Injector) (RestoreRepositoryUsecase, error) {
+	return &restoreRepositoryUsecaseImpl{
+		restoreWindow:             do.MustInvoke[*config.Config](injector).RestoreWindow,
+		repositoryRepository:      do.MustInvoke[repository.RepositoryRepository](injector),
+		deploymentRepository:      do.MustInvoke[repository.DeploymentRepository](injector),
+		rollbackDeploymentUsecase: do.MustInvoke[RollbackDeploymentUsecase](injector),
+	}, nil
+}
@@ -0,0 +1,43 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type RevokeAccessTokenUsecase interface {
+	Execute(ctx context.Context, username string, tokenID entity.ID) error
+}
+
+type revokeAccessTokenUsecaseImpl struct {
+	userRepository        repository.UserRepository
+	accessTokenRepository repository.AccessTokenRepository
+}
+
+// Execute implements RevokeAccessTokenUsecase.
+func (r *revokeAccessTokenUsecaseImpl) Execute(ctx context.Context, username string, tokenID entity.ID) error {
+	user, err := r.userRepository.GetByName(ctx, username)
+	if err != nil {
+		return err
+	}
+	tokens, err := r.accessTokenRepository.ListByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if t.ID == tokenID {
+			return r.accessTokenRepository.Delete(ctx, t.ID)
+		}
+	}
+	return entity.ErrNotFound
+}
+
+func NewRevokeAccessTokenUsecase(injector *do.Injector) (RevokeAccessTokenUsecase, error) {
+	return &revokeAccessTokenUsecaseImpl{
+		userRepository:        do.MustInvoke[repository.UserRepository](injector),
+		accessTokenRepository: do.MustInvoke[repository.AccessTokenRepository](injector),
+	}, nil
+}
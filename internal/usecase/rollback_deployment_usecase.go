@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/queue"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type RollbackDeploymentUsecase interface {
+	// Execute queues a deployment that relaunches the image of a previous successful deployment
+	// without rebuilding it. The rollback of a preview relaunches it as the preview of its branch.
+	// It returns entity.ErrInvalid if the deployment did not succeed.
+	Execute(ctx context.Context, id entity.ID) (*entity.Deployment, error)
+}
+
+type rollbackDeploymentUsecaseImpl struct {
+	deploymentRepository repository.DeploymentRepository
+	deploymentQueue      queue.DeploymentQueue
+}
+
+// Execute implements RollbackDeploymentUsecase.
+func (r *rollbackDeploymentUsecaseImpl) Execute(ctx context.Context, id entity.ID) (*entity.Deployment, error) {
+	target, err := r.deploymentRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// only successful deployments are known to have left a tagged image behind
+	if target.Status != entity.DeploymentStatusSuccess || target.IsTeardown() {
+		return nil, entity.ErrInvalid
+	}
+
+	dep, err := r.deploymentRepository.Create(ctx, &entity.Deployment{
+		RepoID:     target.RepoID,
+		Branch:     target.Branch,
+		CommitSHA:  target.CommitSHA,
+		Status:     entity.DeploymentStatusPending,
+		RollbackOf: target.ID,
+		Preview:    target.Preview,
+	})
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+
+	r.deploymentQueue.Notify()
+
+	return dep, nil
+}
+
+func NewRollbackDeploymentUsecase(injector *do.Injector) (RollbackDeploymentUsecase, error) {
+	return &rollbackDeploymentUsecaseImpl{
+		deploymentRepository: do.MustInvoke[repository.DeploymentRepository](injector),
+		deploymentQueue:      do.MustInvoke[queue.DeploymentQueue](injector),
+	}, nil
+}
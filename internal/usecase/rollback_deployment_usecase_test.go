@@ -0,0 +1,181 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/proxy"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+// recordingRouter is a proxy.Router that remembers the backends the app was switched to.
+type recordingRouter struct {
+	proxy.Router
+	// onSwitch, if set, is called before the app is switched to addr.
+	onSwitch func(addr string)
+	mu       sync.Mutex
+	backends []string
+}
+
+func (r *recordingRouter) SetBackend(repo *entity.Repository, addr string) {
+	if r.onSwitch != nil {
+		r.onSwitch(addr)
+	}
+	r.mu.Lock()
+	r.backends = append(r.backends, addr)
+	r.mu.Unlock()
+	r.Router.SetBackend(repo, addr)
+}
+
+func (r *recordingRouter) last() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.backends) == 0 {
+		return ""
+	}
+	return r.backends[len(r.backends)-1]
+}
+
+// recordRoutes makes the deployments of d switch the traffic through a recordingRouter. It has
+// to be called before the first deployment runs.
+func (d *deployTest) recordRoutes() *recordingRouter {
+	d.t.Helper()
+	router := &recordingRouter{Router: do.MustInvoke[proxy.Router](d.injector)}
+	do.OverrideValue[proxy.Router](d.injector, router)
+	return router
+}
+
+// addrOf returns the address the instance of the deployment listens on.
+func (d *deployTest) addrOf(id entity.ID) string {
+	d.t.Helper()
+	ctx := context.Background()
+	instances, err := d.runtime.List(ctx, map[string]string{deployer.LabelDeployment: id.String()})
+	if err != nil || len(instances) != 1 {
+		d.t.Fatalf("instances of deployment %s = %v, %v, want one", id, instances, err)
+	}
+	status, err := d.runtime.Status(ctx, instances[0].ID, 0)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	return status.Addr
+}
+
+func TestRollbackDeployment(t *testing.T) {
+	d := newDeployTest(t)
+	router := d.recordRoutes()
+	ctx := context.Background()
+	deployments := do.MustInvoke[repository.DeploymentRepository](d.injector)
+	rollback := do.MustInvoke[RollbackDeploymentUsecase](d.injector)
+	run := do.MustInvoke[RunDeploymentUsecase](d.injector)
+
+	first, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := router.last(); got != d.addrOf(second.ID) {
+		t.Fatalf("backend after second push = %q, want the instance of %s", got, second.ID)
+	}
+
+	dep, err := rollback.Execute(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dep.Status != entity.DeploymentStatusPending || dep.RollbackOf != first.ID || dep.CommitSHA != first.CommitSHA || dep.Preview {
+		t.Fatalf("rollback = %+v, want a pending deployment of %s", dep, first.CommitSHA)
+	}
+	// the image of the first commit is relaunched, not rebuilt
+	d.runtime.BuildFunc = func(req *deployer.BuildRequest) error {
+		return errors.New("rollbacks must not build")
+	}
+	dep, err = run.Execute(ctx, dep.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dep.Status != entity.DeploymentStatusSuccess || !dep.IsActive {
+		t.Fatalf("rollback after running = %+v, want the active deployment", dep)
+	}
+	if got := d.active(); got != dep.ID {
+		t.Fatalf("active deployment = %s, want the rollback %s", got, dep.ID)
+	}
+	if previous, err := deployments.GetByID(ctx, second.ID); err != nil || previous.IsActive {
+		t.Fatalf("second deployment = %+v, %v, want it no longer active", previous, err)
+	}
+	if got := d.instances(); len(got) != 1 || got[0] != dep.ID {
+		t.Fatalf("instances after rollback = %v, want [%s]", got, dep.ID)
+	}
+	if got := router.last(); got != d.addrOf(dep.ID) {
+		t.Fatalf("backend after rollback = %q, want the instance of %s", got, dep.ID)
+	}
+
+	// deployments that did not succeed may have left no image behind
+	failed, err := deployments.Create(ctx, &entity.Deployment{RepoID: d.repo.ID, Branch: "main", CommitSHA: first.CommitSHA, Status: entity.DeploymentStatusFailed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := deployments.Create(ctx, &entity.Deployment{RepoID: d.repo.ID, Branch: "main", CommitSHA: first.CommitSHA, Status: entity.DeploymentStatusPending})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []entity.ID{failed.ID, pending.ID} {
+		if _, err := rollback.Execute(ctx, id); err != entity.ErrInvalid {
+			t.Fatalf("rollback of %s error = %v, want %v", id, err, entity.ErrInvalid)
+		}
+	}
+	if _, err := rollback.Execute(ctx, entity.NewID("999")); err != entity.ErrNotFound {
+		t.Fatalf("rollback of an unknown deployment error = %v, want %v", err, entity.ErrNotFound)
+	}
+}
+
+func TestRollbackPreviewDeployment(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	rollback := do.MustInvoke[RollbackDeploymentUsecase](d.injector)
+
+	app, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	preview, err := d.pushBranch("feature", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.pushBranch("feature", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the rollback of a preview relaunches the preview and leaves the app alone
+	dep, err := rollback.Execute(ctx, preview.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dep.Preview || dep.Branch != "feature" {
+		t.Fatalf("rollback of preview = %+v, want a preview of feature", dep)
+	}
+	if dep, err = do.MustInvoke[RunDeploymentUsecase](d.injector).Execute(ctx, dep.ID); err != nil || !dep.IsActive {
+		t.Fatalf("rollback of preview after running = %+v, %v, want it active", dep, err)
+	}
+	if got := d.active(); got != app.ID {
+		t.Fatalf("active deployment = %s, want the app %s", got, app.ID)
+	}
+
+	// the removal of a preview has nothing to relaunch
+	teardown, err := do.MustInvoke[TeardownPreviewUsecase](d.injector).Execute(ctx, d.name, "feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := do.MustInvoke[RunDeploymentUsecase](d.injector).Execute(ctx, teardown.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rollback.Execute(ctx, teardown.ID); err != entity.ErrInvalid {
+		t.Fatalf("rollback of a preview removal error = %v, want %v", err, entity.ErrInvalid)
+	}
+}
@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/git"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+type RollbackRepositoryUsecase interface {
+	// Execute rolls the repository back to the latest successful deployment of the commit.
+	// An empty commitSHA picks the latest successful deployment of a commit other than the active one.
+	// It returns entity.ErrNotFound if there is no such deployment.
+	Execute(ctx context.Context, reponame, commitSHA string) (*entity.Deployment, error)
+}
+
+type rollbackRepositoryUsecaseImpl struct {
+	gitStorage                storage.GitStorage
+	repositoryRepository      repository.RepositoryRepository
+	deploymentRepository      repository.DeploymentRepository
+	rollbackDeploymentUsecase RollbackDeploymentUsecase
+}
+
+// Execute implements RollbackRepositoryUsecase.
+func (r *rollbackRepositoryUsecaseImpl) Execute(ctx context.Context, reponame, commitSHA string) (*entity.Deployment, error) {
+	repo, err := r.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+
+	var activeSHA string
+	if commitSHA != "" {
+		// accept abbreviated SHAs and anything else git can resolve
+		commitSHA, err = git.ResolveCommit(ctx, r.gitStorage.GetRepoDir(repo.FullName()), commitSHA)
+		if err != nil {
+			return nil, entity.ErrInvalid
+		}
+	} else {
+		active, err := r.deploymentRepository.GetActiveByRepo(ctx, repo.ID)
+		if err != nil {
+			return nil, err
+		}
+		activeSHA = active.CommitSHA
+	}
+
+	deps, err := r.deploymentRepository.ListByRepo(ctx, repo.ID)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	for _, dep := range deps {
+		// previews of other branches are never rolled out as the app
+		if dep.Status != entity.DeploymentStatusSuccess || dep.Preview {
+			continue
+		}
+		if commitSHA != "" && dep.CommitSHA != commitSHA {
+			continue
+		}
+		if commitSHA == "" && dep.CommitSHA == activeSHA {
+			continue
+		}
+		return r.rollbackDeploymentUsecase.Execute(ctx, dep.ID)
+	}
+	return nil, entity.ErrNotFound
+}
+
+func NewRollbackRepositoryUsecase(injector *do.Injector) (RollbackRepositoryUsecase, error) {
+	return &rollbackRepositoryUsecaseImpl{
+		gitStorage:                do.MustInvoke[storage.GitStorage](injector),
+		repositoryRepository:      do.MustInvoke[repository.RepositoryRepository](injector),
+		deploymentRepository:      do.MustInvoke[repository.DeploymentRepository](injector),
+		rollbackDeploymentUsecase: do.MustInvoke[RollbackDeploymentUsecase](injector),
+	}, nil
+}
@@ -0,0 +1,68 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+)
+
+func TestRollbackRepository(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	rollback := do.MustInvoke[RollbackRepositoryUsecase](d.injector)
+
+	if _, err := rollback.Execute(ctx, d.name, ""); err != entity.ErrNotFound {
+		t.Fatalf("rollback without deployments error = %v, want %v", err, entity.ErrNotFound)
+	}
+	first, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	preview, err := d.pushBranch("feature", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.runtime.BuildFunc = func(req *deployer.BuildRequest) error {
+		return errors.New("build failed")
+	}
+	failed, err := d.push(nil)
+	if err == nil {
+		t.Fatalf("deployment = %+v, want failed", failed)
+	}
+
+	// without a commit, the newest successful deployment of another commit than the active one
+	dep, err := rollback.Execute(ctx, d.name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dep.RollbackOf != first.ID || dep.Preview {
+		t.Fatalf("rollback = %+v, want a rollback of %s", dep, first.ID)
+	}
+	dep, err = rollback.Execute(ctx, d.name, second.CommitSHA[:7])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dep.RollbackOf != second.ID {
+		t.Fatalf("rollback to %s = %+v, want a rollback of %s", second.CommitSHA[:7], dep, second.ID)
+	}
+	// previews are never rolled out as the app, and failed deployments have no image
+	for _, sha := range []string{preview.CommitSHA, failed.CommitSHA} {
+		if _, err := rollback.Execute(ctx, d.name, sha); err != entity.ErrNotFound {
+			t.Fatalf("rollback to %s error = %v, want %v", sha, err, entity.ErrNotFound)
+		}
+	}
+	if _, err := rollback.Execute(ctx, d.name, "unknown"); err != entity.ErrInvalid {
+		t.Fatalf("rollback to an unknown commit error = %v, want %v", err, entity.ErrInvalid)
+	}
+	if _, err := rollback.Execute(ctx, "alice/missing", ""); err != entity.ErrNotFound {
+		t.Fatalf("rollback of a missing repository error = %v, want %v", err, entity.ErrNotFound)
+	}
+}
@@ -0,0 +1,223 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/config"
+	"github.com/yz4230/githost-poc/internal/deployconfig"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/proxy"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+type RunDeploymentUsecase interface {
+	// Execute builds and starts the deployment, moving it through running to success or failed.
+	Execute(ctx context.Context, id entity.ID) (*entity.Deployment, error)
+}
+
+type runDeploymentUsecaseImpl struct {
+	containerDefaults             entity.ContainerLimits
+	gitStorage                    storage.GitStorage
+	deploymentLogStorage          storage.DeploymentLogStorage
+	repositoryRepository          repository.RepositoryRepository
+	deploymentRepository          repository.DeploymentRepository
+	envVarRepository              repository.EnvVarRepository
+	redirectRepository            repository.RepositoryRedirectRepository
+	updateDeploymentStatusUsecase UpdateDeploymentStatusUsecase
+	deployer                      deployer.Deployer
+	router                        proxy.Router
+}
+
+// Execute implements RunDeploymentUsecase.
+func (r *runDeploymentUsecaseImpl) Execute(ctx context.Context, id entity.ID) (*entity.Deployment, error) {
+	log := zerolog.Ctx(ctx)
+	dep, err := r.deploymentRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := r.repositoryRepository.GetByID(ctx, dep.RepoID)
+	if err != nil {
+		// the repository was deleted after the deployment was queued, it must not stay in the queue
+		if err == entity.ErrNotFound {
+			if _, err := r.updateDeploymentStatusUsecase.Execute(ctx, dep.ID, entity.DeploymentStatusFailed); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	output, err := r.deploymentLogStorage.Create(dep.ID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.updateDeploymentStatusUsecase.Execute(ctx, dep.ID, entity.DeploymentStatusRunning); err != nil {
+		output.Close()
+		return nil, err
+	}
+	switch {
+	case dep.IsTeardown():
+		fmt.Fprintf(output, "Removing preview %s of deleted branch %s\n", repo.PreviewName(dep.Branch), dep.Branch)
+	case dep.RollbackOf != "":
+		fmt.Fprintf(output, "Rolling back %s to %s (%s) of deployment %s\n", repo.FullName(), dep.CommitSHA[:7], dep.Branch, dep.RollbackOf)
+	case dep.Preview:
+		fmt.Fprintf(output, "Deploying %s (%s) of %s as preview %s\n", dep.CommitSHA[:7], dep.Branch, repo.FullName(), repo.PreviewName(dep.Branch))
+		if dep.Clean {
+			fmt.Fprintln(output, "Building without the build cache")
+		}
+	default:
+		fmt.Fprintf(output, "Deploying %s (%s) of %s\n", dep.CommitSHA[:7], dep.Branch, repo.FullName())
+		if dep.Clean {
+			fmt.Fprintln(output, "Building without the build cache")
+		}
+	}
+	log.Info().Str("deployment", dep.ID.String()).Str("repo", repo.FullName()).Str("commit", dep.CommitSHA).Msg("running deployment")
+
+	status := entity.DeploymentStatusSuccess
+	var deployErr error
+	if dep.IsTeardown() {
+		deployErr = r.teardown(ctx, dep, repo, output)
+	} else {
+		deployErr = r.deploy(ctx, dep, repo, output)
+	}
+	if deployErr != nil {
+		status = entity.DeploymentStatusFailed
+		if ctx.Err() != nil {
+			// interrupted by shutdown, run it again once the server is back
+			status = entity.DeploymentStatusPending
+		}
+	}
+	switch status {
+	case entity.DeploymentStatusSuccess:
+		fmt.Fprintf(output, "Deployment %s succeeded\n", dep.ID)
+	case entity.DeploymentStatusFailed:
+		fmt.Fprintf(output, "ERROR: deployment %s failed: %v\n", dep.ID, deployErr)
+	case entity.DeploymentStatusPending:
+		fmt.Fprintf(output, "Deployment %s interrupted, it will be retried\n", dep.ID)
+	}
+	// the log is complete before the final status becomes visible to followers
+	output.Close()
+	dep, err = r.updateDeploymentStatusUsecase.Execute(context.WithoutCancel(ctx), dep.ID, status)
+	if err != nil {
+		return nil, err
+	}
+	if deployErr != nil {
+		return dep, deployErr
+	}
+	return dep, nil
+}
+
+func (r *runDeploymentUsecaseImpl) deploy(ctx context.Context, dep *entity.Deployment, repo *entity.Repository, output io.Writer) error {
+	repoDir := r.gitStorage.GetRepoDir(repo.FullName())
+	cfg, err := deployconfig.Load(ctx, repoDir, dep.CommitSHA)
+	if err != nil {
+		var verr *deployconfig.ValidationError
+		if errors.As(err, &verr) {
+			for _, problem := range verr.Problems {
+				fmt.Fprintf(output, "ERROR: %s: %s\n", deployconfig.FileName, problem)
+			}
+			return fmt.Errorf("invalid %s", deployconfig.FileName)
+		}
+		return fmt.Errorf("failed to read %s: %w", deployconfig.FileName, err)
+	}
+
+	limits := cfg.Limits(r.containerDefaults)
+	if err := r.deploymentRepository.SetLimits(ctx, dep.ID, &limits); err != nil {
+		return fmt.Errorf("failed to record container limits: %w", err)
+	}
+
+	envs, err := r.envVarRepository.ListByRepo(ctx, repo.ID)
+	if err != nil {
+		return fmt.Errorf("failed to read environment variables: %w", err)
+	}
+	// variables set through the API take precedence over the ones in the config file
+	merged := maps.Clone(cfg.Run.Env)
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	// secrets are available to the build too, unlike the variables in the config file
+	secrets := map[string][]byte{}
+	for _, e := range envs {
+		merged[e.Name] = e.Value
+		if e.Secret {
+			secrets[e.Name] = []byte(e.Value)
+		}
+	}
+	env := make([]string, 0, len(merged))
+	for _, name := range slices.Sorted(maps.Keys(merged)) {
+		env = append(env, name+"="+merged[name])
+	}
+
+	formerNames, err := formerNames(ctx, r.redirectRepository, repo.ID)
+	if err != nil {
+		return err
+	}
+	previewName := ""
+	if dep.Preview {
+		previewName = repo.PreviewName(dep.Branch)
+	}
+	return r.deployer.Deploy(ctx, &deployer.Request{
+		DeploymentID: dep.ID,
+		RepoDir:      repoDir,
+		RepoName:     repo.FullName(),
+		FormerNames:  formerNames,
+		Branch:       dep.Branch,
+		CommitSHA:    dep.CommitSHA,
+		PreviewName:  previewName,
+		Config:       cfg,
+		Limits:       limits,
+		Env:          env,
+		Secrets:      secrets,
+		Clean:        dep.Clean,
+		Prebuilt:     dep.RollbackOf != "",
+		Output:       output,
+		OnReady: func(addr string) {
+			// switch the traffic before the previous container is retired, the deployment is
+			// active from here on even if retiring fails
+			if err := r.deploymentRepository.SetActive(ctx, dep); err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Str("deployment", dep.ID.String()).Msg("failed to activate deployment")
+			}
+			if dep.Preview {
+				r.router.SetPreviewBackend(repo, dep.Branch, addr)
+			} else {
+				r.router.SetBackend(repo, addr)
+			}
+		},
+	})
+}
+
+func (r *runDeploymentUsecaseImpl) teardown(ctx context.Context, dep *entity.Deployment, repo *entity.Repository, output io.Writer) error {
+	// stop routing first, the containers are about to go away
+	if err := r.deploymentRepository.DeactivatePreview(ctx, repo.ID, dep.Branch); err != nil {
+		return fmt.Errorf("failed to deactivate preview: %w", err)
+	}
+	r.router.RemovePreview(repo, dep.Branch)
+	formerNames, err := formerNames(ctx, r.redirectRepository, repo.ID)
+	if err != nil {
+		return err
+	}
+	return r.deployer.RemovePreview(ctx, append([]string{repo.FullName()}, formerNames...), dep.Branch, output)
+}
+
+func NewRunDeploymentUsecase(injector *do.Injector) (RunDeploymentUsecase, error) {
+	return &runDeploymentUsecaseImpl{
+		containerDefaults:             do.MustInvoke[*config.Config](injector).ContainerDefaults,
+		gitStorage:                    do.MustInvoke[storage.GitStorage](injector),
+		deploymentLogStorage:          do.MustInvoke[storage.DeploymentLogStorage](injector),
+		repositoryRepository:          do.MustInvoke[repository.RepositoryRepository](injector),
+		deploymentRepository:          do.MustInvoke[repository.DeploymentRepository](injector),
+		envVarRepository:              do.MustInvoke[repository.EnvVarRepository](injector),
+		redirectRepository:            do.MustInvoke[repository.RepositoryRedirectRepository](injector),
+		updateDeploymentStatusUsecase: do.MustInvoke[UpdateDeploymentStatusUsecase](injector),
+		deployer:                      do.MustInvoke[deployer.Deployer](injector),
+		router:                        do.MustInvoke[proxy.Router](injector),
+	}, nil
+}
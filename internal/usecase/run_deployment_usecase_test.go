@@ -0,0 +1,497 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/config"
+	"github.com/yz4230/githost-poc/internal/deployer"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/proxy"
+	"github.com/yz4230/githost-poc/internal/queue"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/secret"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// deployTest runs deployments of pushes to the repository "alice/app" against a fake runtime.
+type deployTest struct {
+	t        *testing.T
+	injector *do.Injector
+	runtime  *deployer.FakeRuntime
+	repo     *entity.Repository
+	// name is the full name the repository is pushed to, tests that rename it update it.
+	name string
+	work string
+}
+
+func newDeployTest(t *testing.T) *deployTest {
+	t.Helper()
+	return newDeployTestOwnedBy(t, "alice")
+}
+
+// newDeployTestOwnedBy is newDeployTest with the repository "app" owned by owner, or by no one
+// if owner is empty. The user alice exists either way.
+func newDeployTestOwnedBy(t *testing.T, owner string) *deployTest {
+	t.Helper()
+	root := t.TempDir()
+	db, err := repository.NewSQLiteDB(filepath.Join(root, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime := deployer.NewFakeRuntime()
+	injector := do.New()
+	do.ProvideValue(injector, &config.Config{Root: root, AppsDomain: "apps.local", MaxPreviews: 1, RestoreWindow: time.Hour})
+	do.ProvideValue(injector, db)
+	do.ProvideValue(injector, storage.NewGitStorage(filepath.Join(root, "repositories"), zerolog.Nop()))
+	do.ProvideValue(injector, storage.NewDeploymentLogStorage(filepath.Join(root, "logs")))
+	do.Provide(injector, func(i *do.Injector) (secret.Cipher, error) { return secret.NewCipher(nil) })
+	do.ProvideValue[deployer.Runtime](injector, runtime)
+	do.Provide(injector, deployer.NewDeployer)
+	do.Provide(injector, proxy.NewRouter)
+	do.Provide(injector, repository.NewRepositoryRepository)
+	do.Provide(injector, repository.NewDeploymentRepository)
+	do.Provide(injector, repository.NewEnvVarRepository)
+	do.Provide(injector, repository.NewRepositoryPermissionRepository)
+	do.Provide(injector, repository.NewRepositoryRedirectRepository)
+	do.Provide(injector, repository.NewUserRepository)
+	do.Provide(injector, repository.NewOrganizationRepository)
+	do.Provide(injector, repository.NewOrganizationMemberRepository)
+	do.Provide(injector, func(i *do.Injector) (queue.DeploymentQueue, error) {
+		// never run, deployments are run by the tests
+		return queue.NewDeploymentQueue(1, do.MustInvoke[repository.DeploymentRepository](i), do.MustInvoke[RunDeploymentUsecase](i)), nil
+	})
+	do.Provide(injector, NewCreateDeploymentUsecase)
+	do.Provide(injector, NewTriggerDeploymentUsecase)
+	do.Provide(injector, NewListDeploymentsUsecase)
+	do.Provide(injector, NewCreatePreviewDeploymentUsecase)
+	do.Provide(injector, NewTeardownPreviewUsecase)
+	do.Provide(injector, NewUpdateDeploymentStatusUsecase)
+	do.Provide(injector, NewRunDeploymentUsecase)
+	do.Provide(injector, NewPruneImagesUsecase)
+	do.Provide(injector, NewRollbackDeploymentUsecase)
+	do.Provide(injector, NewRollbackRepositoryUsecase)
+	do.Provide(injector, NewDeleteRepositoryUsecase)
+	do.Provide(injector, NewRestoreRepositoryUsecase)
+	do.Provide(injector, NewRenameRepositoryUsecase)
+	do.Provide(injector, NewGetRepositoryByRedirectUsecase)
+	do.Provide(injector, NewMigrateRepositoryOwnersUsecase)
+	do.Provide(injector, NewListRefsUsecase)
+	do.Provide(injector, NewCreateRefUsecase)
+	do.Provide(injector, NewDeleteRefUsecase)
+	do.Provide(injector, NewListCommitsUsecase)
+	do.Provide(injector, NewGetCommitUsecase)
+	do.Provide(injector, NewUpdateDeployBranchUsecase)
+	do.Provide(injector, NewUpdateRepositoryMaxPreviewsUsecase)
+	do.Provide(injector, NewCreateOrganizationUsecase)
+	do.Provide(injector, NewAuthorizeOwnerUsecase)
+	do.Provide(injector, NewAuthorizeRepositoryAccessUsecase)
+	do.Provide(injector, NewGetContainerLogsUsecase)
+	do.Provide(injector, NewGetDeploymentLogUsecase)
+	do.Provide(injector, NewFollowDeploymentLogUsecase)
+	do.Provide(injector, NewUpdateRepositoryHooksUsecase)
+
+	if _, err := do.MustInvoke[repository.UserRepository](injector).Create(context.Background(), &entity.User{Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := do.MustInvoke[repository.RepositoryRepository](injector).Create(context.Background(), &entity.Repository{Owner: owner, Name: "app", DeployBranch: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a plain bare repository, the post-receive hook is replaced by the test
+	bare := do.MustInvoke[storage.GitStorage](injector).GetRepoDir(repo.FullName())
+	runGit(t, root, "init", "--bare", "--initial-branch=main", bare)
+	work := filepath.Join(root, "work")
+	runGit(t, root, "init", "--initial-branch=main", work)
+
+	return &deployTest{t: t, injector: injector, runtime: runtime, repo: repo, name: repo.FullName(), work: work}
+}
+
+// push commits the files, pushes them to main and runs the deployment the hook queues.
+func (d *deployTest) push(files map[string]string) (*entity.Deployment, error) {
+	d.t.Helper()
+	return d.pushBranch("main", files)
+}
+
+// pushBranch commits the files, pushes them to the branch and runs the deployment the hook
+// queues, a preview unless the branch is main. The preview usecase's error is returned as is.
+func (d *deployTest) pushBranch(branch string, files map[string]string) (*entity.Deployment, error) {
+	d.t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(d.work, name), []byte(content), 0o644); err != nil {
+			d.t.Fatal(err)
+		}
+	}
+	runGit(d.t, d.work, "add", "-A")
+	runGit(d.t, d.work, "commit", "--allow-empty", "-m", "change")
+	sha := runGit(d.t, d.work, "rev-parse", "HEAD")
+	runGit(d.t, d.work, "push", do.MustInvoke[storage.GitStorage](d.injector).GetRepoDir(d.name), "HEAD:"+branch)
+
+	ctx := context.Background()
+	if branch != "main" {
+		dep, err := do.MustInvoke[CreatePreviewDeploymentUsecase](d.injector).Execute(ctx, d.name, branch, sha, false)
+		if err != nil {
+			return nil, err
+		}
+		return do.MustInvoke[RunDeploymentUsecase](d.injector).Execute(ctx, dep.ID)
+	}
+	dep, err := do.MustInvoke[CreateDeploymentUsecase](d.injector).Execute(ctx, d.name, "main", sha, false)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	return do.MustInvoke[RunDeploymentUsecase](d.injector).Execute(ctx, dep.ID)
+}
+
+// instances returns the deployments of the instances the runtime is running.
+func (d *deployTest) instances() []entity.ID {
+	d.t.Helper()
+	instances, err := d.runtime.List(context.Background(), map[string]string{deployer.LabelRepo: d.name})
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	var ids []entity.ID
+	for _, inst := range instances {
+		ids = append(ids, entity.ID(inst.Labels[deployer.LabelDeployment]))
+	}
+	return ids
+}
+
+func (d *deployTest) active() entity.ID {
+	d.t.Helper()
+	dep, err := do.MustInvoke[repository.DeploymentRepository](d.injector).GetActiveByRepo(context.Background(), d.repo.ID)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	return dep.ID
+}
+
+// log returns the build log of the deployment.
+func (d *deployTest) log(id entity.ID) string {
+	d.t.Helper()
+	r, err := do.MustInvoke[GetDeploymentLogUsecase](d.injector).Execute(context.Background(), id)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRunDeploymentReplacesInstance(t *testing.T) {
+	d := newDeployTest(t)
+
+	first, err := d.push(map[string]string{"Dockerfile": "FROM scratch\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != entity.DeploymentStatusSuccess {
+		t.Fatalf("first deployment status = %s, want success", first.Status)
+	}
+	if got := d.instances(); len(got) != 1 || got[0] != first.ID {
+		t.Fatalf("instances after first push = %v, want [%s]", got, first.ID)
+	}
+
+	second, err := d.push(map[string]string{
+		".githost.yml": "run:\n  port: 3000\n  env:\n    GREETING: hello\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := d.instances(); len(got) != 1 || got[0] != second.ID {
+		t.Fatalf("instances after second push = %v, want [%s]", got, second.ID)
+	}
+	if got := d.active(); got != second.ID {
+		t.Fatalf("active deployment = %s, want %s", got, second.ID)
+	}
+	instances, _ := d.runtime.List(context.Background(), map[string]string{deployer.LabelDeployment: second.ID.String()})
+	spec, _ := d.runtime.Spec(instances[0].ID)
+	if spec.Port != 3000 || len(spec.Env) != 1 || spec.Env[0] != "GREETING=hello" {
+		t.Fatalf("spec of second instance = %+v, want port 3000 and GREETING=hello", spec)
+	}
+
+	// a broken release never replaces the running instance
+	d.runtime.StatusFunc = func(spec *deployer.InstanceSpec) deployer.InstanceStatus {
+		return deployer.InstanceStatus{ExitCode: 1}
+	}
+	third, err := d.push(nil)
+	if !errors.Is(err, deployer.ErrUnhealthy) {
+		t.Fatalf("third deployment error = %v, want %v", err, deployer.ErrUnhealthy)
+	}
+	if third.Status != entity.DeploymentStatusFailed {
+		t.Fatalf("third deployment status = %s, want failed", third.Status)
+	}
+	if got := d.instances(); len(got) != 1 || got[0] != second.ID {
+		t.Fatalf("instances after failed push = %v, want [%s]", got, second.ID)
+	}
+	if got := d.active(); got != second.ID {
+		t.Fatalf("active deployment after failed push = %s, want %s", got, second.ID)
+	}
+}
+
+func TestRunDeploymentSwitchesBeforeRetiring(t *testing.T) {
+	d := newDeployTest(t)
+	router := d.recordRoutes()
+	first, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the new deployment is active and both containers run when the traffic switches
+	var switched []entity.ID
+	var active entity.ID
+	router.onSwitch = func(addr string) {
+		switched = d.instances()
+		active = d.active()
+	}
+	second, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(switched)
+	if want := []entity.ID{first.ID, second.ID}; !slices.Equal(switched, want) {
+		t.Fatalf("instances when the traffic switched = %v, want %v", switched, want)
+	}
+	if active != second.ID {
+		t.Fatalf("active deployment when the traffic switched = %s, want %s", active, second.ID)
+	}
+	if got := d.instances(); len(got) != 1 || got[0] != second.ID {
+		t.Fatalf("instances after the switch = %v, want [%s]", got, second.ID)
+	}
+	if !strings.Contains(d.log(second.ID), "Retired container ") {
+		t.Fatalf("log does not tell the previous container was retired:\n%s", d.log(second.ID))
+	}
+}
+
+func TestRunDeploymentKeepsInstanceOfUnhealthyRelease(t *testing.T) {
+	d := newDeployTest(t)
+	router := d.recordRoutes()
+	first, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := router.last()
+
+	// the new container keeps restarting and never passes the health check
+	d.runtime.StatusFunc = func(spec *deployer.InstanceSpec) deployer.InstanceStatus {
+		return deployer.InstanceStatus{Running: true, Health: deployer.HealthUnhealthy}
+	}
+	second, err := d.push(nil)
+	if !errors.Is(err, deployer.ErrUnhealthy) {
+		t.Fatalf("deployment error = %v, want %v", err, deployer.ErrUnhealthy)
+	}
+	if second.Status != entity.DeploymentStatusFailed || second.IsActive {
+		t.Fatalf("deployment = %+v, want failed and inactive", second)
+	}
+	if got := router.last(); got != addr {
+		t.Fatalf("app routed to %s, want the previous container at %s", got, addr)
+	}
+	if got := d.instances(); len(got) != 1 || got[0] != first.ID {
+		t.Fatalf("instances = %v, want the previous one %s", got, first.ID)
+	}
+	if got := d.active(); got != first.ID {
+		t.Fatalf("active deployment = %s, want %s", got, first.ID)
+	}
+	if log := d.log(second.ID); !strings.Contains(log, "the previous container keeps running") {
+		t.Fatalf("log does not tell the previous container keeps running:\n%s", log)
+	}
+}
+
+func TestRunDeploymentStartFailure(t *testing.T) {
+	d := newDeployTest(t)
+	router := d.recordRoutes()
+	first, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := router.last()
+
+	d.runtime.StartFunc = func(spec *deployer.InstanceSpec) error {
+		return errors.New("port is already allocated")
+	}
+	second, err := d.push(nil)
+	if err == nil || !strings.Contains(err.Error(), "failed to start container") {
+		t.Fatalf("deployment error = %v, want the failed start", err)
+	}
+	if second.Status != entity.DeploymentStatusFailed || second.IsActive {
+		t.Fatalf("deployment = %+v, want failed and inactive", second)
+	}
+	if got := router.last(); got != addr {
+		t.Fatalf("app routed to %s, want the previous container at %s", got, addr)
+	}
+	if got := d.instances(); len(got) != 1 || got[0] != first.ID {
+		t.Fatalf("instances = %v, want the previous one %s", got, first.ID)
+	}
+	if got := d.active(); got != first.ID {
+		t.Fatalf("active deployment = %s, want %s", got, first.ID)
+	}
+}
+
+func TestRunDeploymentStatusTransitions(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	deployments := do.MustInvoke[repository.DeploymentRepository](d.injector)
+	first, err := d.push(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// queue a deployment the way the hook does and watch it from the build
+	runGit(t, d.work, "commit", "--allow-empty", "-m", "change")
+	sha := runGit(t, d.work, "rev-parse", "HEAD")
+	runGit(t, d.work, "push", do.MustInvoke[storage.GitStorage](d.injector).GetRepoDir(d.name), "HEAD:main")
+	queued, err := do.MustInvoke[CreateDeploymentUsecase](d.injector).Execute(ctx, d.name, "main", sha, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued.Status != entity.DeploymentStatusPending || queued.IsActive {
+		t.Fatalf("queued deployment = %+v, want pending and inactive", queued)
+	}
+	repo, err := do.MustInvoke[repository.RepositoryRepository](d.injector).GetByID(ctx, d.repo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.LatestSHA != sha {
+		t.Fatalf("latest SHA = %s, want %s", repo.LatestSHA, sha)
+	}
+
+	var building *entity.Deployment
+	d.runtime.BuildFunc = func(req *deployer.BuildRequest) error {
+		building, _ = deployments.GetByID(ctx, queued.ID)
+		return nil
+	}
+	second, err := do.MustInvoke[RunDeploymentUsecase](d.injector).Execute(ctx, queued.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if building == nil || building.Status != entity.DeploymentStatusRunning || building.IsActive {
+		t.Fatalf("deployment while building = %+v, want running and inactive", building)
+	}
+	if second.Status != entity.DeploymentStatusSuccess || !second.IsActive {
+		t.Fatalf("deployment = %+v, want success and active", second)
+	}
+	if previous, _ := deployments.GetByID(ctx, first.ID); previous.IsActive {
+		t.Fatal("previous deployment is still active")
+	}
+
+	// a failed deployment never becomes active
+	d.runtime.BuildFunc = func(req *deployer.BuildRequest) error {
+		return errors.New("build failed")
+	}
+	third, err := d.push(nil)
+	if err == nil || third.Status != entity.DeploymentStatusFailed || third.IsActive {
+		t.Fatalf("deployment = %+v, %v, want failed and inactive", third, err)
+	}
+	if got := d.active(); got != second.ID {
+		t.Fatalf("active deployment after failed build = %s, want %s", got, second.ID)
+	}
+}
+
+func TestRunDeploymentBuildFailure(t *testing.T) {
+	d := newDeployTest(t)
+	if _, err := d.push(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	d.runtime.BuildFunc = func(req *deployer.BuildRequest) error {
+		return errors.New("build failed")
+	}
+	dep, err := d.push(nil)
+	if err == nil || dep.Status != entity.DeploymentStatusFailed {
+		t.Fatalf("deployment = %+v, %v, want failed", dep, err)
+	}
+	if got := d.instances(); len(got) != 1 {
+		t.Fatalf("instances after failed build = %v, want the previous one", got)
+	}
+}
+
+func TestRunDeploymentLocalEnv(t *testing.T) {
+	d := newDeployTest(t)
+	key := bytes.Repeat([]byte{7}, 32)
+	masterKey := base64.StdEncoding.EncodeToString(key)
+	t.Setenv(config.EnvMasterKey, masterKey)
+	t.Setenv("GITHOST_OTHER", "other")
+	cipher, err := secret.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	do.OverrideValue(d.injector, cipher)
+	do.OverrideValue(d.injector, deployer.NewLocalRuntime(filepath.Join(t.TempDir(), "local")))
+	do.Provide(d.injector, NewSetEnvVarUsecase)
+	if _, err := do.MustInvoke[SetEnvVarUsecase](d.injector).Execute(context.Background(), d.name, "GREETING", "hello", true); err != nil {
+		t.Fatal(err)
+	}
+
+	// the app prints its environment and exits, which fails the deployment
+	out := filepath.Join(t.TempDir(), "env")
+	if _, err := d.push(map[string]string{"Procfile": "web: env > " + out + "\n"}); !errors.Is(err, deployer.ErrUnhealthy) {
+		t.Fatalf("deployment error = %v, want %v", err, deployer.ErrUnhealthy)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if !slices.Contains(env, "GREETING=hello") || !slices.ContainsFunc(env, func(v string) bool { return strings.HasPrefix(v, "PORT=") }) {
+		t.Fatalf("environment of the app = %v, want GREETING and PORT", env)
+	}
+	for _, v := range env {
+		if strings.HasPrefix(v, "GITHOST_") || strings.Contains(v, masterKey) {
+			t.Fatalf("environment of the app has %q of the server", v)
+		}
+	}
+}
+
+func TestRunDeploymentBuildSecrets(t *testing.T) {
+	d := newDeployTest(t)
+	cipher, err := secret.NewCipher(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	do.OverrideValue(d.injector, cipher)
+	do.Provide(d.injector, NewSetEnvVarUsecase)
+	set := do.MustInvoke[SetEnvVarUsecase](d.injector)
+	if _, err := set.Execute(context.Background(), d.name, "TOKEN", "s3cret", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Execute(context.Background(), d.name, "GREETING", "hello", false); err != nil {
+		t.Fatal(err)
+	}
+
+	var secrets map[string][]byte
+	d.runtime.BuildFunc = func(req *deployer.BuildRequest) error {
+		secrets = req.Secrets
+		return nil
+	}
+	if _, err := d.push(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 || string(secrets["TOKEN"]) != "s3cret" {
+		t.Fatalf("build secrets = %q, want only TOKEN", secrets)
+	}
+}
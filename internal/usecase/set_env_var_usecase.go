@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type SetEnvVarUsecase interface {
+	// Execute creates or replaces an environment variable of the repository.
+	// It takes effect with the next deployment.
+	Execute(ctx context.Context, reponame, name, value string, secret bool) (*entity.EnvVar, error)
+}
+
+type setEnvVarUsecaseImpl struct {
+	repositoryRepository repository.RepositoryRepository
+	envVarRepository     repository.EnvVarRepository
+}
+
+// Execute implements SetEnvVarUsecase.
+func (s *setEnvVarUsecaseImpl) Execute(ctx context.Context, reponame, name, value string, secret bool) (*entity.EnvVar, error) {
+	if !entity.IsValidEnvVarName(name) {
+		return nil, entity.ErrInvalid
+	}
+	repo, err := s.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	env, err := s.envVarRepository.Set(ctx, &entity.EnvVar{
+		RepoID: repo.ID,
+		Name:   name,
+		Value:  value,
+		Secret: secret,
+	})
+	if err != nil {
+		return nil, envVarError(err)
+	}
+	return env.Redacted(), nil
+}
+
+func NewSetEnvVarUsecase(injector *do.Injector) (SetEnvVarUsecase, error) {
+	return &setEnvVarUsecaseImpl{
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+		envVarRepository:     do.MustInvoke[repository.EnvVarRepository](injector),
+	}, nil
+}
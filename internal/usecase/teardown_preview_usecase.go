@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type TeardownPreviewUsecase interface {
+	// Execute queues the removal of the preview environment of a deleted branch. It is queued like
+	// a deployment so that it runs after the builds of the branch that are still queued.
+	// It returns entity.ErrNotFound if the branch has no preview.
+	Execute(ctx context.Context, reponame, branch string) (*entity.Deployment, error)
+}
+
+type teardownPreviewUsecaseImpl struct {
+	repositoryRepository repository.RepositoryRepository
+	deploymentRepository repository.DeploymentRepository
+}
+
+// Execute implements TeardownPreviewUsecase.
+func (t *teardownPreviewUsecaseImpl) Execute(ctx context.Context, reponame, branch string) (*entity.Deployment, error) {
+	repo, err := t.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	deps, err := t.deploymentRepository.ListByRepo(ctx, repo.ID)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	if newest := newestPreview(deps, branch); newest == nil || newest.IsTeardown() {
+		return nil, entity.ErrNotFound
+	}
+
+	dep, err := t.deploymentRepository.Create(ctx, &entity.Deployment{
+		RepoID:    repo.ID,
+		Branch:    branch,
+		CommitSHA: entity.ZeroSHA,
+		Status:    entity.DeploymentStatusPending,
+		Preview:   true,
+	})
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	return dep, nil
+}
+
+func NewTeardownPreviewUsecase(injector *do.Injector) (TeardownPreviewUsecase, error) {
+	return &teardownPreviewUsecaseImpl{
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+		deploymentRepository: do.MustInvoke[repository.DeploymentRepository](injector),
+	}, nil
+}
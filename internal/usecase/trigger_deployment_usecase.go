@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/git"
+	"github.com/yz4230/githost-poc/internal/queue"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+type TriggerDeploymentUsecase interface {
+	// Execute queues a deployment of the commit.
+	// An empty commitSHA deploys the tip of the deploy branch. A clean deployment builds
+	// without the build cache.
+	Execute(ctx context.Context, reponame, commitSHA string, clean bool) (*entity.Deployment, error)
+}
+
+type triggerDeploymentUsecaseImpl struct {
+	gitStorage           storage.GitStorage
+	repositoryRepository repository.RepositoryRepository
+	deploymentRepository repository.DeploymentRepository
+	deploymentQueue      queue.DeploymentQueue
+}
+
+// Execute implements TriggerDeploymentUsecase.
+func (t *triggerDeploymentUsecaseImpl) Execute(ctx context.Context, reponame, commitSHA string, clean bool) (*entity.Deployment, error) {
+	repo, err := t.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	if commitSHA == "" {
+		commitSHA = repo.DeployRef()
+	}
+	resolved, err := git.ResolveCommit(ctx, t.gitStorage.GetRepoDir(repo.FullName()), commitSHA)
+	if err != nil {
+		return nil, entity.ErrInvalid
+	}
+
+	dep, err := t.deploymentRepository.Create(ctx, &entity.Deployment{
+		RepoID:    repo.ID,
+		Branch:    repo.DeployBranch,
+		CommitSHA: resolved,
+		Status:    entity.DeploymentStatusPending,
+		Clean:     clean,
+	})
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+
+	t.deploymentQueue.Notify()
+
+	return dep, nil
+}
+
+func NewTriggerDeploymentUsecase(injector *do.Injector) (TriggerDeploymentUsecase, error) {
+	return &triggerDeploymentUsecaseImpl{
+		gitStorage:           do.MustInvoke[storage.GitStorage](injector),
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+		deploymentRepository: do.MustInvoke[repository.DeploymentRepository](injector),
+		deploymentQueue:      do.MustInvoke[queue.DeploymentQueue](injector),
+	}, nil
+}
@@ -0,0 +1,42 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/git"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type UpdateDeployBranchUsecase interface {
+	// Execute changes the branch whose pushes are deployed. The branch does not need to exist yet.
+	Execute(ctx context.Context, reponame, branch string) (*entity.Repository, error)
+}
+
+type updateDeployBranchUsecaseImpl struct {
+	repositoryRepository repository.RepositoryRepository
+}
+
+// Execute implements UpdateDeployBranchUsecase.
+func (u *updateDeployBranchUsecaseImpl) Execute(ctx context.Context, reponame, branch string) (*entity.Repository, error) {
+	if !git.IsValidBranchName(branch) {
+		return nil, entity.ErrInvalid
+	}
+	repo, err := u.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	repo.DeployBranch = branch
+	repo, err = u.repositoryRepository.Update(ctx, repo)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	return repo, nil
+}
+
+func NewUpdateDeployBranchUsecase(injector *do.Injector) (UpdateDeployBranchUsecase, error) {
+	return &updateDeployBranchUsecaseImpl{
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+	}, nil
+}
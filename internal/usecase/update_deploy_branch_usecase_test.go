@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+func TestUpdateDeployBranch(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	update := do.MustInvoke[UpdateDeployBranchUsecase](d.injector)
+
+	// the branch does not need to exist yet
+	repo, err := update.Execute(ctx, d.name, "release/v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.DeployBranch != "release/v2" || repo.DeployRef() != "refs/heads/release/v2" {
+		t.Fatalf("deploy branch = %q (%s), want release/v2", repo.DeployBranch, repo.DeployRef())
+	}
+	stored, err := do.MustInvoke[repository.RepositoryRepository](d.injector).GetByID(ctx, d.repo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.DeployBranch != "release/v2" {
+		t.Fatalf("stored deploy branch = %q, want release/v2", stored.DeployBranch)
+	}
+
+	for _, branch := range []string{"", "-main", "a..b", "main.lock", "with space"} {
+		if _, err := update.Execute(ctx, d.name, branch); err != entity.ErrInvalid {
+			t.Fatalf("deploy branch %q error = %v, want %v", branch, err, entity.ErrInvalid)
+		}
+	}
+	if _, err := update.Execute(ctx, "alice/missing", "main"); err != entity.ErrNotFound {
+		t.Fatalf("deploy branch of a missing repository error = %v, want %v", err, entity.ErrNotFound)
+	}
+}
@@ -0,0 +1,45 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type UpdateDeploymentStatusUsecase interface {
+	// Execute moves the deployment to the status. A successful deployment becomes the
+	// active one of its repository, replacing the previously active deployment.
+	Execute(ctx context.Context, id entity.ID, status entity.DeploymentStatus) (*entity.Deployment, error)
+}
+
+type updateDeploymentStatusUsecaseImpl struct {
+	deploymentRepository repository.DeploymentRepository
+}
+
+// Execute implements UpdateDeploymentStatusUsecase.
+func (u *updateDeploymentStatusUsecaseImpl) Execute(ctx context.Context, id entity.ID, status entity.DeploymentStatus) (*entity.Deployment, error) {
+	dep, err := u.deploymentRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	dep.Status = status
+	dep, err = u.deploymentRepository.Update(ctx, dep)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	if status == entity.DeploymentStatusSuccess {
+		if err := u.deploymentRepository.SetActive(ctx, dep); err != nil {
+			return nil, entity.ErrInternal
+		}
+		dep.IsActive = true
+	}
+	return dep, nil
+}
+
+func NewUpdateDeploymentStatusUsecase(injector *do.Injector) (UpdateDeploymentStatusUsecase, error) {
+	return &updateDeploymentStatusUsecaseImpl{
+		deploymentRepository: do.MustInvoke[repository.DeploymentRepository](injector),
+	}, nil
+}
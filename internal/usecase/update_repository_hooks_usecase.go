@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+type UpdateRepositoryHooksUsecase interface {
+	// Execute rewrites the outdated git hooks of the repositories, deleted ones included so they
+	// work once restored, and returns the repositories whose hooks were rewritten. Hooks are
+	// outdated if an earlier version wrote them, or the executable moved since. Repositories that
+	// fail are reported in the error.
+	Execute(ctx context.Context) ([]*entity.Repository, error)
+}
+
+type updateRepositoryHooksUsecaseImpl struct {
+	gitStorage           storage.GitStorage
+	repositoryRepository repository.RepositoryRepository
+}
+
+// Execute implements UpdateRepositoryHooksUsecase.
+func (u *updateRepositoryHooksUsecaseImpl) Execute(ctx context.Context) ([]*entity.Repository, error) {
+	repos, err := u.repositoryRepository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := u.repositoryRepository.ListDeleted(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var updated []*entity.Repository
+	var errs []error
+	for _, repo := range append(repos, deleted...) {
+		if !u.gitStorage.IsRepoExist(repo.FullName()) {
+			continue
+		}
+		rewritten, err := u.gitStorage.InstallHooks(ctx, repo.FullName())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", repo.FullName(), err))
+			continue
+		}
+		if rewritten {
+			updated = append(updated, repo)
+		}
+	}
+	return updated, errors.Join(errs...)
+}
+
+func NewUpdateRepositoryHooksUsecase(injector *do.Injector) (UpdateRepositoryHooksUsecase, error) {
+	return &updateRepositoryHooksUsecaseImpl{
+		gitStorage:           do.MustInvoke[storage.GitStorage](injector),
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+	}, nil
+}
@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/storage"
+)
+
+func TestUpdateRepositoryHooks(t *testing.T) {
+	d := newDeployTest(t)
+	ctx := context.Background()
+	update := do.MustInvoke[UpdateRepositoryHooksUsecase](d.injector)
+	hook := filepath.Join(do.MustInvoke[storage.GitStorage](d.injector).GetRepoDir(d.name), "hooks", "post-receive")
+
+	// the hook of earlier versions joins the ref lines of a push into one
+	legacy := "#!/bin/sh\necho $(cat) | githost hook post-receive\n"
+	if err := os.WriteFile(hook, []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	repos, err := update.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 || repos[0].ID != d.repo.ID {
+		t.Fatalf("updated repositories = %v, want %s", repos, d.name)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := os.ReadFile(hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(script), "exec \""+executable+"\" hook post-receive") {
+		t.Fatalf("hook after update:\n%s", script)
+	}
+	if info, err := os.Stat(hook); err != nil || info.Mode().Perm()&0o100 == 0 {
+		t.Fatalf("hook after update is not executable: %v, %v", info.Mode(), err)
+	}
+
+	// hooks that are current are left alone, deleted repositories are updated too
+	if repos, err := update.Execute(ctx); err != nil || len(repos) != 0 {
+		t.Fatalf("second update = %v, %v, want nothing", repos, err)
+	}
+	if err := do.MustInvoke[DeleteRepositoryUsecase](d.injector).Execute(ctx, d.name, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(hook, []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if repos, err := update.Execute(ctx); err != nil || len(repos) != 1 {
+		t.Fatalf("update of the deleted repository = %v, %v, want it", repos, err)
+	}
+}
@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/config"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/proxy"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+var hostPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type UpdateRepositoryHostUsecase interface {
+	// Execute changes the host name the reverse proxy routes to the app of the repository.
+	// An empty host restores the default, <name>.<apps domain>.
+	Execute(ctx context.Context, reponame, host string) (*entity.Repository, error)
+}
+
+type updateRepositoryHostUsecaseImpl struct {
+	domain               string
+	repositoryRepository repository.RepositoryRepository
+	router               proxy.Router
+}
+
+// Execute implements UpdateRepositoryHostUsecase.
+func (u *updateRepositoryHostUsecaseImpl) Execute(ctx context.Context, reponame, host string) (*entity.Repository, error) {
+	host = strings.ToLower(host)
+	if host != "" && (len(host) > 253 || !hostPattern.MatchString(host)) {
+		return nil, entity.ErrInvalid
+	}
+	repo, err := u.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+
+	repos, err := u.repositoryRepository.List(ctx)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	repo.Host = host
+	for _, other := range repos {
+		if other.ID != repo.ID && other.AppHost(u.domain) == repo.AppHost(u.domain) {
+			return nil, entity.ErrConflict
+		}
+	}
+
+	repo, err = u.repositoryRepository.Update(ctx, repo)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	u.router.SetHost(repo)
+	return repo, nil
+}
+
+func NewUpdateRepositoryHostUsecase(injector *do.Injector) (UpdateRepositoryHostUsecase, error) {
+	return &updateRepositoryHostUsecaseImpl{
+		domain:               do.MustInvoke[*config.Config](injector).AppsDomain,
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+		router:               do.MustInvoke[proxy.Router](injector),
+	}, nil
+}
@@ -0,0 +1,42 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+type UpdateRepositoryMaxPreviewsUsecase interface {
+	// Execute changes the number of preview environments the repository may have. Nil restores
+	// the server default. Previews over the new limit keep running until their branches are deleted.
+	Execute(ctx context.Context, reponame string, maxPreviews *int) (*entity.Repository, error)
+}
+
+type updateRepositoryMaxPreviewsUsecaseImpl struct {
+	repositoryRepository repository.RepositoryRepository
+}
+
+// Execute implements UpdateRepositoryMaxPreviewsUsecase.
+func (u *updateRepositoryMaxPreviewsUsecaseImpl) Execute(ctx context.Context, reponame string, maxPreviews *int) (*entity.Repository, error) {
+	if maxPreviews != nil && *maxPreviews < 0 {
+		return nil, entity.ErrInvalid
+	}
+	repo, err := u.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	repo.MaxPreviews = maxPreviews
+	repo, err = u.repositoryRepository.Update(ctx, repo)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	return repo, nil
+}
+
+func NewUpdateRepositoryMaxPreviewsUsecase(injector *do.Injector) (UpdateRepositoryMaxPreviewsUsecase, error) {
+	return &updateRepositoryMaxPreviewsUsecaseImpl{
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+	}, nil
+}
@@ -0,0 +1,53 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/samber/do"
+	"github.com/yz4230/githost-poc/internal/entity"
+	"github.com/yz4230/githost-poc/internal/git"
+	"github.com/yz4230/githost-poc/internal/repository"
+)
+
+// UpdateRepositoryInput holds the settings to change, nil fields are left as they are.
+type UpdateRepositoryInput struct {
+	Description  *string
+	DeployBranch *string
+}
+
+type UpdateRepositoryUsecase interface {
+	// Execute changes the description and the deploy branch of the repository.
+	Execute(ctx context.Context, reponame string, input UpdateRepositoryInput) (*entity.Repository, error)
+}
+
+type updateRepositoryUsecaseImpl struct {
+	repositoryRepository repository.RepositoryRepository
+}
+
+// Execute implements UpdateRepositoryUsecase.
+func (u *updateRepositoryUsecaseImpl) Execute(ctx context.Context, reponame string, input UpdateRepositoryInput) (*entity.Repository, error) {
+	if input.DeployBranch != nil && !git.IsValidBranchName(*input.DeployBranch) {
+		return nil, entity.ErrInvalid
+	}
+	repo, err := u.repositoryRepository.GetByName(ctx, reponame)
+	if err != nil {
+		return nil, err
+	}
+	if input.Description != nil {
+		repo.Description = *input.Description
+	}
+	if input.DeployBranch != nil {
+		repo.DeployBranch = *input.DeployBranch
+	}
+	repo, err = u.repositoryRepository.Update(ctx, repo)
+	if err != nil {
+		return nil, entity.ErrInternal
+	}
+	return repo, nil
+}
+
+func NewUpdateRepositoryUsecase(injector *do.Injector) (UpdateRepositoryUsecase, error) {
+	return &updateRepositoryUsecaseImpl{
+		repositoryRepository: do.MustInvoke[repository.RepositoryRepository](injector),
+	}, nil
+}